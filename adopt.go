@@ -0,0 +1,43 @@
+package goply
+
+import (
+	"context"
+	"fmt"
+
+	ssautils "github.com/fluxcd/pkg/ssa/utils"
+	"github.com/samber/lo"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// adoptionCandidates returns the objects that already exist in the cluster but have never been applied by goply
+func (r *Reconciler) adoptionCandidates(ctx context.Context, objects []*unstructured.Unstructured) ([]*unstructured.Unstructured, error) {
+	candidates := []*unstructured.Unstructured{}
+
+	for _, obj := range objects {
+		live := &metav1.PartialObjectMetadata{}
+		live.SetGroupVersionKind(obj.GroupVersionKind())
+		err := r.mgr.Client().Get(withCachedReads(ctx), client.ObjectKeyFromObject(obj), live)
+		if apierrors.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error checking if %v exists: %w", ssautils.FmtUnstructured(obj), err)
+		}
+
+		if !appliedByGoply(live.GetManagedFields()) {
+			candidates = append(candidates, obj)
+		}
+	}
+
+	return candidates, nil
+}
+
+// appliedByGoply reports whether goply's field manager has ever applied the object the entries belong to
+func appliedByGoply(entries []metav1.ManagedFieldsEntry) bool {
+	return lo.ContainsBy(entries, func(entry metav1.ManagedFieldsEntry) bool {
+		return entry.Manager == fieldManager && entry.Operation == metav1.ManagedFieldsOperationApply
+	})
+}
@@ -0,0 +1,220 @@
+package goply
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/fluxcd/pkg/ssa"
+	ssautils "github.com/fluxcd/pkg/ssa/utils"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func (r *Reconciler) applyAll(ctx context.Context, objects []*unstructured.Unstructured, opts ApplyOpts) (*ssa.ChangeSet, error) {
+	if opts.OrderByDependencies {
+		return r.applyConcurrently(ctx, objects, opts)
+	}
+
+	// ApplyAll would reorder the objects
+	if opts.PerObjectApplyTimeout > 0 || opts.DisableStaging {
+		return r.applyIndividually(ctx, objects, opts)
+	}
+
+	batches := [][]*unstructured.Unstructured{objects}
+	// ApplyAll imposes its own ordering, so the only way to keep ours is to hand it one kind at a time
+	if opts.SortByKind {
+		batches = groupByKind(objects)
+	}
+	if opts.MaxApplyBatchBytes > 0 && stageFrom(ctx) == StageTwo {
+		if !opts.SortByKind {
+			// Batches are applied in order, so they need to be cut from the order ApplyAll would have used
+			sorted := append([]*unstructured.Unstructured{}, objects...)
+			sort.Sort(ssa.SortableUnstructureds(sorted))
+			batches = [][]*unstructured.Unstructured{sorted}
+		}
+		var err error
+		batches, err = batchBySize(batches, opts.MaxApplyBatchBytes)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if len(batches) == 1 {
+		changeSet, err := r.withRecreate(ctx, opts, func() (*ssa.ChangeSet, error) {
+			return r.mgr.ApplyAll(ctx, batches[0], ssaApplyOptions(opts))
+		})
+		if err == nil {
+			reportChangeSet(ctx, opts, changeSet)
+		}
+		return changeSet, err
+	}
+
+	changeSet := ssa.NewChangeSet()
+	for _, batch := range batches {
+		cs, err := r.withRecreate(ctx, opts, func() (*ssa.ChangeSet, error) {
+			return r.mgr.ApplyAll(ctx, batch, ssaApplyOptions(opts))
+		})
+		if err != nil {
+			return changeSet, err
+		}
+		reportChangeSet(ctx, opts, cs)
+		changeSet.Append(cs.Entries)
+	}
+	return changeSet, nil
+}
+
+// batchBySize splits each batch further, so that no batch's objects add up to more than limit bytes of JSON. An object
+// that's over the limit on its own gets a batch to itself
+func batchBySize(batches [][]*unstructured.Unstructured, limit int) ([][]*unstructured.Unstructured, error) {
+	sized := [][]*unstructured.Unstructured{}
+	for _, batch := range batches {
+		current, currentSize := []*unstructured.Unstructured{}, 0
+		for _, obj := range batch {
+			data, err := obj.MarshalJSON()
+			if err != nil {
+				return nil, fmt.Errorf("error encoding %v: %w", ssautils.FmtUnstructured(obj), err)
+			}
+			if len(current) > 0 && currentSize+len(data) > limit {
+				sized = append(sized, current)
+				current, currentSize = []*unstructured.Unstructured{}, 0
+			}
+			current = append(current, obj)
+			currentSize += len(data)
+		}
+		if len(current) > 0 {
+			sized = append(sized, current)
+		}
+	}
+	return sized, nil
+}
+
+// applyIndividually applies each object with its own timeout, in the same order ApplyAll would have (unless
+// SortByKind already put them in order, or DisableStaging asks for input order), aggregating the results into a single
+// change set
+func (r *Reconciler) applyIndividually(ctx context.Context, objects []*unstructured.Unstructured, opts ApplyOpts) (*ssa.ChangeSet, error) {
+	sorted := append([]*unstructured.Unstructured{}, objects...)
+	if !opts.SortByKind && !opts.DisableStaging {
+		sort.Sort(ssa.SortableUnstructureds(sorted))
+	}
+
+	changeSet := ssa.NewChangeSet()
+	timedOut := []error{}
+	for _, obj := range sorted {
+		item := toInventoryItem(obj)
+		if err := checkpoint(ctx, &item); err != nil {
+			return changeSet, errors.Join(append(timedOut, err)...)
+		}
+		cs, err := r.applyOne(ctx, obj, opts)
+		// One object timing out shouldn't hold up the rest, that's the point of the per object timeout
+		if err != nil && opts.PerObjectApplyTimeout > 0 && ctx.Err() == nil && errors.Is(err, context.DeadlineExceeded) {
+			timedOut = append(timedOut, err)
+			continue
+		}
+		if err != nil {
+			return changeSet, errors.Join(append(timedOut, err)...)
+		}
+		changeSet.Append(cs.Entries)
+	}
+
+	return changeSet, errors.Join(timedOut...)
+}
+
+// applyConcurrently applies each object on its own, with at most MaxParallelism applies in flight at once. The change
+// set is in the same order as objects, regardless of the order the applies finished in
+func (r *Reconciler) applyConcurrently(ctx context.Context, objects []*unstructured.Unstructured, opts ApplyOpts) (*ssa.ChangeSet, error) {
+	parallelism := opts.MaxParallelism
+	if parallelism <= 0 {
+		parallelism = DefaultMaxParallelism
+	}
+
+	changeSets := make([]*ssa.ChangeSet, len(objects))
+	errs := make([]error, len(objects))
+
+	sem := make(chan struct{}, parallelism)
+	var wg sync.WaitGroup
+	var cancelled error
+	for idx, obj := range objects {
+		// Nothing more is started once cancelled, what's already in flight is left to finish
+		item := toInventoryItem(obj)
+		if cancelled = checkpoint(ctx, &item); cancelled != nil {
+			break
+		}
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			changeSets[idx], errs[idx] = r.applyOne(ctx, obj, opts)
+		}()
+	}
+	wg.Wait()
+
+	changeSet := ssa.NewChangeSet()
+	for _, cs := range changeSets {
+		if cs != nil {
+			changeSet.Append(cs.Entries)
+		}
+	}
+
+	return changeSet, errors.Join(append(errs, cancelled)...)
+}
+
+// applyOne applies a single object, bounded by PerObjectApplyTimeout if it's set
+func (r *Reconciler) applyOne(ctx context.Context, obj *unstructured.Unstructured, opts ApplyOpts) (*ssa.ChangeSet, error) {
+	changeSet, err := r.withRecreate(ctx, opts, func() (*ssa.ChangeSet, error) {
+		objCtx := ctx
+		if opts.PerObjectApplyTimeout > 0 {
+			var cancel context.CancelFunc
+			objCtx, cancel = context.WithTimeout(ctx, opts.PerObjectApplyTimeout)
+			defer cancel()
+		}
+
+		entry, err := r.mgr.Apply(objCtx, obj, ssaApplyOptions(opts))
+		if err != nil {
+			if opts.PerObjectApplyTimeout > 0 && errors.Is(objCtx.Err(), context.DeadlineExceeded) {
+				return nil, fmt.Errorf("%v apply timed out after %v: %w", ssautils.FmtUnstructured(obj), opts.PerObjectApplyTimeout, err)
+			}
+			return nil, err
+		}
+
+		cs := ssa.NewChangeSet()
+		cs.Add(*entry)
+		return cs, nil
+	})
+	if err != nil {
+		return nil, &ApplyError{Objects: []InventoryItem{toInventoryItem(obj)}, err: err}
+	}
+	reportChangeSet(ctx, opts, changeSet)
+	return changeSet, nil
+}
+
+// skipExisting filters out objects that already exist in the cluster, recording them as skipped
+func (r *Reconciler) skipExisting(ctx context.Context, objects []*unstructured.Unstructured, result *Result) ([]*unstructured.Unstructured, error) {
+	toApply := []*unstructured.Unstructured{}
+
+	for _, obj := range objects {
+		existing := &metav1.PartialObjectMetadata{}
+		existing.SetGroupVersionKind(obj.GroupVersionKind())
+		err := r.mgr.Client().Get(withCachedReads(ctx), client.ObjectKeyFromObject(obj), existing)
+		if apierrors.IsNotFound(err) {
+			toApply = append(toApply, obj)
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error checking if %v exists: %w", ssautils.FmtUnstructured(obj), err)
+		}
+
+		r.log(ctx, "object already exists, not applying", objectKV(obj)...)
+		result.Skipped = append(result.Skipped, SkippedItem{
+			InventoryItem: toInventoryItem(obj),
+			Reason:        SkipReasonAlreadyExists,
+		})
+	}
+
+	return toApply, nil
+}
@@ -0,0 +1,152 @@
+package goply
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/lithammer/dedent"
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestBatchBySize(t *testing.T) {
+	configMap := func(name string, size int) *unstructured.Unstructured {
+		obj := &unstructured.Unstructured{}
+		obj.SetAPIVersion("v1")
+		obj.SetKind("ConfigMap")
+		obj.SetNamespace("goply-test")
+		obj.SetName(name)
+		obj.Object["data"] = map[string]any{"blob": strings.Repeat("x", size)}
+		return obj
+	}
+
+	small1, small2, small3 := configMap("small-1", 100), configMap("small-2", 100), configMap("small-3", 100)
+	big := configMap("big", 5000)
+
+	batches, err := batchBySize([][]*unstructured.Unstructured{{small1, small2, big, small3}}, 1000)
+	require.NoError(t, err)
+
+	names := lo.Map(batches, func(batch []*unstructured.Unstructured, _ int) []string {
+		return lo.Map(batch, func(u *unstructured.Unstructured, _ int) string { return u.GetName() })
+	})
+	require.Equal(t, [][]string{{"small-1", "small-2"}, {"big"}, {"small-3"}}, names)
+
+	// Existing batch boundaries, e.g. from SortByKind, are kept
+	batches, err = batchBySize([][]*unstructured.Unstructured{{small1}, {small2, small3}}, 1000)
+	require.NoError(t, err)
+	require.Len(t, batches, 2)
+}
+
+func TestPerObjectApplyTimeout(t *testing.T) {
+	var mu sync.Mutex
+	applied := []string{}
+	r, err := NewReconciler(&ReconcilerConfig{
+		Kubeconfig: offlineKubeconfig,
+		WrapTransport: func(http.RoundTripper) http.RoundTripper {
+			return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+				if body, ok := coreDiscovery[req.URL.Path]; ok {
+					return jsonResponse(req, http.StatusOK, body), nil
+				}
+				// Everything touching config-slow hangs, as if stuck behind a webhook
+				if strings.HasSuffix(req.URL.Path, "/config-slow") {
+					<-req.Context().Done()
+					return nil, req.Context().Err()
+				}
+				switch req.Method {
+				case http.MethodGet:
+					return jsonResponse(req, http.StatusNotFound, `{"kind":"Status","apiVersion":"v1","status":"Failure","reason":"NotFound","code":404}`), nil
+				case http.MethodPatch:
+					// Applies are answered with what was sent, as if the object had been created
+					body, err := io.ReadAll(req.Body)
+					if err != nil {
+						return nil, err
+					}
+					if req.URL.Query().Get("dryRun") == "" {
+						mu.Lock()
+						applied = append(applied, req.URL.Path[strings.LastIndex(req.URL.Path, "/")+1:])
+						mu.Unlock()
+					}
+					return jsonResponse(req, http.StatusOK, string(body)), nil
+				}
+				return nil, errors.New("unexpected request")
+			})
+		},
+	})
+	require.NoError(t, err)
+
+	objs, err := GetObjects(dedent.Dedent(`
+		---
+		apiVersion: v1
+		kind: ConfigMap
+		metadata:
+		  name: config-a
+		  namespace: goply-test
+		---
+		apiVersion: v1
+		kind: ConfigMap
+		metadata:
+		  name: config-slow
+		  namespace: goply-test
+		---
+		apiVersion: v1
+		kind: ConfigMap
+		metadata:
+		  name: config-z
+		  namespace: goply-test
+	`)[1:])
+	require.NoError(t, err)
+
+	changeSet, err := r.applyAll(context.Background(), objs, ApplyOpts{PerObjectApplyTimeout: 200 * time.Millisecond})
+	require.ErrorContains(t, err, "apply timed out after 200ms")
+	require.Equal(t, []InventoryItem{toInventoryItem(objs[1])}, newApplyError(err).Objects)
+	// The objects on either side of the slow one were still applied
+	require.Equal(t, []string{"config-a", "config-z"}, applied)
+	require.Len(t, changeSet.Entries, 2)
+}
+
+func TestApplyConcurrentlyCancelled(t *testing.T) {
+	requests := 0
+	r, err := NewReconciler(&ReconcilerConfig{
+		Kubeconfig: offlineKubeconfig,
+		WrapTransport: func(http.RoundTripper) http.RoundTripper {
+			return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+				requests++
+				return nil, errors.New("intercepted")
+			})
+		},
+	})
+	require.NoError(t, err)
+
+	objs, err := GetObjects(dedent.Dedent(`
+		---
+		apiVersion: v1
+		kind: ConfigMap
+		metadata:
+		  name: config-a
+		  namespace: goply-test
+		---
+		apiVersion: v1
+		kind: ConfigMap
+		metadata:
+		  name: config-z
+		  namespace: goply-test
+	`)[1:])
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(withStage(context.Background(), StageTwo))
+	cancel()
+
+	_, err = r.applyConcurrently(ctx, objs, ApplyOpts{})
+	var cancelled *CancelledError
+	require.ErrorAs(t, err, &cancelled)
+	require.Equal(t, StageTwo, cancelled.Stage)
+	require.Equal(t, toInventoryItem(objs[0]), *cancelled.Next)
+	require.Zero(t, requests)
+}
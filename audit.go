@@ -0,0 +1,63 @@
+package goply
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// AuditActionPruned is the audit action recorded for objects removed because they left the inventory
+const AuditActionPruned Action = "pruned"
+
+// AuditRecord is the document produced by Result.ToAuditJSON
+type AuditRecord struct {
+	Timestamp   time.Time     `json:"timestamp"`
+	ReconcileID string        `json:"reconcileId"`
+	Objects     []AuditObject `json:"objects"`
+}
+
+// AuditObject is what happened to a single object during the reconcile
+type AuditObject struct {
+	Group     string `json:"group"`
+	Version   string `json:"version"`
+	Kind      string `json:"kind"`
+	Namespace string `json:"namespace,omitempty"`
+	Name      string `json:"name"`
+	Action    Action `json:"action"`
+}
+
+func newAuditObject(item InventoryItem, action Action) AuditObject {
+	return AuditObject{
+		Group:     item.GroupKind.Group,
+		Version:   item.GroupVersion,
+		Kind:      item.GroupKind.Kind,
+		Namespace: item.Namespace,
+		Name:      item.Name,
+		Action:    action,
+	}
+}
+
+// AuditRecord builds the audit document for this result. Applied objects come first, in the order they were
+// applied, followed by skipped and then pruned objects
+func (r Result) AuditRecord() AuditRecord {
+	objects := make([]AuditObject, 0, len(r.Changes)+len(r.Skipped)+len(r.Pruned))
+	for _, c := range r.Changes {
+		objects = append(objects, newAuditObject(c.InventoryItem, c.Action))
+	}
+	for _, s := range r.Skipped {
+		objects = append(objects, newAuditObject(s.InventoryItem, ActionSkipped))
+	}
+	for _, p := range r.Pruned {
+		objects = append(objects, newAuditObject(p, AuditActionPruned))
+	}
+
+	return AuditRecord{
+		Timestamp:   r.Timestamp.UTC(),
+		ReconcileID: r.ReconcileID,
+		Objects:     objects,
+	}
+}
+
+// ToAuditJSON renders the result as a JSON audit document, suitable for shipping to a log pipeline
+func (r Result) ToAuditJSON() ([]byte, error) {
+	return json.Marshal(r.AuditRecord())
+}
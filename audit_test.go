@@ -0,0 +1,51 @@
+package goply
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/cli-utils/pkg/object"
+)
+
+func TestToAuditJSON(t *testing.T) {
+	item := func(group string, kind string, namespace string, name string) InventoryItem {
+		return InventoryItem{
+			ObjMetadata: object.ObjMetadata{
+				GroupKind: schema.GroupKind{Group: group, Kind: kind},
+				Namespace: namespace,
+				Name:      name,
+			},
+			GroupVersion: "v1",
+		}
+	}
+
+	result := Result{
+		ReconcileID: "abc-123",
+		Timestamp:   time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+		Changes: []Change{
+			{InventoryItem: item("", "Namespace", "", "my-ns"), Action: ActionCreated},
+			{InventoryItem: item("", "ConfigMap", "my-ns", "config"), Action: ActionConfigured},
+		},
+		Skipped: []SkippedItem{
+			{InventoryItem: item("example.com", "Widget", "my-ns", "widget"), Reason: SkipReasonMissingKind},
+		},
+		Pruned: []InventoryItem{
+			item("", "ConfigMap", "my-ns", "old-config"),
+		},
+	}
+
+	got, err := result.ToAuditJSON()
+	require.NoError(t, err)
+	require.JSONEq(t, `{
+		"timestamp": "2024-01-02T03:04:05Z",
+		"reconcileId": "abc-123",
+		"objects": [
+			{"group": "", "version": "v1", "kind": "Namespace", "name": "my-ns", "action": "created"},
+			{"group": "", "version": "v1", "kind": "ConfigMap", "namespace": "my-ns", "name": "config", "action": "configured"},
+			{"group": "example.com", "version": "v1", "kind": "Widget", "namespace": "my-ns", "name": "widget", "action": "skipped"},
+			{"group": "", "version": "v1", "kind": "ConfigMap", "namespace": "my-ns", "name": "old-config", "action": "pruned"}
+		]
+	}`, string(got))
+}
@@ -0,0 +1,177 @@
+package goply
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// ttlCache is a map whose entries are forgotten ttl after they're set
+type ttlCache[K comparable, V any] struct {
+	ttl time.Duration
+
+	mu      sync.Mutex
+	entries map[K]ttlEntry[V]
+}
+
+type ttlEntry[V any] struct {
+	value   V
+	expires time.Time
+}
+
+func newTTLCache[K comparable, V any](ttl time.Duration) *ttlCache[K, V] {
+	return &ttlCache[K, V]{ttl: ttl, entries: map[K]ttlEntry[V]{}}
+}
+
+func (c *ttlCache[K, V]) get(key K) (V, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[key]
+	if !ok || time.Now().After(entry.expires) {
+		delete(c.entries, key)
+		var zero V
+		return zero, false
+	}
+	return entry.value, true
+}
+
+func (c *ttlCache[K, V]) set(key K, value V) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[key] = ttlEntry[V]{value: value, expires: time.Now().Add(c.ttl)}
+}
+
+func (c *ttlCache[K, V]) deleteFunc(match func(K) bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for key := range c.entries {
+		if match(key) {
+			delete(c.entries, key)
+		}
+	}
+}
+
+type cachedReadsKey struct{}
+
+// withCachedReads marks ctx as one whose object reads can be served from the cache. Only reads whose staleness can't
+// cause harm should be marked, everything watching for changes (waits in particular) must go to the API server
+func withCachedReads(ctx context.Context) context.Context {
+	return context.WithValue(ctx, cachedReadsKey{}, true)
+}
+
+func cachedReadsFrom(ctx context.Context) bool {
+	cached, _ := ctx.Value(cachedReadsKey{}).(bool)
+	return cached
+}
+
+type objectCacheKey struct {
+	schema.GroupKind
+	client.ObjectKey
+	metadataOnly bool
+}
+
+// cachingClient serves Gets of unstructured objects and object metadata from a short-lived cache, for contexts marked
+// with withCachedReads. Writing an object through it, other than as a dry-run, evicts that object, and writing a CRD or
+// APIService also evicts the cached discovery of listable resources
+type cachingClient struct {
+	client.Client
+
+	objects   *ttlCache[objectCacheKey, client.Object]
+	resources *ttlCache[struct{}, []listableResource]
+}
+
+func newCachingClient(c client.Client, ttl time.Duration) *cachingClient {
+	return &cachingClient{
+		Client:    c,
+		objects:   newTTLCache[objectCacheKey, client.Object](ttl),
+		resources: newTTLCache[struct{}, []listableResource](ttl),
+	}
+}
+
+func (c *cachingClient) Get(ctx context.Context, key client.ObjectKey, obj client.Object, opts ...client.GetOption) error {
+	if !cachedReadsFrom(ctx) {
+		return c.Client.Get(ctx, key, obj, opts...)
+	}
+
+	gvk := obj.GetObjectKind().GroupVersionKind()
+	cacheKey := objectCacheKey{GroupKind: gvk.GroupKind(), ObjectKey: key}
+
+	switch target := obj.(type) {
+	case *unstructured.Unstructured:
+		if cached, ok := c.objects.get(cacheKey); ok && cached.GetObjectKind().GroupVersionKind() == gvk {
+			target.Object = cached.(*unstructured.Unstructured).DeepCopy().Object
+			return nil
+		}
+	case *metav1.PartialObjectMetadata:
+		cacheKey.metadataOnly = true
+		if cached, ok := c.objects.get(cacheKey); ok && cached.GetObjectKind().GroupVersionKind() == gvk {
+			cached.(*metav1.PartialObjectMetadata).DeepCopyInto(target)
+			return nil
+		}
+	default:
+		return c.Client.Get(ctx, key, obj, opts...)
+	}
+
+	if err := c.Client.Get(ctx, key, obj, opts...); err != nil {
+		return err
+	}
+	c.objects.set(cacheKey, obj.DeepCopyObject().(client.Object))
+	return nil
+}
+
+func (c *cachingClient) Create(ctx context.Context, obj client.Object, opts ...client.CreateOption) error {
+	createOpts := &client.CreateOptions{}
+	createOpts.ApplyOptions(opts)
+	err := c.Client.Create(ctx, obj, opts...)
+	if len(createOpts.DryRun) == 0 {
+		c.evict(obj)
+	}
+	return err
+}
+
+func (c *cachingClient) Update(ctx context.Context, obj client.Object, opts ...client.UpdateOption) error {
+	updateOpts := &client.UpdateOptions{}
+	updateOpts.ApplyOptions(opts)
+	err := c.Client.Update(ctx, obj, opts...)
+	if len(updateOpts.DryRun) == 0 {
+		c.evict(obj)
+	}
+	return err
+}
+
+func (c *cachingClient) Patch(ctx context.Context, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+	patchOpts := &client.PatchOptions{}
+	patchOpts.ApplyOptions(opts)
+	err := c.Client.Patch(ctx, obj, patch, opts...)
+	if len(patchOpts.DryRun) == 0 {
+		c.evict(obj)
+	}
+	return err
+}
+
+func (c *cachingClient) Delete(ctx context.Context, obj client.Object, opts ...client.DeleteOption) error {
+	deleteOpts := &client.DeleteOptions{}
+	deleteOpts.ApplyOptions(opts)
+	err := c.Client.Delete(ctx, obj, opts...)
+	if len(deleteOpts.DryRun) == 0 {
+		c.evict(obj)
+	}
+	return err
+}
+
+func (c *cachingClient) evict(obj client.Object) {
+	gk := obj.GetObjectKind().GroupVersionKind().GroupKind()
+	key := client.ObjectKeyFromObject(obj)
+	c.objects.deleteFunc(func(k objectCacheKey) bool { return k.GroupKind == gk && k.ObjectKey == key })
+
+	if gk == (schema.GroupKind{Group: "apiextensions.k8s.io", Kind: "CustomResourceDefinition"}) ||
+		gk == (schema.GroupKind{Group: "apiregistration.k8s.io", Kind: "APIService"}) {
+		c.resources.deleteFunc(func(struct{}) bool { return true })
+	}
+}
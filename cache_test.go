@@ -0,0 +1,67 @@
+package goply
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+type countingClient struct {
+	client.Client
+	gets int
+}
+
+func (c *countingClient) Get(ctx context.Context, key client.ObjectKey, obj client.Object, opts ...client.GetOption) error {
+	c.gets++
+	return c.Client.Get(ctx, key, obj, opts...)
+}
+
+func TestCachingClient(t *testing.T) {
+	cm := &unstructured.Unstructured{}
+	cm.SetAPIVersion("v1")
+	cm.SetKind("ConfigMap")
+	cm.SetNamespace("goply-test")
+	cm.SetName("config-one")
+
+	counting := &countingClient{Client: fake.NewClientBuilder().WithObjects(cm.DeepCopy()).Build()}
+	cache := newCachingClient(counting, time.Minute)
+
+	get := func(ctx context.Context) {
+		t.Helper()
+		obj := &unstructured.Unstructured{}
+		obj.SetGroupVersionKind(cm.GroupVersionKind())
+		require.NoError(t, cache.Get(ctx, client.ObjectKeyFromObject(cm), obj))
+		require.Equal(t, "config-one", obj.GetName())
+	}
+
+	cached := withCachedReads(context.Background())
+	get(cached)
+	get(cached)
+	require.Equal(t, 1, counting.gets)
+
+	// Unmarked reads always go to the server
+	get(context.Background())
+	require.Equal(t, 2, counting.gets)
+
+	// Dry-runs leave the cache alone, real writes evict
+	require.NoError(t, cache.Update(cached, cm.DeepCopy(), client.DryRunAll))
+	get(cached)
+	require.Equal(t, 2, counting.gets)
+
+	require.NoError(t, cache.Update(cached, cm.DeepCopy()))
+	get(cached)
+	require.Equal(t, 3, counting.gets)
+}
+
+func TestTTLCacheExpiry(t *testing.T) {
+	cache := newTTLCache[string, int](time.Millisecond)
+	cache.set("one", 1)
+	time.Sleep(5 * time.Millisecond)
+	_, ok := cache.get("one")
+	require.False(t, ok)
+}
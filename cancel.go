@@ -0,0 +1,35 @@
+package goply
+
+import (
+	"context"
+	"fmt"
+)
+
+// CancelledError is returned when a reconcile's context is cancelled or times out. goply only stops at checkpoints:
+// before each stage, between dependency levels, and between objects when applying them one at a time, so it never
+// leaves an apply half done. Stage is the stage that was about to start or was in progress, and Next, if known, the
+// object that was about to be applied. The Result returned alongside it covers everything done before stopping
+type CancelledError struct {
+	Stage string
+	Next  *InventoryItem
+	err   error
+}
+
+func (e *CancelledError) Error() string {
+	if e.Next == nil {
+		return fmt.Sprintf("reconcile cancelled at stage %v: %v", e.Stage, e.err)
+	}
+	return fmt.Sprintf("reconcile cancelled at stage %v, before %v: %v", e.Stage, e.Next.ID(), e.err)
+}
+
+func (e *CancelledError) Unwrap() error {
+	return e.err
+}
+
+// checkpoint returns a CancelledError if ctx is done, next being the object about to be applied, if any
+func checkpoint(ctx context.Context, next *InventoryItem) error {
+	if ctx.Err() == nil {
+		return nil
+	}
+	return &CancelledError{Stage: stageFrom(ctx), Next: next, err: context.Cause(ctx)}
+}
@@ -0,0 +1,52 @@
+package goply
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/lithammer/dedent"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCancelledBeforeStageOne(t *testing.T) {
+	r := offlineReconciler(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	result, err := r.ReconcileContext(ctx, dedent.Dedent(`
+		---
+		apiVersion: v1
+		kind: ConfigMap
+		metadata:
+		  name: config
+		  namespace: default
+	`), ApplyOpts{}, nil)
+
+	var cancelled *CancelledError
+	require.ErrorAs(t, err, &cancelled)
+	require.Equal(t, StageOne, cancelled.Stage)
+	require.Nil(t, cancelled.Next)
+	require.ErrorIs(t, err, context.Canceled)
+	require.NotEmpty(t, result.ReconcileID)
+	require.Empty(t, result.Changes)
+}
+
+func TestCheckpoint(t *testing.T) {
+	require.NoError(t, checkpoint(context.Background(), nil))
+
+	ctx, cancel := context.WithCancelCause(withStage(context.Background(), StageTwo))
+	cause := errors.New("shutting down")
+	cancel(cause)
+
+	item := InventoryItem{}
+	item.GroupKind.Kind, item.Name, item.Namespace = "ConfigMap", "config", "default"
+	err := checkpoint(ctx, &item)
+
+	var cancelled *CancelledError
+	require.ErrorAs(t, err, &cancelled)
+	require.Equal(t, StageTwo, cancelled.Stage)
+	require.Equal(t, &item, cancelled.Next)
+	require.ErrorIs(t, err, cause)
+}
@@ -0,0 +1,247 @@
+package goply
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fluxcd/pkg/ssa"
+	ssautils "github.com/fluxcd/pkg/ssa/utils"
+	"github.com/samber/lo"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/structured-merge-diff/v4/fieldpath"
+)
+
+var (
+	ErrConflictNotForcedError = errors.New("conflict not forced")
+)
+
+type ConflictPolicy string
+
+const (
+	// ConflictPolicyForce takes ownership of any conflicting fields, except on objects a ConflictResolver declines to
+	// force, which are left unchanged as with ConflictPolicyWarn. The resource manager has always applied with forced
+	// ownership, so this is also what the zero value does
+	ConflictPolicyForce ConflictPolicy = "Force"
+	// ConflictPolicyFail fails the reconcile on the first conflict
+	ConflictPolicyFail ConflictPolicy = "Fail"
+	// ConflictPolicyWarn leaves conflicted objects untouched, emits a warning event for each of them, and carries on
+	// with the rest of the reconcile
+	ConflictPolicyWarn ConflictPolicy = "Warn"
+)
+
+func (p ConflictPolicy) forces() bool {
+	return p == "" || p == ConflictPolicyForce
+}
+
+// ConflictResolver is consulted for each object whose apply would conflict with fields owned by another field manager,
+// and decides whether goply should take ownership of those fields. An object it won't force fails the reconcile under
+// ConflictPolicyFail, and is otherwise left unchanged and reported in Result.Skipped
+type ConflictResolver func(obj *unstructured.Unstructured, conflicts []string) (force bool)
+
+// Conflict is a single field goply wants to set that is currently owned by one or more other field managers
+type Conflict struct {
+	Field    string
+	Managers []string
+}
+
+func (c Conflict) String() string {
+	if len(c.Managers) == 0 {
+		return c.Field
+	}
+	return fmt.Sprintf("%v (owned by %v)", c.Field, strings.Join(lo.Map(c.Managers, func(m string, _ int) string { return strconv.Quote(m) }), ", "))
+}
+
+func formatConflicts(conflicts []Conflict) string {
+	return strings.Join(lo.Map(conflicts, func(c Conflict, _ int) string { return c.String() }), ", ")
+}
+
+// resolveConflicts returns the objects that should go on to be applied. Conflicts on objects being adopted are always
+// forced. Otherwise a ConflictResolver, if set, decides whether each conflict is forced; anything left unforced only
+// fails the reconcile under ConflictPolicyFail, and is skipped otherwise
+func (r *Reconciler) resolveConflicts(ctx context.Context, objects []*unstructured.Unstructured, adopting []*unstructured.Unstructured, opts ApplyOpts, result *Result) ([]*unstructured.Unstructured, error) {
+	toApply := []*unstructured.Unstructured{}
+
+	for _, obj := range objects {
+		conflicts, err := r.dryRunConflicts(ctx, obj)
+		if err != nil {
+			return nil, err
+		}
+		if len(conflicts) == 0 {
+			toApply = append(toApply, obj)
+			continue
+		}
+
+		if lo.Contains(adopting, obj) {
+			r.log(ctx, "adopting object, forcing ownership of conflicting fields", append(objectKV(obj), "conflicts", formatConflicts(conflicts))...)
+			toApply = append(toApply, obj)
+			continue
+		}
+
+		force := opts.ConflictPolicy.forces()
+		if opts.ConflictResolver != nil {
+			force = opts.ConflictResolver(obj, lo.Map(conflicts, func(c Conflict, _ int) string { return c.Field }))
+		}
+
+		if force {
+			r.log(ctx, "forcing ownership of conflicting fields", append(objectKV(obj), "conflicts", formatConflicts(conflicts))...)
+			toApply = append(toApply, obj)
+			continue
+		}
+
+		conflicts, err = r.retryConflicts(ctx, obj, conflicts, opts)
+		if err != nil {
+			return nil, err
+		}
+		if len(conflicts) == 0 {
+			toApply = append(toApply, obj)
+			continue
+		}
+
+		if opts.ConflictPolicy == ConflictPolicyFail {
+			return nil, fmt.Errorf(
+				"%v has conflicting fields [%v]%v: %w",
+				ssautils.FmtUnstructured(obj), formatConflicts(conflicts), retriesSuffix(opts), ErrConflictNotForcedError,
+			)
+		}
+
+		item := toInventoryItem(obj)
+		r.event(ctx, Event{
+			Type:    EventTypeWarning,
+			Reason:  EventReasonConflict,
+			Object:  &item,
+			Message: fmt.Sprintf("leaving %v unchanged due to conflicting fields [%v]%v", ssautils.FmtUnstructured(obj), formatConflicts(conflicts), retriesSuffix(opts)),
+		})
+		result.Skipped = append(result.Skipped, SkippedItem{
+			InventoryItem: item,
+			Reason:        SkipReasonConflict,
+		})
+	}
+
+	return toApply, nil
+}
+
+// retryConflicts re-checks an object for conflicts up to ConflictRetries times, doubling the wait in between each time,
+// since conflicts are often just two controllers racing each other. It returns the conflicts still present at the end
+func (r *Reconciler) retryConflicts(ctx context.Context, obj *unstructured.Unstructured, conflicts []Conflict, opts ApplyOpts) ([]Conflict, error) {
+	backoff := opts.ConflictRetryBackoff
+	if backoff <= 0 {
+		backoff = time.Second
+	}
+
+	for attempt := 1; attempt <= opts.ConflictRetries && len(conflicts) > 0; attempt++ {
+		r.log(ctx, "object has conflicting fields, retrying", append(
+			objectKV(obj),
+			"conflicts", formatConflicts(conflicts), "backoff", backoff, "attempt", attempt, "retries", opts.ConflictRetries,
+		)...)
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		backoff *= 2
+
+		var err error
+		conflicts, err = r.dryRunConflicts(ctx, obj)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return conflicts, nil
+}
+
+func retriesSuffix(opts ApplyOpts) string {
+	if opts.ConflictRetries == 0 {
+		return ""
+	}
+	return fmt.Sprintf(" after %v retries", opts.ConflictRetries)
+}
+
+// dryRunConflicts performs a server-side dry-run apply *without* forcing ownership, which is the only way to find out
+// about conflicts since the resource manager always forces
+func (r *Reconciler) dryRunConflicts(ctx context.Context, obj *unstructured.Unstructured) ([]Conflict, error) {
+	err := r.mgr.Client().Patch(ctx, obj.DeepCopy(), client.Apply, client.DryRunAll, client.FieldOwner(fieldManager))
+	if err == nil || !apierrors.IsConflict(err) {
+		// Any other failure will be reported by the real apply
+		return nil, nil
+	}
+
+	conflicts := conflictsFromError(err)
+
+	// The managed fields are the authoritative record of who owns what, the cause messages are only a fallback
+	live := &unstructured.Unstructured{}
+	live.SetGroupVersionKind(obj.GroupVersionKind())
+	if err := r.mgr.Client().Get(ctx, client.ObjectKeyFromObject(obj), live); err == nil {
+		owners := fieldOwners(live.GetManagedFields())
+		for i := range conflicts {
+			if managers, ok := owners[conflicts[i].Field]; ok {
+				conflicts[i].Managers = managers
+			}
+		}
+	}
+
+	return conflicts, nil
+}
+
+var conflictManagerRegex = regexp.MustCompile(`conflict with "([^"]+)"`)
+
+func conflictsFromError(err error) []Conflict {
+	var status apierrors.APIStatus
+	if !errors.As(err, &status) {
+		return []Conflict{}
+	}
+
+	details := status.Status().Details
+	if details == nil {
+		return []Conflict{}
+	}
+
+	conflicts := []Conflict{}
+	for _, cause := range details.Causes {
+		if cause.Type != metav1.CauseTypeFieldManagerConflict {
+			continue
+		}
+
+		conflict := Conflict{Field: cause.Field, Managers: []string{}}
+		if match := conflictManagerRegex.FindStringSubmatch(cause.Message); match != nil {
+			conflict.Managers = append(conflict.Managers, match[1])
+		}
+		conflicts = append(conflicts, conflict)
+	}
+
+	return conflicts
+}
+
+// fieldOwners maps each field path, formatted the same way the API server formats conflict causes, to every manager
+// other than goply that owns it
+func fieldOwners(entries []metav1.ManagedFieldsEntry) map[string][]string {
+	owners := map[string][]string{}
+
+	for _, entry := range entries {
+		if entry.Manager == fieldManager || entry.FieldsV1 == nil {
+			continue
+		}
+
+		fields, err := ssa.FieldsToSet(*entry.FieldsV1)
+		if err != nil {
+			continue
+		}
+
+		fields.Iterate(func(p fieldpath.Path) {
+			path := p.String()
+			if !lo.Contains(owners[path], entry.Manager) {
+				owners[path] = append(owners[path], entry.Manager)
+			}
+		})
+	}
+
+	return owners
+}
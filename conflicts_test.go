@@ -0,0 +1,78 @@
+package goply
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestConflictsFromError(t *testing.T) {
+	statusErr := &apierrors.StatusError{ErrStatus: metav1.Status{
+		Status: metav1.StatusFailure,
+		Code:   409,
+		Reason: metav1.StatusReasonConflict,
+		Details: &metav1.StatusDetails{
+			Causes: []metav1.StatusCause{
+				{Type: metav1.CauseTypeFieldManagerConflict, Field: ".data.foo", Message: `conflict with "kubectl"`},
+				{Type: metav1.CauseTypeFieldValueInvalid, Field: ".data.bar"},
+				{Type: metav1.CauseTypeFieldManagerConflict, Field: ".data.baz", Message: `conflict with "helm"`},
+			},
+		},
+	}}
+
+	expected := []Conflict{
+		{Field: ".data.foo", Managers: []string{"kubectl"}},
+		{Field: ".data.baz", Managers: []string{"helm"}},
+	}
+
+	t.Run("status error", func(t *testing.T) {
+		require.Equal(t, expected, conflictsFromError(statusErr))
+	})
+
+	t.Run("wrapped status error", func(t *testing.T) {
+		require.Equal(t, expected, conflictsFromError(fmt.Errorf("apply failed: %w", statusErr)))
+	})
+
+	t.Run("non status error", func(t *testing.T) {
+		require.Equal(t, []Conflict{}, conflictsFromError(errors.New("boom")))
+	})
+}
+
+func TestFieldOwners(t *testing.T) {
+	owners := fieldOwners([]metav1.ManagedFieldsEntry{
+		{
+			Manager:  fieldManager,
+			FieldsV1: &metav1.FieldsV1{Raw: []byte(`{"f:data":{"f:foo":{}}}`)},
+		},
+		{
+			Manager:  "someone-else",
+			FieldsV1: &metav1.FieldsV1{Raw: []byte(`{"f:data":{"f:foo":{},"f:bar":{}}}`)},
+		},
+		{
+			Manager:  "another",
+			FieldsV1: &metav1.FieldsV1{Raw: []byte(`{"f:data":{"f:bar":{}}}`)},
+		},
+	})
+
+	require.Equal(t, []string{"someone-else"}, owners[".data.foo"])
+	require.Equal(t, []string{"someone-else", "another"}, owners[".data.bar"])
+
+	require.Equal(t, `.data.bar (owned by "someone-else", "another")`, Conflict{Field: ".data.bar", Managers: owners[".data.bar"]}.String())
+}
+
+func TestAppliedByGoply(t *testing.T) {
+	require.False(t, appliedByGoply(nil))
+	require.False(t, appliedByGoply([]metav1.ManagedFieldsEntry{
+		{Manager: "kubectl-create", Operation: metav1.ManagedFieldsOperationUpdate},
+		// An update under goply's name, such as a metadata patch, isn't an apply
+		{Manager: fieldManager, Operation: metav1.ManagedFieldsOperationUpdate},
+	}))
+	require.True(t, appliedByGoply([]metav1.ManagedFieldsEntry{
+		{Manager: "kubectl-create", Operation: metav1.ManagedFieldsOperationUpdate},
+		{Manager: fieldManager, Operation: metav1.ManagedFieldsOperationApply},
+	}))
+}
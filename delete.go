@@ -0,0 +1,287 @@
+package goply
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/fluxcd/pkg/ssa"
+	ssautils "github.com/fluxcd/pkg/ssa/utils"
+	"github.com/samber/lo"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+type DeleteOpts struct {
+	WaitTimeout *time.Duration
+	SkipWait    bool
+	// KindPolicies overrides how objects of specific kinds are deleted. Kinds without an entry are deleted with
+	// foreground propagation and their default grace period
+	KindPolicies map[schema.GroupKind]DeletePolicy
+	// DryRun performs a server-side dry-run of the deletion, nothing is actually removed and there is no wait for
+	// termination
+	DryRun bool
+	// RequireConfirmation refuses the delete, before anything is touched, unless Confirm is set or ConfirmationToken
+	// matches the ConfirmationToken of exactly the objects being deleted. Dry-runs are always allowed, which makes them
+	// a way of finding out what would be deleted and the token to confirm it with
+	RequireConfirmation bool
+	// AllowCRDDeletion permits deleting CustomResourceDefinitions, which takes every custom resource of that kind with
+	// it. Without it, a delete including any CRD is refused before anything is touched
+	AllowCRDDeletion  bool
+	Confirm           bool
+	ConfirmationToken string
+}
+
+var (
+	ErrDeleteNotConfirmedError = errors.New("delete not confirmed")
+	ErrCRDDeletionError        = errors.New("refusing to delete CustomResourceDefinitions")
+)
+
+// checkCRDDeletion refuses to delete any CRD unless allowed
+func checkCRDDeletion(items []*unstructured.Unstructured, allowed bool) error {
+	if allowed {
+		return nil
+	}
+	crds := lo.Filter(items, func(u *unstructured.Unstructured, _ int) bool { return ssautils.IsCRD(u) })
+	if len(crds) == 0 {
+		return nil
+	}
+	return fmt.Errorf(
+		"%w, set AllowCRDDeletion to delete them: [%v]",
+		ErrCRDDeletionError,
+		strings.Join(lo.Map(crds, func(u *unstructured.Unstructured, _ int) string { return u.GetName() }), ", "),
+	)
+}
+
+// ConfirmationToken returns the token that confirms a delete of exactly items, whatever order they're in
+func ConfirmationToken(items []InventoryItem) string {
+	ids := lo.Uniq(lo.Map(items, func(i InventoryItem, _ int) string { return i.ID() }))
+	sort.Strings(ids)
+	sum := sha256.Sum256([]byte(strings.Join(ids, "\n")))
+	return hex.EncodeToString(sum[:])[:12]
+}
+
+type DeletePolicy struct {
+	PropagationPolicy  metav1.DeletionPropagation
+	GracePeriodSeconds *int64
+}
+
+// DeleteResult reports what a delete did, or for a dry-run what it would have done. Every requested object ends up in
+// exactly one of Deleted, Absent, or Failed
+type DeleteResult struct {
+	Requested []InventoryItem
+	Deleted   []InventoryItem
+	// Absent objects were already gone from the cluster, or their kind no longer exists. They don't cause the delete to
+	// fail, so re-running a teardown is safe
+	Absent []InventoryItem
+	Failed []DeleteFailure
+}
+
+// DeleteFailure is an object that couldn't be deleted, and why
+type DeleteFailure struct {
+	InventoryItem
+	Err error
+}
+
+// String summarizes the counts of the result, e.g. "requested 3, deleted 2, absent 1, failed 0"
+func (d DeleteResult) String() string {
+	return fmt.Sprintf(
+		"requested %v, deleted %v, absent %v, failed %v",
+		len(d.Requested), len(d.Deleted), len(d.Absent), len(d.Failed),
+	)
+}
+
+func (r *Reconciler) delete(ctx context.Context, items []*unstructured.Unstructured, opts DeleteOpts) (DeleteResult, error) {
+	if opts.WaitTimeout == nil {
+		opts.WaitTimeout = ptr(DefaultTimeout)
+	}
+
+	if err := checkCRDDeletion(items, opts.AllowCRDDeletion); err != nil {
+		return DeleteResult{}, err
+	}
+
+	if opts.RequireConfirmation && !opts.Confirm && !opts.DryRun {
+		if token := ConfirmationToken(toInventoryItems(items)); opts.ConfirmationToken != token {
+			return DeleteResult{}, fmt.Errorf(
+				"%w: deleting %v objects requires Confirm, or ConfirmationToken %v",
+				ErrDeleteNotConfirmedError, len(items), token,
+			)
+		}
+	}
+
+	if opts.DryRun {
+		r.log(ctx, "beginning dry-run delete of resources")
+	} else {
+		r.log(ctx, "beginning delete of resources")
+	}
+	result, err := r.deleteObjects(ctx, items, opts)
+	if err != nil {
+		return result, fmt.Errorf("error during deletion: %w", err)
+	}
+
+	if !opts.SkipWait && !opts.DryRun {
+		r.log(ctx, "waiting for resources to terminate")
+		err = r.mgr.WaitForTermination(items, ssa.WaitOptions{
+			Interval: 2 * time.Second,
+			Timeout:  *opts.WaitTimeout,
+		})
+		if err != nil {
+			return result, fmt.Errorf("error waiting for resources to terminate: %w", err)
+		}
+	}
+
+	return result, nil
+}
+
+// deleteObjects mirrors ssa.ResourceManager.DeleteAll, but applies any per-kind propagation/grace period overrides,
+// supports dry-runs, tells apart objects that were actually deleted from those that were already gone, and orders the
+// deletes by the live objects' ownerReferences, see deleteOrder
+func (r *Reconciler) deleteObjects(ctx context.Context, items []*unstructured.Unstructured, opts DeleteOpts) (DeleteResult, error) {
+	sorted := append([]*unstructured.Unstructured{}, items...)
+	sort.Sort(sort.Reverse(ssa.SortableUnstructureds(sorted)))
+
+	result := DeleteResult{Requested: toInventoryItems(sorted)}
+	var errs []string
+	fail := func(obj *unstructured.Unstructured, err error) {
+		result.Failed = append(result.Failed, DeleteFailure{InventoryItem: toInventoryItem(obj), Err: err})
+	}
+
+	live := []*unstructured.Unstructured{}
+	for _, obj := range sorted {
+		existing := &unstructured.Unstructured{}
+		existing.SetGroupVersionKind(obj.GroupVersionKind())
+		err := r.mgr.Client().Get(ctx, client.ObjectKeyFromObject(obj), existing)
+		if apierrors.IsNotFound(err) || meta.IsNoMatchError(err) {
+			result.Absent = append(result.Absent, toInventoryItem(obj))
+			continue
+		}
+		if err != nil {
+			fail(obj, err)
+			errs = append(errs, fmt.Sprintf("%v query failed: %v", ssautils.FmtUnstructured(obj), err))
+			continue
+		}
+		live = append(live, existing)
+	}
+
+	for _, existing := range deleteOrder(live) {
+		propagation := metav1.DeletePropagationForeground
+		deleteOpts := []client.DeleteOption{}
+		if policy, ok := opts.KindPolicies[existing.GroupVersionKind().GroupKind()]; ok {
+			if policy.PropagationPolicy != "" {
+				propagation = policy.PropagationPolicy
+			}
+			if policy.GracePeriodSeconds != nil {
+				deleteOpts = append(deleteOpts, client.GracePeriodSeconds(*policy.GracePeriodSeconds))
+			}
+		}
+		deleteOpts = append(deleteOpts, client.PropagationPolicy(propagation))
+		if opts.DryRun {
+			deleteOpts = append(deleteOpts, client.DryRunAll)
+		}
+
+		err := r.mgr.Client().Delete(ctx, existing, deleteOpts...)
+		switch {
+		case apierrors.IsNotFound(err):
+			result.Absent = append(result.Absent, toInventoryItem(existing))
+		case err != nil:
+			fail(existing, err)
+			errs = append(errs, fmt.Sprintf("%v delete failed: %v", ssautils.FmtUnstructured(existing), err))
+		default:
+			result.Deleted = append(result.Deleted, toInventoryItem(existing))
+		}
+	}
+
+	if len(errs) > 0 {
+		return result, fmt.Errorf("delete failed, errors: %v", strings.Join(errs, "; "))
+	}
+
+	return result, nil
+}
+
+// deleteOrder reorders live objects by the ownerReferences between them. A child is deleted before an owner that
+// merely references it, so it's never left behind as an orphan. A child whose owner is its controller (a Deployment's
+// ReplicaSets, say) goes after its owner instead, since deleting it first only has the controller recreate it, and the
+// owner's foreground deletion takes it down anyway. Otherwise objects keep their order, and a reference cycle is broken
+// wherever it's first reached
+func deleteOrder(live []*unstructured.Unstructured) []*unstructured.Unstructured {
+	byUID := make(map[types.UID]int, len(live))
+	for idx, obj := range live {
+		if obj.GetUID() != "" {
+			byUID[obj.GetUID()] = idx
+		}
+	}
+
+	// before[i] holds the objects that have to be deleted before live[i]
+	before := make([][]int, len(live))
+	for child, obj := range live {
+		for _, ref := range obj.GetOwnerReferences() {
+			owner, ok := byUID[ref.UID]
+			if !ok || owner == child {
+				continue
+			}
+			if ref.Controller != nil && *ref.Controller {
+				before[child] = append(before[child], owner)
+			} else {
+				before[owner] = append(before[owner], child)
+			}
+		}
+	}
+
+	ordered := make([]*unstructured.Unstructured, 0, len(live))
+	visited := make([]bool, len(live))
+	var visit func(idx int)
+	visit = func(idx int) {
+		if visited[idx] {
+			return
+		}
+		visited[idx] = true
+		for _, dep := range before[idx] {
+			visit(dep)
+		}
+		ordered = append(ordered, live[idx])
+	}
+	for idx := range live {
+		visit(idx)
+	}
+
+	return ordered
+}
+
+func (r *Reconciler) Delete(yaml string, opts DeleteOpts) (DeleteResult, error) {
+	allObjects, err := GetObjects(yaml)
+	if err != nil {
+		return DeleteResult{}, fmt.Errorf("error decoding yaml to unstructured: %w", err)
+	}
+
+	return r.delete(context.TODO(), allObjects, opts)
+}
+
+// DeleteInventory deletes everything tracked by inv
+func (r *Reconciler) DeleteInventory(ctx context.Context, inv Inventory, opts DeleteOpts) (DeleteResult, error) {
+	return r.DeleteInventoryFiltered(ctx, inv, func(InventoryItem) bool { return true }, opts)
+}
+
+// DeleteInventoryFiltered deletes only the items of inv for which predicate returns true
+func (r *Reconciler) DeleteInventoryFiltered(ctx context.Context, inv Inventory, predicate func(InventoryItem) bool, opts DeleteOpts) (DeleteResult, error) {
+	toDelete := lo.FilterMap(inv.Items, func(i InventoryItem, _ int) (*unstructured.Unstructured, bool) {
+		if !predicate(i) {
+			return nil, false
+		}
+		return i.stub(), true
+	})
+	if len(toDelete) == 0 {
+		return DeleteResult{}, nil
+	}
+
+	return r.delete(ctx, toDelete, opts)
+}
@@ -0,0 +1,75 @@
+package goply
+
+import (
+	"context"
+	"fmt"
+
+	ssautils "github.com/fluxcd/pkg/ssa/utils"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// DryRunError is returned by an AtomicDryRunFirst reconcile when the server refuses the dry-run of an object, before
+// anything has been applied. If an admission webhook refused it, it wraps a WebhookRejectionError
+type DryRunError struct {
+	Object InventoryItem
+	err    error
+}
+
+func (e *DryRunError) Error() string { return e.err.Error() }
+func (e *DryRunError) Unwrap() error { return e.err }
+
+// dryRunAll performs a server-side dry-run apply of every object, stopping at the first one the server refuses.
+// Ownership is forced, conflicts are left to the real apply and its ConflictPolicy. Objects in a namespace, or of a
+// kind, that this same manifest creates can't be dry-run until it exists, so those are let through unchecked, as are
+// missing kinds when they're going to be skipped anyway
+func (r *Reconciler) dryRunAll(ctx context.Context, objects []*unstructured.Unstructured, opts ApplyOpts) error {
+	pendingNamespaces := newSet[string]()
+	pendingKinds := newSet[schema.GroupKind]()
+	for _, obj := range objects {
+		switch {
+		case ssautils.IsNamespace(obj):
+			pendingNamespaces.Add(obj.GetName())
+		case ssautils.IsCRD(obj):
+			if gk, ok := crdGroupKind(obj); ok {
+				pendingKinds.Add(gk)
+			}
+		}
+	}
+
+	for _, obj := range objects {
+		err := r.mgr.Client().Patch(ctx, obj.DeepCopy(), client.Apply, client.DryRunAll, client.ForceOwnership, client.FieldOwner(fieldManager))
+		if err == nil {
+			continue
+		}
+
+		gk := obj.GroupVersionKind().GroupKind()
+		switch {
+		case apierrors.IsNotFound(err) && pendingNamespaces.Contains(obj.GetNamespace()):
+			continue
+		case meta.IsNoMatchError(err) && (pendingKinds.Contains(gk) || opts.SkipMissingKinds):
+			continue
+		}
+
+		item := toInventoryItem(obj)
+		if rejection := asWebhookRejection(err); rejection != nil {
+			rejection.Object = &item
+			r.event(ctx, Event{
+				Type:    EventTypeWarning,
+				Reason:  EventReasonWebhookRejected,
+				Object:  rejection.Object,
+				Message: rejection.Error(),
+			})
+			err = rejection
+		}
+		return &DryRunError{
+			Object: item,
+			err:    fmt.Errorf("dry-run of %v failed, nothing was applied: %w", ssautils.FmtUnstructured(obj), err),
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,83 @@
+package goply
+
+import (
+	ssaerrors "github.com/fluxcd/pkg/ssa/errors"
+)
+
+// The typed errors below mark where in a reconcile something failed, so callers can branch with errors.As rather than
+// matching on messages. Each keeps the message of the error it wraps, and unwraps to it, so the sentinel errors and
+// apimachinery helpers keep working through them. A failed reconcile is typically a StageError wrapping one of the more
+// specific types, e.g. an ApplyError or WaitTimeoutError
+
+// ParseError is returned when a manifest can't be decoded into objects
+type ParseError struct {
+	err error
+}
+
+func (e *ParseError) Error() string { return e.err.Error() }
+func (e *ParseError) Unwrap() error { return e.err }
+
+// StageError is returned when a reconcile fails in one of its stages, Stage being one of StageOne, StageTwo or
+// StagePrune
+type StageError struct {
+	Stage string
+	err   error
+}
+
+func (e *StageError) Error() string { return e.err.Error() }
+func (e *StageError) Unwrap() error { return e.err }
+
+// ApplyError is returned when the server refuses to apply objects. Objects holds the ones that failed, as far as they
+// can be determined, and may be empty
+type ApplyError struct {
+	Objects []InventoryItem
+	err     error
+}
+
+func (e *ApplyError) Error() string { return e.err.Error() }
+func (e *ApplyError) Unwrap() error { return e.err }
+
+// PruneError is returned when pruning is refused or fails. Failed holds the objects whose deletion failed, it's empty
+// when the prune was refused before anything was deleted
+type PruneError struct {
+	Failed []DeleteFailure
+	err    error
+}
+
+func (e *PruneError) Error() string { return e.err.Error() }
+func (e *PruneError) Unwrap() error { return e.err }
+
+// newApplyError wraps err as an ApplyError, gathering up the objects involved from anywhere in it
+func newApplyError(err error) *ApplyError {
+	if applyErr, ok := err.(*ApplyError); ok {
+		return applyErr
+	}
+	return &ApplyError{Objects: involvedObjects(err), err: err}
+}
+
+func involvedObjects(err error) []InventoryItem {
+	switch e := err.(type) {
+	case *ApplyError:
+		return e.Objects
+	case *WebhookRejectionError:
+		if e.Object != nil {
+			return []InventoryItem{*e.Object}
+		}
+	case *ssaerrors.DryRunErr:
+		if obj := e.InvolvedObject(); obj != nil {
+			return []InventoryItem{toInventoryItem(obj)}
+		}
+	}
+
+	switch e := err.(type) {
+	case interface{ Unwrap() []error }:
+		items := []InventoryItem{}
+		for _, err := range e.Unwrap() {
+			items = append(items, involvedObjects(err)...)
+		}
+		return items
+	case interface{ Unwrap() error }:
+		return involvedObjects(e.Unwrap())
+	}
+	return nil
+}
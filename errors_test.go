@@ -0,0 +1,55 @@
+package goply
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	ssaerrors "github.com/fluxcd/pkg/ssa/errors"
+	"github.com/lithammer/dedent"
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTypedErrors(t *testing.T) {
+	_, err := GetObjects("apiVersion: v1\nkind: [")
+	var parseErr *ParseError
+	require.ErrorAs(t, err, &parseErr)
+
+	objs, err := GetObjects(dedent.Dedent(`
+		---
+		apiVersion: v1
+		kind: ConfigMap
+		metadata:
+		  name: config-one
+		  namespace: goply-test
+		---
+		apiVersion: v1
+		kind: ConfigMap
+		metadata:
+		  name: config-two
+		  namespace: goply-test
+	`)[1:])
+	require.NoError(t, err)
+
+	t.Run("apply", func(t *testing.T) {
+		// As applyConcurrently would return it, one object failing on its own and another failing the dry-run
+		joined := errors.Join(
+			&ApplyError{Objects: []InventoryItem{toInventoryItem(objs[0])}, err: errors.New("connection refused")},
+			fmt.Errorf("apply failed: %w", ssaerrors.NewDryRunErr(errors.New("invalid"), objs[1])),
+		)
+		applyErr := newApplyError(joined)
+		require.Equal(t, []string{"config-one", "config-two"}, lo.Map(applyErr.Objects, func(i InventoryItem, _ int) string { return i.Name }))
+		require.Equal(t, joined.Error(), applyErr.Error())
+		require.Same(t, applyErr, newApplyError(applyErr))
+	})
+
+	t.Run("prune", func(t *testing.T) {
+		err := offlineReconciler(t).removeItems(context.Background(), Inventory{Items: toInventoryItems(objs)}, &Result{}, ApplyOpts{MaxPrune: ptr(1)})
+		var pruneErr *PruneError
+		require.ErrorAs(t, err, &pruneErr)
+		require.ErrorIs(t, err, ErrPruneRefusedError)
+		require.Empty(t, pruneErr.Failed)
+	})
+}
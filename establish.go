@@ -0,0 +1,98 @@
+package goply
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/fluxcd/cli-utils/pkg/kstatus/status"
+	ssautils "github.com/fluxcd/pkg/ssa/utils"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// waitEstablished waits for stage one to be usable: every CRD Established and every Namespace Active. That's all stage
+// two needs, and it's much quicker to tell than full kstatus readiness
+func (r *Reconciler) waitEstablished(ctx context.Context, objects []*unstructured.Unstructured, timeout time.Duration) error {
+	if len(objects) == 0 {
+		return nil
+	}
+
+	pending := make(map[*unstructured.Unstructured]ObjectStatus, len(objects))
+	for _, obj := range objects {
+		pending[obj] = ObjectStatus{
+			ObjMetadata: toInventoryItem(obj).ObjMetadata,
+			Status:      status.UnknownStatus,
+			Message:     "can't determine status",
+		}
+	}
+
+	waitCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	err := wait.PollUntilContextCancel(waitCtx, 500*time.Millisecond, true, func(ctx context.Context) (bool, error) {
+		for obj, objStatus := range pending {
+			live := &unstructured.Unstructured{}
+			live.SetGroupVersionKind(obj.GroupVersionKind())
+			err := r.mgr.Client().Get(ctx, client.ObjectKeyFromObject(obj), live)
+			if apierrors.IsNotFound(err) {
+				objStatus.Status, objStatus.Message = status.NotFoundStatus, "not found"
+				pending[obj] = objStatus
+				continue
+			}
+			if err != nil {
+				return false, fmt.Errorf("error getting %v: %w", ssautils.FmtUnstructured(obj), err)
+			}
+
+			ready, msg := isEstablished(live)
+			if ready {
+				delete(pending, obj)
+				continue
+			}
+			objStatus.Status, objStatus.Message = status.InProgressStatus, msg
+			pending[obj] = objStatus
+		}
+		return len(pending) == 0, nil
+	})
+	if err == nil {
+		return nil
+	}
+	if !errors.Is(err, context.DeadlineExceeded) {
+		return err
+	}
+
+	notReady := make([]ObjectStatus, 0, len(pending))
+	for _, obj := range objects {
+		if objStatus, ok := pending[obj]; ok {
+			notReady = append(notReady, objStatus)
+		}
+	}
+	return &WaitTimeoutError{Objects: notReady}
+}
+
+// isEstablished reports whether a live CRD is Established or a live Namespace is Active. Anything else only needs to
+// exist
+func isEstablished(live *unstructured.Unstructured) (bool, string) {
+	switch {
+	case ssautils.IsCRD(live):
+		conditions, _, _ := unstructured.NestedSlice(live.Object, "status", "conditions")
+		for _, c := range conditions {
+			condition, ok := c.(map[string]any)
+			if ok && condition["type"] == "Established" && condition["status"] == "True" {
+				return true, ""
+			}
+		}
+		return false, "not yet established"
+	case ssautils.IsNamespace(live):
+		phase, _, _ := unstructured.NestedString(live.Object, "status", "phase")
+		if phase == "Active" {
+			return true, ""
+		}
+		return false, fmt.Sprintf("phase is %q", phase)
+	default:
+		return true, ""
+	}
+}
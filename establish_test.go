@@ -0,0 +1,44 @@
+package goply
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestIsEstablished(t *testing.T) {
+	crd := func(conditions ...any) *unstructured.Unstructured {
+		return &unstructured.Unstructured{Object: map[string]any{
+			"apiVersion": "apiextensions.k8s.io/v1",
+			"kind":       "CustomResourceDefinition",
+			"metadata":   map[string]any{"name": "widgets.example.com"},
+			"status":     map[string]any{"conditions": conditions},
+		}}
+	}
+	namespace := func(phase string) *unstructured.Unstructured {
+		return &unstructured.Unstructured{Object: map[string]any{
+			"apiVersion": "v1",
+			"kind":       "Namespace",
+			"metadata":   map[string]any{"name": "goply-test"},
+			"status":     map[string]any{"phase": phase},
+		}}
+	}
+
+	ready, _ := isEstablished(crd(
+		map[string]any{"type": "NamesAccepted", "status": "True"},
+		map[string]any{"type": "Established", "status": "True"},
+	))
+	require.True(t, ready)
+
+	ready, msg := isEstablished(crd(map[string]any{"type": "Established", "status": "False"}))
+	require.False(t, ready)
+	require.Equal(t, "not yet established", msg)
+
+	ready, _ = isEstablished(namespace("Active"))
+	require.True(t, ready)
+
+	ready, msg = isEstablished(namespace("Terminating"))
+	require.False(t, ready)
+	require.Equal(t, `phase is "Terminating"`, msg)
+}
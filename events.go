@@ -0,0 +1,85 @@
+package goply
+
+import "context"
+
+type EventType string
+
+const (
+	EventTypeNormal  EventType = "Normal"
+	EventTypeWarning EventType = "Warning"
+)
+
+const (
+	EventReasonConflict = "Conflict"
+)
+
+// Event is a notable occurrence during a reconcile, delivered to the func registered with SetEventFunc. Object is nil
+// for events that aren't about one particular object, ReconcileID is empty for events outside of a reconcile
+type Event struct {
+	Type        EventType
+	Reason      string
+	Object      *InventoryItem
+	Message     string
+	ReconcileID string
+}
+
+// EventChannelPolicy is what happens when an event can't be sent to the channel registered with SetEventChannel
+// straight away
+type EventChannelPolicy string
+
+const (
+	// EventChannelBlock waits for the consumer to receive the event, or for the operation's context to be done. This is
+	// also what the zero value does
+	EventChannelBlock EventChannelPolicy = "Block"
+	// EventChannelDrop discards the event
+	EventChannelDrop EventChannelPolicy = "Drop"
+)
+
+func (r *Reconciler) SetEventFunc(f func(Event)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.eventFunc = f
+}
+
+// SetEventChannel delivers events to ch, in addition to any func registered with SetEventFunc. policy decides what
+// happens when ch isn't ready to receive. A nil ch stops delivery. goply never closes ch
+func (r *Reconciler) SetEventChannel(ch chan<- Event, policy EventChannelPolicy) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.eventChan = ch
+	r.eventChanPolicy = policy
+}
+
+// event delivers e to the registered event func and channel, and also logs its message
+func (r *Reconciler) event(ctx context.Context, e Event) {
+	e.ReconcileID = reconcileIDFrom(ctx)
+	kv := []any{"reason", e.Reason}
+	if e.Object != nil {
+		kv = append(itemKV(*e.Object), kv...)
+	}
+	r.log(ctx, e.Message, kv...)
+
+	r.mu.RLock()
+	eventFunc := r.eventFunc
+	eventChan, policy := r.eventChan, r.eventChanPolicy
+	r.mu.RUnlock()
+
+	if eventFunc != nil {
+		eventFunc(e)
+	}
+
+	if eventChan == nil {
+		return
+	}
+	if policy == EventChannelDrop {
+		select {
+		case eventChan <- e:
+		default:
+		}
+		return
+	}
+	select {
+	case eventChan <- e:
+	case <-ctx.Done():
+	}
+}
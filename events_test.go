@@ -0,0 +1,38 @@
+package goply
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEventChannel(t *testing.T) {
+	t.Run("block", func(t *testing.T) {
+		r := offlineReconciler(t)
+		ch := make(chan Event, 1)
+		r.SetEventChannel(ch, EventChannelBlock)
+
+		r.event(withReconcileID(context.Background(), "run-one"), Event{Type: EventTypeNormal, Message: "one"})
+		e := <-ch
+		require.Equal(t, "one", e.Message)
+		require.Equal(t, "run-one", e.ReconcileID)
+
+		// A full channel doesn't hold up an operation whose context is done
+		ch <- Event{}
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		r.event(ctx, Event{Type: EventTypeNormal, Message: "two"})
+	})
+
+	t.Run("drop", func(t *testing.T) {
+		r := offlineReconciler(t)
+		ch := make(chan Event, 1)
+		r.SetEventChannel(ch, EventChannelDrop)
+
+		r.event(context.Background(), Event{Type: EventTypeNormal, Message: "one"})
+		r.event(context.Background(), Event{Type: EventTypeNormal, Message: "two"})
+		require.Equal(t, "one", (<-ch).Message)
+		require.Empty(t, ch)
+	})
+}
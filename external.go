@@ -0,0 +1,25 @@
+package goply
+
+import (
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// ExternallyManagedAnnotation, set to "true" on an object in the manifest, hands the object off to something else once
+// it's been applied: goply doesn't wait on it becoming ready, and never prunes it. It's still tracked in the inventory,
+// with InventoryItem.ExternallyManaged set
+const ExternallyManagedAnnotation = "goply.io/externally-managed"
+
+func isExternallyManaged(annotations map[string]string) bool {
+	return annotations[ExternallyManagedAnnotation] == "true"
+}
+
+// withoutExternallyManaged returns the objects goply is responsible for waiting on
+func withoutExternallyManaged(objects []*unstructured.Unstructured) []*unstructured.Unstructured {
+	managed := make([]*unstructured.Unstructured, 0, len(objects))
+	for _, obj := range objects {
+		if !isExternallyManaged(obj.GetAnnotations()) {
+			managed = append(managed, obj)
+		}
+	}
+	return managed
+}
@@ -0,0 +1,81 @@
+package goply
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	ssautils "github.com/fluxcd/pkg/ssa/utils"
+	"github.com/samber/lo"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// relinquish drops goply's field ownership of every applied object opts.RelinquishAfterApply selects, recording them
+// in Result.HandedOff. They're marked ExternallyManaged in the inventory, so dropping them from the manifest afterwards
+// doesn't prune them
+func (r *Reconciler) relinquish(ctx context.Context, objects []*unstructured.Unstructured, opts ApplyOpts, result *Result) error {
+	for _, obj := range objects {
+		if !opts.RelinquishAfterApply(obj) {
+			continue
+		}
+
+		dropped, err := r.dropManagedFields(ctx, obj, func(entry metav1.ManagedFieldsEntry) bool {
+			return entry.Manager == fieldManager
+		})
+		if err != nil {
+			return err
+		}
+		if !dropped {
+			continue
+		}
+
+		r.log(ctx, "relinquished field ownership", objectKV(obj)...)
+		item := toInventoryItem(obj)
+		item.ExternallyManaged = true
+		result.HandedOff = append(result.HandedOff, item)
+		for idx := range result.Inventory.Items {
+			if result.Inventory.Items[idx].ObjMetadata == item.ObjMetadata {
+				result.Inventory.Items[idx].ExternallyManaged = true
+			}
+		}
+	}
+	return nil
+}
+
+// dropManagedFields removes the entries drop selects from obj's managedFields, leaving the fields themselves in place
+// for the next manager to pick up. Returns false if there was nothing to drop
+func (r *Reconciler) dropManagedFields(ctx context.Context, obj *unstructured.Unstructured, drop func(metav1.ManagedFieldsEntry) bool) (bool, error) {
+	live := &metav1.PartialObjectMetadata{}
+	live.SetGroupVersionKind(obj.GroupVersionKind())
+	if err := r.mgr.Client().Get(ctx, client.ObjectKeyFromObject(obj), live); err != nil {
+		return false, fmt.Errorf("error getting %v: %w", ssautils.FmtUnstructured(obj), err)
+	}
+
+	entries := live.GetManagedFields()
+	kept := lo.Reject(entries, func(entry metav1.ManagedFieldsEntry, _ int) bool { return drop(entry) })
+	if len(kept) == len(entries) {
+		return false, nil
+	}
+
+	// An empty list leaves managedFields untouched, a single empty entry is what clears them
+	var value any = kept
+	if len(kept) == 0 {
+		value = []map[string]any{{}}
+	}
+	patch, err := json.Marshal([]map[string]any{
+		{"op": "test", "path": "/metadata/resourceVersion", "value": live.GetResourceVersion()},
+		{"op": "replace", "path": "/metadata/managedFields", "value": value},
+	})
+	if err != nil {
+		return false, fmt.Errorf("error encoding managed fields of %v: %w", ssautils.FmtUnstructured(obj), err)
+	}
+
+	stub := toInventoryItem(obj).stub()
+	if err := r.mgr.Client().Patch(ctx, stub, client.RawPatch(types.JSONPatchType, patch)); err != nil {
+		return false, fmt.Errorf("error patching managed fields of %v: %w", ssautils.FmtUnstructured(obj), err)
+	}
+	return true, nil
+}
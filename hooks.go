@@ -0,0 +1,161 @@
+package goply
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	ssautils "github.com/fluxcd/pkg/ssa/utils"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Annotations understood by the DefaultAnnotationHooks
+const (
+	// PreApplyWaitAnnotation, set to a whole number of seconds, holds off on applying the object for that long
+	PreApplyWaitAnnotation = "goply.io/pre-apply-wait-seconds"
+	// PostApplyVerifyAnnotation, set to a condition type, optionally followed by =Status (True if left out), requires
+	// the applied object to report that condition in its status before the reconcile moves on. It's given up to the
+	// WaitTimeout, and counts as a failed apply if it doesn't get there
+	PostApplyVerifyAnnotation = "goply.io/post-apply-verify"
+)
+
+// AnnotationHook is a per-object hook, run for every applied object carrying Annotation, with the annotation's value.
+// Either func may be nil. Hooks run one object after another, so a PreApply that blocks holds up the apply of every
+// object in its batch, not just its own
+type AnnotationHook struct {
+	Annotation string
+	// PreApply runs before the object is applied, an error stopping the apply
+	PreApply func(ctx context.Context, c client.Client, obj *unstructured.Unstructured, value string) error
+	// PostApply runs once the object has been applied, an error failing the reconcile. ctx is bound by the WaitTimeout
+	PostApply func(ctx context.Context, c client.Client, obj *unstructured.Unstructured, value string) error
+}
+
+// DefaultAnnotationHooks are always available, see ReconcilerConfig.AnnotationHooks
+var DefaultAnnotationHooks = []AnnotationHook{
+	{Annotation: PreApplyWaitAnnotation, PreApply: preApplyWait},
+	{Annotation: PostApplyVerifyAnnotation, PostApply: postApplyVerify},
+}
+
+var ErrInvalidHookAnnotationError = errors.New("invalid hook annotation")
+
+// HookError is returned when an annotation hook fails, Object being the object it ran for
+type HookError struct {
+	Object     InventoryItem
+	Annotation string
+	err        error
+}
+
+func (e *HookError) Error() string { return e.err.Error() }
+func (e *HookError) Unwrap() error { return e.err }
+
+// annotationHooks combines the DefaultAnnotationHooks with extra, an extra hook replacing a default one for the same
+// annotation
+func annotationHooks(extra []AnnotationHook) []AnnotationHook {
+	hooks := []AnnotationHook{}
+	for _, hook := range DefaultAnnotationHooks {
+		replaced := false
+		for _, e := range extra {
+			replaced = replaced || e.Annotation == hook.Annotation
+		}
+		if !replaced {
+			hooks = append(hooks, hook)
+		}
+	}
+	return append(hooks, extra...)
+}
+
+func (r *Reconciler) runPreApplyHooks(ctx context.Context, objects []*unstructured.Unstructured) error {
+	return r.runHooks(ctx, objects, "pre-apply", func(h AnnotationHook) hookFunc { return h.PreApply })
+}
+
+func (r *Reconciler) runPostApplyHooks(ctx context.Context, objects []*unstructured.Unstructured, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	return r.runHooks(ctx, objects, "post-apply", func(h AnnotationHook) hookFunc { return h.PostApply })
+}
+
+type hookFunc func(ctx context.Context, c client.Client, obj *unstructured.Unstructured, value string) error
+
+func (r *Reconciler) runHooks(ctx context.Context, objects []*unstructured.Unstructured, phase string, pick func(AnnotationHook) hookFunc) error {
+	for _, obj := range objects {
+		for _, hook := range r.annotationHooks {
+			f := pick(hook)
+			value, ok := obj.GetAnnotations()[hook.Annotation]
+			if f == nil || !ok {
+				continue
+			}
+
+			r.log(ctx, fmt.Sprintf("running %v hook", phase), append(objectKV(obj), "annotation", hook.Annotation)...)
+			if err := f(ctx, r.mgr.Client(), obj, value); err != nil {
+				return &HookError{
+					Object:     toInventoryItem(obj),
+					Annotation: hook.Annotation,
+					err:        fmt.Errorf("%v hook %v failed for %v: %w", phase, hook.Annotation, ssautils.FmtUnstructured(obj), err),
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func preApplyWait(ctx context.Context, _ client.Client, _ *unstructured.Unstructured, value string) error {
+	seconds, err := strconv.Atoi(value)
+	if err != nil || seconds < 0 {
+		return fmt.Errorf("%w, %q is not a whole number of seconds", ErrInvalidHookAnnotationError, value)
+	}
+
+	select {
+	case <-time.After(time.Duration(seconds) * time.Second):
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func postApplyVerify(ctx context.Context, c client.Client, obj *unstructured.Unstructured, value string) error {
+	conditionType, conditionStatus, found := strings.Cut(value, "=")
+	if !found {
+		conditionStatus = "True"
+	}
+	if conditionType == "" || conditionStatus == "" {
+		return fmt.Errorf("%w, %q is not a condition type, optionally followed by =Status", ErrInvalidHookAnnotationError, value)
+	}
+
+	last := "not yet reported"
+	err := wait.PollUntilContextCancel(ctx, time.Second, true, func(ctx context.Context) (bool, error) {
+		live := &unstructured.Unstructured{}
+		live.SetGroupVersionKind(obj.GroupVersionKind())
+		if err := c.Get(ctx, client.ObjectKeyFromObject(obj), live); err != nil {
+			return false, err
+		}
+
+		status, ok := conditionStatusOf(live, conditionType)
+		if ok {
+			last = fmt.Sprintf("is %v", status)
+		}
+		return ok && status == conditionStatus, nil
+	})
+	if err != nil {
+		return fmt.Errorf("condition %v=%v, last %v: %w", conditionType, conditionStatus, last, err)
+	}
+	return nil
+}
+
+// conditionStatusOf returns the status of the live object's condition of type conditionType, if it reports one
+func conditionStatusOf(live *unstructured.Unstructured, conditionType string) (string, bool) {
+	conditions, _, _ := unstructured.NestedSlice(live.Object, "status", "conditions")
+	for _, c := range conditions {
+		condition, ok := c.(map[string]any)
+		if !ok || condition["type"] != conditionType {
+			continue
+		}
+		status, ok := condition["status"].(string)
+		return status, ok
+	}
+	return "", false
+}
@@ -0,0 +1,119 @@
+package goply
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestAnnotationHooks(t *testing.T) {
+	replacement := AnnotationHook{Annotation: PreApplyWaitAnnotation}
+	extra := AnnotationHook{Annotation: "example.com/extra"}
+
+	hooks := annotationHooks([]AnnotationHook{replacement, extra})
+	require.Len(t, hooks, 3)
+	require.Equal(t, PostApplyVerifyAnnotation, hooks[0].Annotation)
+	require.Nil(t, hooks[1].PreApply)
+	require.Equal(t, "example.com/extra", hooks[2].Annotation)
+}
+
+func TestRunHooks(t *testing.T) {
+	failing := errors.New("nope")
+	seen := []string{}
+
+	r := offlineReconciler(t)
+	r.annotationHooks = annotationHooks([]AnnotationHook{{
+		Annotation: "example.com/check",
+		PreApply: func(_ context.Context, _ client.Client, obj *unstructured.Unstructured, value string) error {
+			seen = append(seen, obj.GetName())
+			if value == "fail" {
+				return failing
+			}
+			return nil
+		},
+	}})
+
+	objects, err := GetObjects(`
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: config-one
+  namespace: default
+  annotations:
+    example.com/check: ok
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: config-two
+  namespace: default
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: config-three
+  namespace: default
+  annotations:
+    example.com/check: fail
+`)
+	require.NoError(t, err)
+
+	err = r.runPreApplyHooks(context.Background(), objects)
+	require.ErrorIs(t, err, failing)
+	var hookErr *HookError
+	require.ErrorAs(t, err, &hookErr)
+	require.Equal(t, "config-three", hookErr.Object.Name)
+	require.Equal(t, "example.com/check", hookErr.Annotation)
+	require.Equal(t, []string{"config-one", "config-three"}, seen)
+}
+
+func TestPreApplyWait(t *testing.T) {
+	require.NoError(t, preApplyWait(context.Background(), nil, nil, "0"))
+	require.ErrorIs(t, preApplyWait(context.Background(), nil, nil, "1m"), ErrInvalidHookAnnotationError)
+	require.ErrorIs(t, preApplyWait(context.Background(), nil, nil, "-1"), ErrInvalidHookAnnotationError)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	require.ErrorIs(t, preApplyWait(ctx, nil, nil, "60"), context.Canceled)
+}
+
+func TestConditionStatusOf(t *testing.T) {
+	live := &unstructured.Unstructured{Object: map[string]any{
+		"status": map[string]any{
+			"conditions": []any{
+				map[string]any{"type": "Ready", "status": "True"},
+				map[string]any{"type": "Degraded", "status": "False"},
+			},
+		},
+	}}
+
+	status, ok := conditionStatusOf(live, "Degraded")
+	require.True(t, ok)
+	require.Equal(t, "False", status)
+
+	_, ok = conditionStatusOf(live, "Available")
+	require.False(t, ok)
+}
+
+func TestPostApplyVerify(t *testing.T) {
+	obj := &unstructured.Unstructured{}
+	obj.SetAPIVersion("v1")
+	obj.SetKind("ConfigMap")
+	obj.SetNamespace("default")
+	obj.SetName("config-one")
+	c := fake.NewClientBuilder().WithObjects(obj.DeepCopy()).Build()
+
+	require.ErrorIs(t, postApplyVerify(context.Background(), c, obj, "=True"), ErrInvalidHookAnnotationError)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	err := postApplyVerify(ctx, c, obj, "Ready")
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	require.ErrorContains(t, err, "last not yet reported")
+}
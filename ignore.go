@@ -0,0 +1,150 @@
+package goply
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/fluxcd/pkg/ssa"
+	"github.com/fluxcd/pkg/ssa/normalize"
+	ssautils "github.com/fluxcd/pkg/ssa/utils"
+	"github.com/samber/lo"
+	apiequality "k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// skipIgnoredDrift filters out objects that have an IgnoreFields entry and whose only drift from the live object is in
+// those ignored fields. They're recorded as unchanged. Anything that can't be checked is left for ApplyAll to deal with
+func (r *Reconciler) skipIgnoredDrift(ctx context.Context, objects []*unstructured.Unstructured, opts ApplyOpts, result *Result) ([]*unstructured.Unstructured, error) {
+	toApply := []*unstructured.Unstructured{}
+
+	for _, obj := range objects {
+		paths, ok := opts.IgnoreFields[obj.GroupVersionKind().GroupKind()]
+		if !ok || len(paths) == 0 {
+			toApply = append(toApply, obj)
+			continue
+		}
+
+		live := &unstructured.Unstructured{}
+		live.SetGroupVersionKind(obj.GroupVersionKind())
+		if err := r.mgr.Client().Get(ctx, client.ObjectKeyFromObject(obj), live); err != nil {
+			toApply = append(toApply, obj)
+			continue
+		}
+
+		dryRun := obj.DeepCopy()
+		err := r.mgr.Client().Patch(ctx, dryRun, client.Apply, client.DryRunAll, client.ForceOwnership, client.FieldOwner(fieldManager))
+		if err != nil {
+			toApply = append(toApply, obj)
+			continue
+		}
+
+		for _, path := range paths {
+			if err := removeJSONPointer(live.Object, path); err != nil {
+				return nil, fmt.Errorf("error applying ignore field %v to %v: %w", path, ssautils.FmtUnstructured(obj), err)
+			}
+			if err := removeJSONPointer(dryRun.Object, path); err != nil {
+				return nil, fmt.Errorf("error applying ignore field %v to %v: %w", path, ssautils.FmtUnstructured(obj), err)
+			}
+		}
+
+		if hasDrifted(live, dryRun) {
+			toApply = append(toApply, obj)
+			continue
+		}
+
+		r.log(ctx, "object has only drifted in ignored fields, not applying", objectKV(obj)...)
+		change := Change{
+			InventoryItem: toInventoryItem(obj),
+			Action:        ActionUnchanged,
+		}
+		result.Changes = append(result.Changes, change)
+		reportApplied(ctx, opts, change)
+	}
+
+	return toApply, nil
+}
+
+// skipUnchanged filters out objects that exist and match the manifest, recording them as skipped. Anything that can't be
+// diffed is left for ApplyAll to deal with
+func (r *Reconciler) skipUnchanged(ctx context.Context, objects []*unstructured.Unstructured, result *Result) []*unstructured.Unstructured {
+	return lo.Filter(objects, func(obj *unstructured.Unstructured, _ int) bool {
+		entry, _, _, err := r.mgr.Diff(withCachedReads(ctx), obj, ssa.DefaultDiffOptions())
+		if err != nil || entry.Action != ssa.UnchangedAction {
+			return true
+		}
+
+		r.log(ctx, "object has not drifted, not applying", objectKV(obj)...)
+		result.Skipped = append(result.Skipped, SkippedItem{
+			InventoryItem: toInventoryItem(obj),
+			Reason:        SkipReasonUnchanged,
+		})
+		return false
+	})
+}
+
+// hasDrifted mirrors the drift detection ssa.ResourceManager does internally
+func hasDrifted(live *unstructured.Unstructured, dryRun *unstructured.Unstructured) bool {
+	if !apiequality.Semantic.DeepEqual(dryRun.GetLabels(), live.GetLabels()) {
+		return true
+	}
+	if !apiequality.Semantic.DeepEqual(dryRun.GetAnnotations(), live.GetAnnotations()) {
+		return true
+	}
+
+	prepare := func(u *unstructured.Unstructured) *unstructured.Unstructured {
+		c := u.DeepCopy()
+		unstructured.RemoveNestedField(c.Object, "metadata")
+		unstructured.RemoveNestedField(c.Object, "status")
+		if err := normalize.DryRunUnstructured(c); err != nil {
+			return u
+		}
+		return c
+	}
+
+	return !apiequality.Semantic.DeepEqual(prepare(dryRun).Object, prepare(live).Object)
+}
+
+// removeJSONPointer deletes the field at the RFC 6901 pointer, if it exists
+func removeJSONPointer(obj map[string]any, pointer string) error {
+	if !strings.HasPrefix(pointer, "/") {
+		return fmt.Errorf("invalid JSON pointer %q, must start with /", pointer)
+	}
+
+	tokens := strings.Split(pointer[1:], "/")
+	for i := range tokens {
+		tokens[i] = strings.ReplaceAll(strings.ReplaceAll(tokens[i], "~1", "/"), "~0", "~")
+	}
+
+	var current any = obj
+	for i, token := range tokens {
+		last := i == len(tokens)-1
+
+		switch c := current.(type) {
+		case map[string]any:
+			if last {
+				delete(c, token)
+				return nil
+			}
+			current = c[token]
+		case []any:
+			idx, err := strconv.Atoi(token)
+			if err != nil || idx < 0 || idx >= len(c) {
+				return nil
+			}
+			if last {
+				// Removing out of a list would shift the indexes of everything after it, null it instead so both sides
+				// still line up
+				c[idx] = nil
+				return nil
+			}
+			current = c[idx]
+		default:
+			return nil
+		}
+	}
+
+	return nil
+}
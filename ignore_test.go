@@ -0,0 +1,54 @@
+package goply
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRemoveJSONPointer(t *testing.T) {
+	newObj := func() map[string]any {
+		return map[string]any{
+			"metadata": map[string]any{
+				"annotations": map[string]any{
+					"example.com/foo": "bar",
+					"keep":            "me",
+				},
+			},
+			"spec": map[string]any{
+				"replicas": int64(3),
+				"containers": []any{
+					map[string]any{"name": "main", "image": "nginx"},
+				},
+			},
+		}
+	}
+
+	t.Run("nested map field", func(t *testing.T) {
+		obj := newObj()
+		require.NoError(t, removeJSONPointer(obj, "/spec/replicas"))
+		require.NotContains(t, obj["spec"], "replicas")
+	})
+
+	t.Run("escaped key", func(t *testing.T) {
+		obj := newObj()
+		require.NoError(t, removeJSONPointer(obj, "/metadata/annotations/example.com~1foo"))
+		require.Equal(t, map[string]any{"keep": "me"}, obj["metadata"].(map[string]any)["annotations"])
+	})
+
+	t.Run("through a list", func(t *testing.T) {
+		obj := newObj()
+		require.NoError(t, removeJSONPointer(obj, "/spec/containers/0/image"))
+		require.Equal(t, []any{map[string]any{"name": "main"}}, obj["spec"].(map[string]any)["containers"])
+	})
+
+	t.Run("missing path is a no-op", func(t *testing.T) {
+		obj := newObj()
+		require.NoError(t, removeJSONPointer(obj, "/spec/nope/nothing"))
+		require.Equal(t, newObj(), obj)
+	})
+
+	t.Run("invalid pointer", func(t *testing.T) {
+		require.Error(t, removeJSONPointer(newObj(), "spec.replicas"))
+	})
+}
@@ -1,6 +1,9 @@
 package goply
 
 import (
+	"sort"
+	"strings"
+
 	"github.com/samber/lo"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime/schema"
@@ -11,6 +14,44 @@ type Inventory struct {
 	Items []InventoryItem
 }
 
+func (i Inventory) Contains(m object.ObjMetadata) bool {
+	_, ok := i.Get(m)
+	return ok
+}
+
+// Get returns the first item for m. It scans Items each time, so anything looking up more than a handful of objects
+// should build an Index once and use that
+func (i Inventory) Get(m object.ObjMetadata) (InventoryItem, bool) {
+	return lo.Find(i.Items, func(item InventoryItem) bool { return item.ObjMetadata == m })
+}
+
+// InventoryIndex looks up the items of an inventory by ObjMetadata without scanning them, see Inventory.Index
+type InventoryIndex struct {
+	items map[object.ObjMetadata]InventoryItem
+}
+
+// Index returns an index of i's items, the first item winning for duplicates. It's a snapshot, so it doesn't see
+// later changes to i, and is never written to, so it can be read concurrently
+func (i Inventory) Index() InventoryIndex {
+	items := make(map[object.ObjMetadata]InventoryItem, len(i.Items))
+	for _, item := range i.Items {
+		if _, ok := items[item.ObjMetadata]; !ok {
+			items[item.ObjMetadata] = item
+		}
+	}
+	return InventoryIndex{items: items}
+}
+
+func (x InventoryIndex) Contains(m object.ObjMetadata) bool {
+	_, ok := x.items[m]
+	return ok
+}
+
+func (x InventoryIndex) Get(m object.ObjMetadata) (InventoryItem, bool) {
+	item, ok := x.items[m]
+	return item, ok
+}
+
 func (i Inventory) ItemsToRemove(newInv Inventory) []*unstructured.Unstructured {
 	newSet := newSet(lo.Map(newInv.Items, func(i InventoryItem, _ int) string { return i.ID() })...)
 
@@ -18,34 +59,156 @@ func (i Inventory) ItemsToRemove(newInv Inventory) []*unstructured.Unstructured
 
 	for _, i := range i.Items {
 		if !newSet.Contains(i.ID()) {
-			obj := &unstructured.Unstructured{}
-			obj.SetGroupVersionKind(schema.GroupVersionKind{
-				Group:   i.ObjMetadata.GroupKind.Group,
-				Kind:    i.ObjMetadata.GroupKind.Kind,
-				Version: i.GroupVersion,
-			})
-			obj.SetName(i.Name)
-			obj.SetNamespace(i.Namespace)
-
-			toRemove = append(toRemove, obj)
+			toRemove = append(toRemove, i.stub())
 		}
 	}
 
 	return toRemove
 }
 
+// Equal reports whether both inventories hold the same set of objects, regardless of order. An object whose
+// ContentHash differs between the two, when both have one, counts as a difference too, so Changed notices content
+// changes as well as objects coming and going
+func (i Inventory) Equal(other Inventory) bool {
+	hashes := i.hashesByID()
+	otherHashes := other.hashesByID()
+	if len(hashes) != len(otherHashes) {
+		return false
+	}
+	for id, hash := range hashes {
+		otherHash, ok := otherHashes[id]
+		if !ok || hash != "" && otherHash != "" && hash != otherHash {
+			return false
+		}
+	}
+	return true
+}
+
+// hashesByID maps the ID of every item to its ContentHash, the first item winning for duplicates
+func (i Inventory) hashesByID() map[string]string {
+	hashes := make(map[string]string, len(i.Items))
+	for _, item := range i.Items {
+		if _, ok := hashes[item.ID()]; !ok {
+			hashes[item.ID()] = item.ContentHash
+		}
+	}
+	return hashes
+}
+
+// Changed reports whether i differs from previous, so whether it needs persisting. A nil previous, meaning nothing has
+// been persisted yet, is always a change
+func (i Inventory) Changed(previous *Inventory) bool {
+	return previous == nil || !i.Equal(*previous)
+}
+
+// FilterByNamespace returns a new inventory holding only the items in namespace ns. Cluster scoped items are matched by
+// an empty ns
+func (i Inventory) FilterByNamespace(ns string) Inventory {
+	return i.filter(func(item InventoryItem) bool { return item.Namespace == ns })
+}
+
+// FilterByGroupKind returns a new inventory holding only the items of kind gk
+func (i Inventory) FilterByGroupKind(gk schema.GroupKind) Inventory {
+	return i.filter(func(item InventoryItem) bool { return item.GroupKind == gk })
+}
+
+func (i Inventory) filter(predicate func(InventoryItem) bool) Inventory {
+	return Inventory{
+		Items: lo.Filter(i.Items, func(item InventoryItem, _ int) bool { return predicate(item) }),
+	}
+}
+
+// GroupedByNamespace returns the items keyed by namespace, cluster scoped items under "". Each namespace's items are
+// sorted by kind and then name
+func (i Inventory) GroupedByNamespace() map[string][]InventoryItem {
+	grouped := lo.GroupBy(i.Items, func(item InventoryItem) string { return item.Namespace })
+	for _, items := range grouped {
+		sort.SliceStable(items, func(a, b int) bool {
+			if items[a].GroupKind.String() != items[b].GroupKind.String() {
+				return items[a].GroupKind.String() < items[b].GroupKind.String()
+			}
+			return items[a].Name < items[b].Name
+		})
+	}
+	return grouped
+}
+
+// String renders the inventory as a tree of namespace, kind and name, cluster scoped items first, for display
+func (i Inventory) String() string {
+	grouped := i.GroupedByNamespace()
+	namespaces := lo.Keys(grouped)
+	sort.Strings(namespaces)
+
+	b := strings.Builder{}
+	for _, ns := range namespaces {
+		if ns == "" {
+			b.WriteString("(cluster)\n")
+		} else {
+			b.WriteString(ns + "\n")
+		}
+
+		kind := ""
+		for _, item := range grouped[ns] {
+			if item.GroupKind.String() != kind {
+				kind = item.GroupKind.String()
+				b.WriteString("  " + kind + "\n")
+			}
+			b.WriteString("    " + item.Name + "\n")
+		}
+	}
+	return b.String()
+}
+
+// union returns an inventory holding every item in either inventory, the items of i taking precedence
+func (i Inventory) union(other Inventory) Inventory {
+	merged := Inventory{Items: append([]InventoryItem{}, i.Items...)}
+	seen := newSet(lo.Map(i.Items, func(item InventoryItem, _ int) object.ObjMetadata { return item.ObjMetadata })...)
+	for _, item := range other.Items {
+		if !seen.Contains(item.ObjMetadata) {
+			seen.Add(item.ObjMetadata)
+			merged.Items = append(merged.Items, item)
+		}
+	}
+	return merged
+}
+
 type InventoryItem struct {
 	object.ObjMetadata
 	GroupVersion string
+	// ExternallyManaged is set for objects carrying the ExternallyManagedAnnotation, which are never pruned
+	ExternallyManaged bool `json:",omitempty"`
+	// ContentHash is a hash of the object as it was in the manifest, set for everything a reconcile applied, for
+	// Reconciler.SnapshotDiff to compare against
+	ContentHash string `json:",omitempty"`
 }
 
 func (i InventoryItem) ID() string {
 	return i.ObjMetadata.String()
 }
 
+// stub builds a bare object carrying just enough identity to address the item in the cluster
+func (i InventoryItem) stub() *unstructured.Unstructured {
+	obj := &unstructured.Unstructured{}
+	obj.SetGroupVersionKind(schema.GroupVersionKind{
+		Group:   i.ObjMetadata.GroupKind.Group,
+		Kind:    i.ObjMetadata.GroupKind.Kind,
+		Version: i.GroupVersion,
+	})
+	obj.SetName(i.Name)
+	obj.SetNamespace(i.Namespace)
+	return obj
+}
+
+func toInventoryItems(objs []*unstructured.Unstructured) []InventoryItem {
+	return lo.Map(objs, func(u *unstructured.Unstructured, _ int) InventoryItem {
+		return toInventoryItem(u)
+	})
+}
+
 func toInventoryItem(obj *unstructured.Unstructured) InventoryItem {
 	return InventoryItem{
-		ObjMetadata:  object.UnstructuredToObjMetadata(obj),
-		GroupVersion: obj.GroupVersionKind().Version,
+		ObjMetadata:       object.UnstructuredToObjMetadata(obj),
+		GroupVersion:      obj.GroupVersionKind().Version,
+		ExternallyManaged: isExternallyManaged(obj.GetAnnotations()),
 	}
 }
@@ -7,6 +7,8 @@ import (
 	"github.com/samber/lo"
 	"github.com/stretchr/testify/require"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/cli-utils/pkg/object"
 )
 
 func inventoryFromYaml(t *testing.T, yaml string) Inventory {
@@ -53,3 +55,189 @@ func TestInventoryDiff(t *testing.T) {
 	toRemoveNames := lo.Map(toRemove, func(u *unstructured.Unstructured, _ int) string { return u.GetName() })
 	require.Equal(t, []string{"config-one"}, toRemoveNames)
 }
+
+func TestInventoryLookup(t *testing.T) {
+	inv := inventoryFromYaml(t, dedent.Dedent(`
+		---
+		apiVersion: v1
+		kind: ConfigMap
+		metadata:
+		  name: config-one
+		  namespace: goply-test
+		data:
+		  foo: foo1
+		---
+		apiVersion: apps/v1
+		kind: Deployment
+		metadata:
+		  name: deploy-one
+		  namespace: goply-test
+	`)[1:])
+
+	deploy := object.ObjMetadata{
+		Namespace: "goply-test",
+		Name:      "deploy-one",
+		GroupKind: schema.GroupKind{Group: "apps", Kind: "Deployment"},
+	}
+	missing := object.ObjMetadata{
+		Namespace: "goply-test",
+		Name:      "config-two",
+		GroupKind: schema.GroupKind{Kind: "ConfigMap"},
+	}
+
+	require.True(t, inv.Contains(deploy))
+	require.False(t, inv.Contains(missing))
+
+	item, ok := inv.Get(deploy)
+	require.True(t, ok)
+	require.Equal(t, "v1", item.GroupVersion)
+
+	_, ok = inv.Get(missing)
+	require.False(t, ok)
+
+	// Lookups should notice the inventory growing, and items being edited in place
+	inv.Items = append(inv.Items, InventoryItem{ObjMetadata: missing, GroupVersion: "v1"})
+	require.True(t, inv.Contains(missing))
+	inv.Items[1].GroupVersion = "v2"
+	item, ok = inv.Get(deploy)
+	require.True(t, ok)
+	require.Equal(t, "v2", item.GroupVersion)
+
+	// An index gives the same answers, from a snapshot of the inventory
+	index := inv.Index()
+	require.True(t, index.Contains(missing))
+	item, ok = index.Get(deploy)
+	require.True(t, ok)
+	require.Equal(t, "v2", item.GroupVersion)
+	inv.Items = inv.Items[:1]
+	require.True(t, index.Contains(missing))
+	require.False(t, inv.Index().Contains(missing))
+}
+
+func TestInventoryFilter(t *testing.T) {
+	inv := inventoryFromYaml(t, dedent.Dedent(`
+		---
+		apiVersion: v1
+		kind: Namespace
+		metadata:
+		  name: goply-test
+		---
+		apiVersion: v1
+		kind: ConfigMap
+		metadata:
+		  name: config-one
+		  namespace: goply-test
+		---
+		apiVersion: v1
+		kind: ConfigMap
+		metadata:
+		  name: config-two
+		  namespace: other
+		---
+		apiVersion: apps/v1
+		kind: Deployment
+		metadata:
+		  name: deploy-one
+		  namespace: goply-test
+	`)[1:])
+
+	names := func(inv Inventory) []string {
+		return lo.Map(inv.Items, func(i InventoryItem, _ int) string { return i.Name })
+	}
+
+	require.Equal(t, []string{"config-one", "deploy-one"}, names(inv.FilterByNamespace("goply-test")))
+	require.Equal(t, []string{"goply-test"}, names(inv.FilterByNamespace("")))
+	require.Equal(t, []string{"config-one", "config-two"}, names(inv.FilterByGroupKind(schema.GroupKind{Kind: "ConfigMap"})))
+	require.Equal(
+		t,
+		[]string{"config-one"},
+		names(inv.FilterByNamespace("goply-test").FilterByGroupKind(schema.GroupKind{Kind: "ConfigMap"})),
+	)
+}
+
+func TestInventoryEqual(t *testing.T) {
+	inv := inventoryFromYaml(t, dedent.Dedent(`
+		---
+		apiVersion: v1
+		kind: ConfigMap
+		metadata:
+		  name: config-one
+		  namespace: goply-test
+		---
+		apiVersion: v1
+		kind: ConfigMap
+		metadata:
+		  name: config-two
+		  namespace: goply-test
+	`)[1:])
+
+	reversed := Inventory{Items: lo.Reverse(append([]InventoryItem{}, inv.Items...))}
+	require.True(t, inv.Equal(reversed))
+	require.False(t, inv.Changed(&reversed))
+
+	fewer := Inventory{Items: inv.Items[:1]}
+	require.False(t, inv.Equal(fewer))
+	require.True(t, inv.Changed(&fewer))
+
+	require.True(t, inv.Changed(nil))
+
+	hashed := func(hashes ...string) Inventory {
+		items := append([]InventoryItem{}, inv.Items...)
+		for idx := range items {
+			items[idx].ContentHash = hashes[idx]
+		}
+		return Inventory{Items: items}
+	}
+
+	// Same objects, one of them with different content
+	require.True(t, hashed("a", "b").Equal(hashed("a", "b")))
+	require.False(t, hashed("a", "b").Equal(hashed("a", "c")))
+	require.True(t, hashed("a", "c").Changed(ptr(hashed("a", "b"))))
+	// Without a hash on both sides there's nothing to compare
+	require.True(t, hashed("a", "").Equal(hashed("a", "c")))
+	require.True(t, hashed("a", "b").Equal(inv))
+}
+
+func TestInventoryString(t *testing.T) {
+	inv := inventoryFromYaml(t, dedent.Dedent(`
+		---
+		apiVersion: apps/v1
+		kind: Deployment
+		metadata:
+		  name: deploy-one
+		  namespace: goply-test
+		---
+		apiVersion: v1
+		kind: ConfigMap
+		metadata:
+		  name: config-two
+		  namespace: goply-test
+		---
+		apiVersion: v1
+		kind: Namespace
+		metadata:
+		  name: goply-test
+		---
+		apiVersion: v1
+		kind: ConfigMap
+		metadata:
+		  name: config-one
+		  namespace: goply-test
+	`)[1:])
+
+	grouped := inv.GroupedByNamespace()
+	require.Equal(t, 2, len(grouped))
+	require.Equal(t, []string{"config-one", "config-two", "deploy-one"}, lo.Map(grouped["goply-test"], func(i InventoryItem, _ int) string { return i.Name }))
+
+	require.Equal(t, dedent.Dedent(`
+		(cluster)
+		  Namespace
+		    goply-test
+		goply-test
+		  ConfigMap
+		    config-one
+		    config-two
+		  Deployment.apps
+		    deploy-one
+	`)[1:], inv.String())
+}
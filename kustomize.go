@@ -0,0 +1,27 @@
+package goply
+
+import (
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/kustomize/api/krusty"
+	"sigs.k8s.io/kustomize/kyaml/filesys"
+)
+
+// GetObjectsFromKustomize runs a kustomize build of the overlay in dir and decodes the rendered output, ready to be
+// passed to ApplyObjects/ReconcileObjects
+func GetObjectsFromKustomize(dir string) ([]*unstructured.Unstructured, error) {
+	k := krusty.MakeKustomizer(krusty.MakeDefaultOptions())
+
+	resMap, err := k.Run(filesys.MakeFsOnDisk(), dir)
+	if err != nil {
+		return []*unstructured.Unstructured{}, fmt.Errorf("error running kustomize build of %v: %w", dir, err)
+	}
+
+	yaml, err := resMap.AsYaml()
+	if err != nil {
+		return []*unstructured.Unstructured{}, fmt.Errorf("error rendering kustomize output: %w", err)
+	}
+
+	return GetObjects(string(yaml))
+}
@@ -0,0 +1,47 @@
+package goply
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/lithammer/dedent"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetObjectsFromKustomize(t *testing.T) {
+	dir := t.TempDir()
+
+	writeFile := func(name string, content string) {
+		t.Helper()
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(dedent.Dedent(content)[1:]), 0644))
+	}
+
+	writeFile("kustomization.yaml", `
+		namespace: goply-kustomize-test
+		namePrefix: dev-
+		resources:
+		- configmap.yaml
+	`)
+	writeFile("configmap.yaml", `
+		apiVersion: v1
+		kind: ConfigMap
+		metadata:
+		  name: config-one
+		data:
+		  foo: foo1
+	`)
+
+	t.Run("builds overlay", func(t *testing.T) {
+		objs, err := GetObjectsFromKustomize(dir)
+		require.NoError(t, err)
+		require.Len(t, objs, 1)
+		require.Equal(t, "dev-config-one", objs[0].GetName())
+		require.Equal(t, "goply-kustomize-test", objs[0].GetNamespace())
+	})
+
+	t.Run("build failure", func(t *testing.T) {
+		_, err := GetObjectsFromKustomize(filepath.Join(dir, "does-not-exist"))
+		require.ErrorContains(t, err, "error running kustomize build")
+	})
+}
@@ -0,0 +1,147 @@
+package goply
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+
+	ssautils "github.com/fluxcd/pkg/ssa/utils"
+	apiequality "k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// LastAppliedAnnotation holds, when ApplyOpts.RecordLastApplied is set, the object as it was last applied by goply,
+// for PreviewFromLastApplied to diff against. Secret values are recorded as hashes, never in the clear
+const LastAppliedAnnotation = "goply.io/last-applied"
+
+// Preview is what reconciling a manifest would change, judged against what goply last applied rather than the live
+// state of the cluster, see PreviewFromLastApplied
+type Preview struct {
+	Create    []InventoryItem
+	Update    []PreviewUpdate
+	Unchanged []InventoryItem
+	// Untracked objects exist but have no LastAppliedAnnotation, so there's nothing to compare them to
+	Untracked []InventoryItem
+	Prune     []InventoryItem
+}
+
+// PreviewUpdate is an object whose manifest differs from what was last applied. Secret values in both are hashes
+type PreviewUpdate struct {
+	InventoryItem
+	LastApplied *unstructured.Unstructured
+	Desired     *unstructured.Unstructured
+}
+
+// PreviewFromLastApplied compares yaml against the LastAppliedAnnotation on each object, reading only object metadata
+// from the cluster. It's a cheaper, rougher, alternative to Plan, with some limitations:
+//   - it only knows what goply last sent, so drift made by anyone else since then goes unnoticed
+//   - nothing is validated, defaulted or run through admission webhooks, so an apply it calls an update may still be
+//     rejected, and one it calls an update may turn out to be a no-op once the server has merged it
+//   - objects last applied without RecordLastApplied, or by something else entirely, are reported as Untracked
+//   - labels goply adds itself, for Owner and ManagementLabels, aren't part of the comparison
+//
+// previous may be nil, in which case nothing is pruned
+func (r *Reconciler) PreviewFromLastApplied(ctx context.Context, yaml string, previous *Inventory) (Preview, error) {
+	allObjects, err := GetObjects(yaml)
+	if err != nil {
+		return Preview{}, fmt.Errorf("error decoding yaml to unstructured: %w", err)
+	}
+
+	stageOne, stageTwo, _, err := getResourceStages(allObjects, r.stages, r.transformers)
+	if err != nil {
+		return Preview{}, fmt.Errorf("error getting resource stages: %w", err)
+	}
+
+	preview := Preview{}
+	for _, obj := range r.withoutIgnoredObjects(append(append([]*unstructured.Unstructured{}, stageOne...), stageTwo...)) {
+		item := toInventoryItem(obj)
+
+		live := &metav1.PartialObjectMetadata{}
+		live.SetGroupVersionKind(obj.GroupVersionKind())
+		err := r.mgr.Client().Get(withCachedReads(ctx), client.ObjectKeyFromObject(obj), live)
+		if apierrors.IsNotFound(err) || meta.IsNoMatchError(err) {
+			preview.Create = append(preview.Create, item)
+			continue
+		}
+		if err != nil {
+			return Preview{}, fmt.Errorf("error getting %v: %w", ssautils.FmtUnstructured(obj), err)
+		}
+
+		recorded, ok := live.GetAnnotations()[LastAppliedAnnotation]
+		if !ok {
+			preview.Untracked = append(preview.Untracked, item)
+			continue
+		}
+		lastApplied := &unstructured.Unstructured{}
+		if err := lastApplied.UnmarshalJSON([]byte(recorded)); err != nil {
+			return Preview{}, fmt.Errorf("error decoding %v on %v: %w", LastAppliedAnnotation, ssautils.FmtUnstructured(obj), err)
+		}
+
+		desired := lastAppliedForm(obj)
+		if apiequality.Semantic.DeepEqual(lastApplied.Object, desired.Object) {
+			preview.Unchanged = append(preview.Unchanged, item)
+			continue
+		}
+		preview.Update = append(preview.Update, PreviewUpdate{InventoryItem: item, LastApplied: lastApplied, Desired: desired})
+	}
+
+	if previous != nil {
+		newInventory := Inventory{Items: toInventoryItems(allObjects)}
+		preview.Prune = r.withoutIgnoredItems(toInventoryItems(previous.ItemsToRemove(newInventory)))
+	}
+
+	return preview, nil
+}
+
+// recordLastApplied sets the LastAppliedAnnotation on every object, from the object as it is now
+func recordLastApplied(objects []*unstructured.Unstructured) error {
+	for _, obj := range objects {
+		recorded, err := lastAppliedForm(obj).MarshalJSON()
+		if err != nil {
+			return fmt.Errorf("error recording %v on %v: %w", LastAppliedAnnotation, ssautils.FmtUnstructured(obj), err)
+		}
+
+		annotations := obj.GetAnnotations()
+		if annotations == nil {
+			annotations = map[string]string{}
+		}
+		annotations[LastAppliedAnnotation] = string(recorded)
+		obj.SetAnnotations(annotations)
+	}
+	return nil
+}
+
+// lastAppliedForm is obj as recorded in the LastAppliedAnnotation: without the annotations that change on their own
+// between applies, and with Secret values replaced by their hashes
+func lastAppliedForm(obj *unstructured.Unstructured) *unstructured.Unstructured {
+	form := obj.DeepCopy()
+
+	annotations := form.GetAnnotations()
+	delete(annotations, LastAppliedAnnotation)
+	delete(annotations, ReconcileIDAnnotation)
+	if len(annotations) == 0 {
+		annotations = nil
+	}
+	form.SetAnnotations(annotations)
+
+	if ssautils.IsSecret(form) {
+		for _, field := range []string{"data", "stringData"} {
+			values, ok, _ := unstructured.NestedMap(form.Object, field)
+			if !ok {
+				continue
+			}
+			for k, v := range values {
+				sum := sha256.Sum256([]byte(fmt.Sprint(v)))
+				values[k] = "sha256:" + hex.EncodeToString(sum[:])
+			}
+			_ = unstructured.SetNestedMap(form.Object, values, field)
+		}
+	}
+
+	return form
+}
@@ -0,0 +1,40 @@
+package goply
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/lithammer/dedent"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestRecordLastApplied(t *testing.T) {
+	objs, err := GetObjects(dedent.Dedent(`
+		---
+		apiVersion: v1
+		kind: Secret
+		metadata:
+		  name: secret-one
+		  namespace: goply-test
+		  annotations:
+		    goply.io/reconcile-id: abc
+		stringData:
+		  password: hunter2
+	`)[1:])
+	require.NoError(t, err)
+	require.NoError(t, recordLastApplied(objs))
+
+	recorded := objs[0].GetAnnotations()[LastAppliedAnnotation]
+	require.NotContains(t, recorded, "hunter2")
+	require.NotContains(t, recorded, "reconcile-id")
+
+	lastApplied := &unstructured.Unstructured{}
+	require.NoError(t, lastApplied.UnmarshalJSON([]byte(recorded)))
+	password, _, _ := unstructured.NestedString(lastApplied.Object, "stringData", "password")
+	require.True(t, strings.HasPrefix(password, "sha256:"))
+
+	// Recording again, or stamping a new reconcile ID, records the same thing
+	objs[0].SetAnnotations(map[string]string{ReconcileIDAnnotation: "def", LastAppliedAnnotation: recorded})
+	require.Equal(t, lastApplied.Object, lastAppliedForm(objs[0]).Object)
+}
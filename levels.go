@@ -0,0 +1,81 @@
+package goply
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/fluxcd/pkg/ssa"
+	"github.com/samber/lo"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/cli-utils/pkg/object"
+	"sigs.k8s.io/cli-utils/pkg/object/graph"
+)
+
+// DefaultMaxParallelism is how many objects are applied at once within a dependency level when MaxParallelism isn't
+// set
+const DefaultMaxParallelism = 4
+
+// dependencyLevels splits stageTwo into levels, where every object only depends on objects in earlier levels (or in
+// stage one, which has already been applied). Dependencies come from the config.kubernetes.io/depends-on annotation,
+// as well as the implicit ones on namespaces and CRDs
+func dependencyLevels(stageOne []*unstructured.Unstructured, stageTwo []*unstructured.Unstructured) ([][]*unstructured.Unstructured, error) {
+	// Stage one has to be part of the graph, otherwise depending on one of its objects is an external dependency
+	all := append(append(object.UnstructuredSet{}, stageOne...), stageTwo...)
+	sets, err := graph.SortObjs(all)
+	if err != nil {
+		return nil, err
+	}
+
+	inStageTwo := newSet(stageTwo...)
+	levels := [][]*unstructured.Unstructured{}
+	for _, set := range sets {
+		level := lo.Filter(set, func(obj *unstructured.Unstructured, _ int) bool { return inStageTwo.Contains(obj) })
+		if len(level) > 0 {
+			levels = append(levels, level)
+		}
+	}
+
+	return levels, nil
+}
+
+// applyLevels applies stageTwo one dependency level at a time, waiting for each level to become ready before moving to
+// the next. The final level isn't waited on, that's left to the regular stage two wait
+func (r *Reconciler) applyLevels(
+	ctx context.Context,
+	stageOne []*unstructured.Unstructured,
+	stageTwo []*unstructured.Unstructured,
+	opts ApplyOpts,
+	result *Result,
+) error {
+	levels, err := dependencyLevels(stageOne, stageTwo)
+	if err != nil {
+		return fmt.Errorf("error computing dependency levels: %w", err)
+	}
+
+	for idx, level := range levels {
+		if err := checkpoint(ctx, nil); err != nil {
+			return err
+		}
+		r.log(ctx, "applying dependency level", "level", idx+1, "levels", len(levels))
+		if err := r.applyStage(ctx, level, opts, result); err != nil {
+			return fmt.Errorf("error applying dependency level %v: %w", idx+1, err)
+		}
+
+		if idx == len(levels)-1 {
+			break
+		}
+
+		r.log(ctx, "waiting for dependency level to reconcile", "level", idx+1)
+		toWait := withoutExternallyManaged(level)
+		err := r.wait(ctx, toWait, ssa.WaitOptions{
+			Interval: 2 * time.Second,
+			Timeout:  scaledWaitTimeout(opts, len(toWait)),
+		}, opts, result)
+		if err != nil {
+			return fmt.Errorf("error waiting for dependency level %v: %w", idx+1, err)
+		}
+	}
+
+	return nil
+}
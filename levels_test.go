@@ -0,0 +1,85 @@
+package goply
+
+import (
+	"testing"
+
+	"github.com/lithammer/dedent"
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestDependencyLevels(t *testing.T) {
+	objs, err := GetObjects(dedent.Dedent(`
+		---
+		apiVersion: v1
+		kind: Namespace
+		metadata:
+		  name: goply-test
+		---
+		apiVersion: apps/v1
+		kind: Deployment
+		metadata:
+		  name: app
+		  namespace: goply-test
+		  annotations:
+		    config.kubernetes.io/depends-on: /namespaces/goply-test/Service/db,/namespaces/goply-test/ConfigMap/config
+		---
+		apiVersion: v1
+		kind: Service
+		metadata:
+		  name: db
+		  namespace: goply-test
+		  annotations:
+		    config.kubernetes.io/depends-on: /namespaces/goply-test/ConfigMap/config
+		---
+		apiVersion: v1
+		kind: ConfigMap
+		metadata:
+		  name: config
+		  namespace: goply-test
+		---
+		apiVersion: v1
+		kind: ConfigMap
+		metadata:
+		  name: standalone
+		  namespace: goply-test
+	`)[1:])
+	require.NoError(t, err)
+
+	stageOne, stageTwo, _, err := getResourceStages(objs, stageOverrides{}, nil)
+	require.NoError(t, err)
+
+	levels, err := dependencyLevels(stageOne, stageTwo)
+	require.NoError(t, err)
+
+	names := lo.Map(levels, func(level []*unstructured.Unstructured, _ int) []string {
+		return lo.Map(level, func(u *unstructured.Unstructured, _ int) string { return u.GetName() })
+	})
+	require.Equal(t, [][]string{{"config", "standalone"}, {"db"}, {"app"}}, names)
+}
+
+func TestDependencyLevelsCycle(t *testing.T) {
+	objs, err := GetObjects(dedent.Dedent(`
+		---
+		apiVersion: v1
+		kind: ConfigMap
+		metadata:
+		  name: one
+		  namespace: goply-test
+		  annotations:
+		    config.kubernetes.io/depends-on: /namespaces/goply-test/ConfigMap/two
+		---
+		apiVersion: v1
+		kind: ConfigMap
+		metadata:
+		  name: two
+		  namespace: goply-test
+		  annotations:
+		    config.kubernetes.io/depends-on: /namespaces/goply-test/ConfigMap/one
+	`)[1:])
+	require.NoError(t, err)
+
+	_, err = dependencyLevels(nil, objs)
+	require.Error(t, err)
+}
@@ -0,0 +1,119 @@
+package goply
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// Keys goply attaches to its log lines. Object keys are only present on lines about a single object, stage only on
+// lines logged while reconciling one
+const (
+	LogKeyReconcileID = "reconcileId"
+	LogKeyStage       = "stage"
+	LogKeyAPIVersion  = "apiVersion"
+	LogKeyKind        = "kind"
+	LogKeyNamespace   = "namespace"
+	LogKeyName        = "name"
+)
+
+// Stages of a reconcile, as logged under LogKeyStage
+const (
+	StageOne   = "one"
+	StageTwo   = "two"
+	StagePrune = "prune"
+)
+
+type stageKey struct{}
+
+func withStage(ctx context.Context, stage string) context.Context {
+	return context.WithValue(ctx, stageKey{}, stage)
+}
+
+func stageFrom(ctx context.Context) string {
+	stage, _ := ctx.Value(stageKey{}).(string)
+	return stage
+}
+
+// SetLogFunc registers a func that receives each log line flattened to a string, as the message followed by its
+// key/value pairs
+func (r *Reconciler) SetLogFunc(f func(string)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.logFunc = f
+}
+
+// SetLogger registers a structured logger. Each line is logged at Info with the LogKey* fields as separate key/value
+// pairs, so it can be filtered by object or stage
+func (r *Reconciler) SetLogger(l logr.Logger) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.logger = &l
+}
+
+// log sends msg and keysAndValues to the registered logger and log func, along with the reconcile ID and stage from ctx
+func (r *Reconciler) log(ctx context.Context, msg string, keysAndValues ...any) {
+	r.mu.RLock()
+	logFunc, logger := r.logFunc, r.logger
+	r.mu.RUnlock()
+
+	if logFunc == nil && logger == nil {
+		return
+	}
+
+	id := reconcileIDFrom(ctx)
+	if stage := stageFrom(ctx); stage != "" {
+		keysAndValues = append([]any{LogKeyStage, stage}, keysAndValues...)
+	}
+
+	if logger != nil {
+		if id != "" {
+			logger.Info(msg, append([]any{LogKeyReconcileID, id}, keysAndValues...)...)
+		} else {
+			logger.Info(msg, keysAndValues...)
+		}
+	}
+	if logFunc != nil {
+		logFunc(flattenLog(id, msg, keysAndValues))
+	}
+}
+
+// flattenLog renders a log line as "[id] msg key=value ...", quoting values that wouldn't otherwise read back cleanly
+func flattenLog(id string, msg string, keysAndValues []any) string {
+	b := strings.Builder{}
+	if id != "" {
+		fmt.Fprintf(&b, "[%v] ", id)
+	}
+	b.WriteString(msg)
+
+	for i := 0; i < len(keysAndValues); i += 2 {
+		value := "<missing>"
+		if i+1 < len(keysAndValues) {
+			value = fmt.Sprint(keysAndValues[i+1])
+		}
+		if value == "" || strings.ContainsAny(value, " =\"") {
+			value = strconv.Quote(value)
+		}
+		fmt.Fprintf(&b, " %v=%v", keysAndValues[i], value)
+	}
+
+	return b.String()
+}
+
+// objectKV returns the log fields identifying obj
+func objectKV(obj *unstructured.Unstructured) []any {
+	kv := []any{LogKeyAPIVersion, obj.GetAPIVersion(), LogKeyKind, obj.GetKind()}
+	if obj.GetNamespace() != "" {
+		kv = append(kv, LogKeyNamespace, obj.GetNamespace())
+	}
+	return append(kv, LogKeyName, obj.GetName())
+}
+
+// itemKV returns the log fields identifying item
+func itemKV(item InventoryItem) []any {
+	return objectKV(item.stub())
+}
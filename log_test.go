@@ -0,0 +1,46 @@
+package goply
+
+import (
+	"context"
+	"testing"
+
+	"github.com/go-logr/logr/funcr"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestLog(t *testing.T) {
+	obj := &unstructured.Unstructured{}
+	obj.SetAPIVersion("v1")
+	obj.SetKind("ConfigMap")
+	obj.SetNamespace("some-ns")
+	obj.SetName("some-config")
+
+	r := offlineReconciler(t)
+
+	var flat string
+	r.SetLogFunc(func(s string) { flat = s })
+
+	structured := map[string]any{}
+	r.SetLogger(funcr.New(func(_, _ string) {}, funcr.Options{
+		RenderArgsHook: func(kvList []any) []any {
+			for i := 0; i < len(kvList); i += 2 {
+				structured[kvList[i].(string)] = kvList[i+1]
+			}
+			return kvList
+		},
+	}))
+
+	ctx := withStage(withReconcileID(context.Background(), "run-one"), StageTwo)
+	r.log(ctx, "forcing ownership of conflicting fields", append(objectKV(obj), "conflicts", ".data.foo")...)
+
+	require.Equal(t, `[run-one] forcing ownership of conflicting fields stage=two apiVersion=v1 kind=ConfigMap namespace=some-ns name=some-config conflicts=.data.foo`, flat)
+	require.Equal(t, "run-one", structured[LogKeyReconcileID])
+	require.Equal(t, StageTwo, structured[LogKeyStage])
+	require.Equal(t, "ConfigMap", structured[LogKeyKind])
+	require.Equal(t, "some-ns", structured[LogKeyNamespace])
+	require.Equal(t, "some-config", structured[LogKeyName])
+
+	r.log(context.Background(), "pruning resources", "reason", "some reason")
+	require.Equal(t, `pruning resources reason="some reason"`, flat)
+}
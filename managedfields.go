@@ -0,0 +1,46 @@
+package goply
+
+import (
+	"context"
+	"time"
+
+	"github.com/samber/lo"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// ManagedFieldsPruning selects stale managedFields entries to remove from applied objects: those last updated more than
+// MaxAge ago, and those belonging to any of Managers, typically tools that no longer touch the objects. goply's own
+// entry is always kept. Removing an entry leaves its fields in place, just no longer owned by that manager, which will
+// take them back if it ever writes them again
+type ManagedFieldsPruning struct {
+	MaxAge   time.Duration
+	Managers []string
+}
+
+func (p ManagedFieldsPruning) stale(entry metav1.ManagedFieldsEntry, now time.Time) bool {
+	if entry.Manager == fieldManager {
+		return false
+	}
+	if lo.Contains(p.Managers, entry.Manager) {
+		return true
+	}
+	return p.MaxAge > 0 && entry.Time != nil && now.Sub(entry.Time.Time) > p.MaxAge
+}
+
+// pruneManagedFields removes stale managedFields entries from every object
+func (r *Reconciler) pruneManagedFields(ctx context.Context, objects []*unstructured.Unstructured, pruning ManagedFieldsPruning) error {
+	now := time.Now()
+	for _, obj := range objects {
+		pruned, err := r.dropManagedFields(ctx, obj, func(entry metav1.ManagedFieldsEntry) bool {
+			return pruning.stale(entry, now)
+		})
+		if err != nil {
+			return err
+		}
+		if pruned {
+			r.log(ctx, "pruned stale managed fields entries", objectKV(obj)...)
+		}
+	}
+	return nil
+}
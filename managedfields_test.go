@@ -0,0 +1,27 @@
+package goply
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestManagedFieldsPruningStale(t *testing.T) {
+	now := time.Now()
+	entry := func(manager string, age time.Duration) metav1.ManagedFieldsEntry {
+		return metav1.ManagedFieldsEntry{Manager: manager, Time: &metav1.Time{Time: now.Add(-age)}}
+	}
+	pruning := ManagedFieldsPruning{MaxAge: 24 * time.Hour, Managers: []string{"old-tool"}}
+
+	require.True(t, pruning.stale(entry("old-tool", time.Minute), now))
+	require.True(t, pruning.stale(entry("kubectl-edit", 48*time.Hour), now))
+	require.False(t, pruning.stale(entry("kubectl-edit", time.Hour), now))
+	require.False(t, pruning.stale(metav1.ManagedFieldsEntry{Manager: "kubectl-edit"}, now))
+	// goply's own entry is never stale, however old
+	require.False(t, pruning.stale(entry(fieldManager, 48*time.Hour), now))
+
+	// Without MaxAge, only the listed managers are pruned
+	require.False(t, ManagedFieldsPruning{Managers: []string{"old-tool"}}.stale(entry("kubectl-edit", 48*time.Hour), now))
+}
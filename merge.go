@@ -0,0 +1,85 @@
+package goply
+
+import (
+	"fmt"
+
+	ssautils "github.com/fluxcd/pkg/ssa/utils"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/strategicpatch"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/cli-utils/pkg/object"
+)
+
+// MergeObjects layers sources on top of each other, for a base manifest plus overlays without reaching for kustomize.
+// Objects are matched across sources by group, kind, namespace and name, and each later source is merged into what
+// came before it:
+//
+//   - Built-in kinds are merged the same way as a strategic merge patch, so lists such as a pod's containers are
+//     merged by key (containers by name, ports by containerPort, and so on) rather than replaced. Any $patch directives
+//     in an overlay are honored
+//   - Every other kind is merged as a JSON merge patch: maps are merged recursively, while lists and scalar values are
+//     replaced wholesale
+//   - Either way, a null value in a later source removes the field
+//
+// The result holds each object once, in the order it first appears, and none of the sources are modified
+func MergeObjects(sources ...[]*unstructured.Unstructured) ([]*unstructured.Unstructured, error) {
+	merged := []*unstructured.Unstructured{}
+	byID := map[object.ObjMetadata]int{}
+
+	for _, source := range sources {
+		for _, obj := range source {
+			id := toInventoryItem(obj).ObjMetadata
+			idx, ok := byID[id]
+			if !ok {
+				byID[id] = len(merged)
+				merged = append(merged, obj.DeepCopy())
+				continue
+			}
+
+			result, err := mergeObject(merged[idx], obj)
+			if err != nil {
+				return nil, fmt.Errorf("error merging %v: %w", ssautils.FmtUnstructured(obj), err)
+			}
+			merged[idx] = result
+		}
+	}
+
+	return merged, nil
+}
+
+func mergeObject(base *unstructured.Unstructured, overlay *unstructured.Unstructured) (*unstructured.Unstructured, error) {
+	patch := overlay.DeepCopy().Object
+
+	typed, err := scheme.Scheme.New(overlay.GroupVersionKind())
+	if err != nil {
+		return &unstructured.Unstructured{Object: jsonMerge(base.DeepCopy().Object, patch)}, nil
+	}
+
+	result, err := strategicpatch.StrategicMergeMapPatch(base.DeepCopy().Object, patch, typed)
+	if err != nil {
+		return nil, err
+	}
+	return &unstructured.Unstructured{Object: result}, nil
+}
+
+// jsonMerge applies patch to base as an RFC 7386 JSON merge patch, modifying base in place
+func jsonMerge(base map[string]any, patch map[string]any) map[string]any {
+	for key, value := range patch {
+		if value == nil {
+			delete(base, key)
+			continue
+		}
+
+		patchMap, ok := value.(map[string]any)
+		if !ok {
+			base[key] = value
+			continue
+		}
+		baseMap, ok := base[key].(map[string]any)
+		if !ok {
+			baseMap = map[string]any{}
+		}
+		base[key] = jsonMerge(baseMap, patchMap)
+	}
+	return base
+}
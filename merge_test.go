@@ -0,0 +1,145 @@
+package goply
+
+import (
+	"testing"
+
+	"github.com/lithammer/dedent"
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestMergeObjectsContainerImage(t *testing.T) {
+	base, err := GetObjects(dedent.Dedent(`
+		---
+		apiVersion: apps/v1
+		kind: Deployment
+		metadata:
+		  name: app
+		  namespace: goply-test
+		spec:
+		  replicas: 1
+		  template:
+		    spec:
+		      containers:
+		      - name: app
+		        image: app:1.0
+		        ports:
+		        - containerPort: 8080
+		      - name: sidecar
+		        image: sidecar:1.0
+		---
+		apiVersion: v1
+		kind: ConfigMap
+		metadata:
+		  name: config
+		  namespace: goply-test
+		data:
+		  foo: bar
+	`)[1:])
+	require.NoError(t, err)
+	staging, err := GetObjects(dedent.Dedent(`
+		---
+		apiVersion: apps/v1
+		kind: Deployment
+		metadata:
+		  name: app
+		  namespace: goply-test
+		spec:
+		  replicas: 2
+		  template:
+		    spec:
+		      containers:
+		      - name: app
+		        image: app:1.1
+	`)[1:])
+	require.NoError(t, err)
+	prod, err := GetObjects(dedent.Dedent(`
+		---
+		apiVersion: apps/v1
+		kind: Deployment
+		metadata:
+		  name: app
+		  namespace: goply-test
+		spec:
+		  template:
+		    spec:
+		      containers:
+		      - name: app
+		        image: app:2.0
+	`)[1:])
+	require.NoError(t, err)
+
+	merged, err := MergeObjects(base, staging, prod)
+	require.NoError(t, err)
+	require.Equal(t, []string{"Deployment", "ConfigMap"}, lo.Map(merged, func(u *unstructured.Unstructured, _ int) string { return u.GetKind() }))
+
+	containers, _, err := unstructured.NestedSlice(merged[0].Object, "spec", "template", "spec", "containers")
+	require.NoError(t, err)
+	images := lo.SliceToMap(containers, func(c any) (string, any) {
+		container := c.(map[string]any)
+		return container["name"].(string), container["image"]
+	})
+	// The last layer wins, and containers it doesn't mention are left alone
+	require.Equal(t, map[string]any{"app": "app:2.0", "sidecar": "sidecar:1.0"}, images)
+
+	ports, _, _ := unstructured.NestedSlice(containers[0].(map[string]any), "ports")
+	require.Len(t, ports, 1)
+
+	replicas, _, _ := unstructured.NestedInt64(merged[0].Object, "spec", "replicas")
+	require.Equal(t, int64(2), replicas)
+
+	// None of the sources were touched
+	image, _, _ := unstructured.NestedSlice(base[0].Object, "spec", "template", "spec", "containers")
+	require.Equal(t, "app:1.0", image[0].(map[string]any)["image"])
+}
+
+func TestMergeObjectsJSONMergeFallback(t *testing.T) {
+	base, err := GetObjects(dedent.Dedent(`
+		---
+		apiVersion: example.com/v1
+		kind: Widget
+		metadata:
+		  name: widget
+		  labels:
+		    tier: base
+		    remove: me
+		spec:
+		  sizes: [small, medium]
+		  colors:
+		    primary: red
+		    secondary: blue
+	`)[1:])
+	require.NoError(t, err)
+	overlay, err := GetObjects(dedent.Dedent(`
+		---
+		apiVersion: example.com/v1
+		kind: Widget
+		metadata:
+		  name: widget
+		  labels:
+		    remove: null
+		spec:
+		  sizes: [large]
+		  colors:
+		    primary: green
+		---
+		apiVersion: example.com/v1
+		kind: Widget
+		metadata:
+		  name: another
+	`)[1:])
+	require.NoError(t, err)
+
+	merged, err := MergeObjects(base, overlay)
+	require.NoError(t, err)
+	require.Len(t, merged, 2)
+
+	require.Equal(t, map[string]string{"tier": "base"}, merged[0].GetLabels())
+	// Lists are replaced, maps are merged
+	require.Equal(t, map[string]any{
+		"sizes":  []any{"large"},
+		"colors": map[string]any{"primary": "green", "secondary": "blue"},
+	}, merged[0].Object["spec"])
+	require.Equal(t, "another", merged[1].GetName())
+}
@@ -0,0 +1,47 @@
+package goply
+
+import (
+	"context"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// preserveMetadata merges the labels and annotations each object currently has in the cluster into the object, for any
+// key the manifest doesn't set itself. Objects that don't exist yet, or can't be read, are left for the apply to deal
+// with
+func (r *Reconciler) preserveMetadata(ctx context.Context, objects []*unstructured.Unstructured, opts ApplyOpts) {
+	for _, obj := range objects {
+		live := &metav1.PartialObjectMetadata{}
+		live.SetGroupVersionKind(obj.GroupVersionKind())
+		if err := r.mgr.Client().Get(withCachedReads(ctx), client.ObjectKeyFromObject(obj), live); err != nil {
+			continue
+		}
+
+		liveAnnotations := live.GetAnnotations()
+		if opts.MigrateToServerSide {
+			// That's being cleaned up, preserving it would undo the migration
+			delete(liveAnnotations, corev1.LastAppliedConfigAnnotation)
+		}
+
+		obj.SetLabels(mergeMissing(obj.GetLabels(), live.GetLabels()))
+		obj.SetAnnotations(mergeMissing(obj.GetAnnotations(), liveAnnotations))
+	}
+}
+
+// mergeMissing returns desired with every key from live that it doesn't already have
+func mergeMissing(desired map[string]string, live map[string]string) map[string]string {
+	if len(live) == 0 {
+		return desired
+	}
+	merged := make(map[string]string, len(desired)+len(live))
+	for k, v := range live {
+		merged[k] = v
+	}
+	for k, v := range desired {
+		merged[k] = v
+	}
+	return merged
+}
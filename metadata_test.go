@@ -0,0 +1,16 @@
+package goply
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMergeMissing(t *testing.T) {
+	require.Equal(t,
+		map[string]string{"app": "goply", "team": "platform", "owner": "someone-else"},
+		mergeMissing(map[string]string{"app": "goply", "team": "platform"}, map[string]string{"team": "other", "owner": "someone-else"}),
+	)
+	require.Nil(t, mergeMissing(nil, nil))
+	require.Equal(t, map[string]string{"owner": "someone-else"}, mergeMissing(nil, map[string]string{"owner": "someone-else"}))
+}
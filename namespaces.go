@@ -0,0 +1,134 @@
+package goply
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/samber/lo"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+var systemNamespaces = newSet(
+	metav1.NamespaceDefault,
+	metav1.NamespaceSystem,
+	metav1.NamespacePublic,
+	corev1.NamespaceNodeLease,
+)
+
+// PruneOrphanedNamespaces deletes namespaces that were in previous but aren't part of keep, that goply applied, and
+// that are empty apart from what Kubernetes creates in every namespace. A namespace holding anything else, whoever
+// manages it, is left alone, as is any namespace previous doesn't know about, so other manifests' namespaces are never
+// touched. When owner is set, namespaces also have to carry its labels. Externally managed namespaces are never pruned
+func (r *Reconciler) PruneOrphanedNamespaces(ctx context.Context, previous Inventory, keep Inventory, owner *Owner, opts DeleteOpts) (DeleteResult, error) {
+	return r.pruneOrphanedNamespaces(ctx, previous, keep, owner, nil, opts)
+}
+
+// pruneOrphanedNamespaces is PruneOrphanedNamespaces limited to the namespaces in scope, if there are any
+func (r *Reconciler) pruneOrphanedNamespaces(ctx context.Context, previous Inventory, keep Inventory, owner *Owner, scope []string, opts DeleteOpts) (DeleteResult, error) {
+	orphans, err := r.orphanedNamespaces(ctx, previous, keep, owner)
+	if err != nil {
+		return DeleteResult{}, err
+	}
+	orphans = lo.Filter(orphans, func(ns *unstructured.Unstructured, _ int) bool { return inNamespaceScope(ns, scope) })
+	if len(orphans) == 0 {
+		return DeleteResult{}, nil
+	}
+
+	return r.delete(ctx, orphans, opts)
+}
+
+func (r *Reconciler) orphanedNamespaces(ctx context.Context, previous Inventory, keep Inventory, owner *Owner) ([]*unstructured.Unstructured, error) {
+	previousNamespaces := newSet[string]()
+	kept := keep.Index()
+	for _, item := range previous.Items {
+		if isNamespace(item) && !item.ExternallyManaged && !kept.Contains(item.ObjMetadata) {
+			previousNamespaces.Add(item.Name)
+		}
+	}
+	if len(previousNamespaces.data) == 0 {
+		return nil, nil
+	}
+
+	listOpts := []client.ListOption{}
+	if owner != nil {
+		listOpts = append(listOpts, client.MatchingLabels(r.mgr.GetOwnerLabels(owner.Name, owner.Namespace)))
+	}
+
+	namespaces := &corev1.NamespaceList{}
+	if err := r.mgr.Client().List(ctx, namespaces, listOpts...); err != nil {
+		return nil, fmt.Errorf("error listing namespaces: %w", err)
+	}
+
+	candidates := lo.Filter(namespaces.Items, func(ns corev1.Namespace, _ int) bool {
+		return previousNamespaces.Contains(ns.Name) &&
+			!systemNamespaces.Contains(ns.Name) &&
+			ns.Status.Phase != corev1.NamespaceTerminating &&
+			lo.ContainsBy(ns.ManagedFields, func(m metav1.ManagedFieldsEntry) bool { return m.Manager == fieldManager })
+	})
+	if len(candidates) == 0 {
+		return nil, nil
+	}
+
+	resources, err := r.listableResources()
+	if err != nil {
+		return nil, err
+	}
+	resources = lo.Filter(resources, func(l listableResource, _ int) bool { return l.Namespaced })
+
+	orphans := []*unstructured.Unstructured{}
+	for _, ns := range candidates {
+		empty, err := r.namespaceIsEmpty(ctx, ns.Name, resources)
+		if err != nil {
+			return nil, err
+		}
+		if !empty {
+			continue
+		}
+
+		obj := &unstructured.Unstructured{}
+		obj.SetGroupVersionKind(corev1.SchemeGroupVersion.WithKind("Namespace"))
+		obj.SetName(ns.Name)
+		orphans = append(orphans, obj)
+	}
+
+	return orphans, nil
+}
+
+func (r *Reconciler) namespaceIsEmpty(ctx context.Context, namespace string, resources []listableResource) (bool, error) {
+	for _, resource := range resources {
+		list := &metav1.PartialObjectMetadataList{}
+		list.SetGroupVersionKind(resource.listGVK())
+		if err := r.mgr.Client().List(ctx, list, client.InNamespace(namespace)); err != nil {
+			return false, fmt.Errorf("error listing %v in %v: %w", resource.GroupVersionKind, namespace, err)
+		}
+
+		if lo.ContainsBy(list.Items, func(item metav1.PartialObjectMetadata) bool { return !isNamespaceDefault(resource.GroupKind(), item.Name) }) {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+func isNamespace(item InventoryItem) bool {
+	return item.GroupKind == schema.GroupKind{Kind: "Namespace"}
+}
+
+// isNamespaceDefault reports whether an object is one Kubernetes puts in every namespace by itself, which doesn't stop
+// the namespace from counting as empty
+func isNamespaceDefault(gk schema.GroupKind, name string) bool {
+	switch {
+	case gk == schema.GroupKind{Kind: "Event"}, gk == schema.GroupKind{Group: "events.k8s.io", Kind: "Event"}:
+		return true
+	case gk == schema.GroupKind{Kind: "ConfigMap"} && name == "kube-root-ca.crt":
+		return true
+	case gk == schema.GroupKind{Kind: "ServiceAccount"} && name == "default":
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,63 @@
+package goply
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/fluxcd/pkg/ssa/normalize"
+	ssautils "github.com/fluxcd/pkg/ssa/utils"
+	"github.com/samber/lo"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/kubernetes/scheme"
+)
+
+type NormalizePolicy string
+
+const (
+	// NormalizePolicyFail fails the reconcile, naming the object that couldn't be normalized. This is also what the
+	// zero value does
+	NormalizePolicyFail NormalizePolicy = "Fail"
+	// NormalizePolicySkip leaves objects that can't be normalized out of the reconcile, with a warning event for each,
+	// and carries on with the rest
+	NormalizePolicySkip NormalizePolicy = "Skip"
+)
+
+const (
+	EventReasonNormalizeFailed = "NormalizeFailed"
+)
+
+// normalizeObject is normalize.Unstructured, except an object of a built-in kind that doesn't decode into its typed
+// form is an error. normalize.Unstructured quietly leaves those alone, and they'd only fail later on, at the API server
+func normalizeObject(obj *unstructured.Unstructured) error {
+	if scheme.Scheme.Recognizes(obj.GroupVersionKind()) {
+		if _, err := normalize.FromUnstructured(obj); err != nil {
+			return err
+		}
+	}
+	return normalize.Unstructured(obj)
+}
+
+// skipUnnormalizable filters out objects that fail normalization, recording them as skipped. They're kept in the
+// inventory, a broken document shouldn't get its live object pruned
+func (r *Reconciler) skipUnnormalizable(ctx context.Context, objects []*unstructured.Unstructured, result *Result) []*unstructured.Unstructured {
+	return lo.Filter(objects, func(obj *unstructured.Unstructured, _ int) bool {
+		err := normalizeObject(obj)
+		if err == nil {
+			return true
+		}
+
+		item := toInventoryItem(obj)
+		r.event(ctx, Event{
+			Type:    EventTypeWarning,
+			Reason:  EventReasonNormalizeFailed,
+			Object:  &item,
+			Message: fmt.Sprintf("skipping %v, it could not be normalized: %v", ssautils.FmtUnstructured(obj), err),
+		})
+		result.Skipped = append(result.Skipped, SkippedItem{
+			InventoryItem: item,
+			Reason:        SkipReasonNormalizeFailed,
+		})
+		result.Inventory.Items = append(result.Inventory.Items, item)
+		return false
+	})
+}
@@ -0,0 +1,81 @@
+package goply
+
+import (
+	"context"
+	"testing"
+
+	"github.com/lithammer/dedent"
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+const unnormalizableYaml = `
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: config-one
+  namespace: goply-test
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: deploy-one
+  namespace: goply-test
+spec:
+  replicas: lots
+`
+
+func TestNormalizeFailure(t *testing.T) {
+	t.Run("fail", func(t *testing.T) {
+		objs, err := GetObjects(dedent.Dedent(unnormalizableYaml)[1:])
+		require.NoError(t, err)
+
+		_, _, _, err = getResourceStages(objs, stageOverrides{}, nil)
+		require.ErrorContains(t, err, "error setting defaults on Deployment/goply-test/deploy-one")
+	})
+
+	t.Run("skip", func(t *testing.T) {
+		r := offlineReconciler(t)
+		objs, err := GetObjects(dedent.Dedent(unnormalizableYaml)[1:])
+		require.NoError(t, err)
+
+		result := Result{}
+		kept := r.skipUnnormalizable(context.Background(), objs, &result)
+		require.Equal(t, []string{"config-one"}, lo.Map(kept, func(u *unstructured.Unstructured, _ int) string { return u.GetName() }))
+		require.Equal(t, []SkippedItem{{InventoryItem: toInventoryItem(objs[1]), Reason: SkipReasonNormalizeFailed}}, result.Skipped)
+		require.Equal(t, 1, len(result.Inventory.Items))
+	})
+}
+
+func TestNormalizeSkipAfterTransformers(t *testing.T) {
+	// Breaks the Deployment, counting how many times each object is transformed
+	transformed := map[string]int{}
+	r, err := NewReconciler(&ReconcilerConfig{
+		Kubeconfig: offlineKubeconfig,
+		Transformers: []Transformer{TransformerFunc(func(obj *unstructured.Unstructured) error {
+			transformed[obj.GetName()]++
+			if obj.GetKind() == "Deployment" {
+				return unstructured.SetNestedField(obj.Object, "lots", "spec", "replicas")
+			}
+			return nil
+		})},
+	})
+	require.NoError(t, err)
+
+	result, err := r.ReconcileContext(context.Background(), dedent.Dedent(`
+		---
+		apiVersion: apps/v1
+		kind: Deployment
+		metadata:
+		  name: deploy-one
+		  namespace: goply-test
+		spec:
+		  replicas: 1
+	`)[1:], ApplyOpts{NormalizePolicy: NormalizePolicySkip}, nil)
+	require.NoError(t, err)
+	require.Equal(t, []SkippedItem{{InventoryItem: result.Inventory.Items[0], Reason: SkipReasonNormalizeFailed}}, result.Skipped)
+	require.Equal(t, "deploy-one", result.Skipped[0].Name)
+	require.Equal(t, map[string]int{"deploy-one": 1}, transformed)
+}
@@ -0,0 +1,79 @@
+package goply
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/samber/lo"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+var ErrUnsupportedApplyOrderError = errors.New("unsupported apply order")
+
+type ApplyOrder string
+
+const (
+	// ApplyOrderDefault applies all of stage two at once, in the resource manager's own kind ordering
+	ApplyOrderDefault ApplyOrder = ""
+	// ApplyOrderTiered applies stage two in tiers, one after the other: ServiceAccounts, Secrets and ConfigMaps first,
+	// then RBAC, then everything else. Namespaces and CRDs are always applied before all of them, in stage one
+	ApplyOrderTiered ApplyOrder = "Tiered"
+)
+
+var (
+	tierOneKinds = []schema.GroupKind{
+		{Kind: "ServiceAccount"},
+		{Kind: "Secret"},
+		{Kind: "ConfigMap"},
+	}
+	tierTwoKinds = []schema.GroupKind{
+		{Group: "rbac.authorization.k8s.io", Kind: "ClusterRole"},
+		{Group: "rbac.authorization.k8s.io", Kind: "ClusterRoleBinding"},
+		{Group: "rbac.authorization.k8s.io", Kind: "Role"},
+		{Group: "rbac.authorization.k8s.io", Kind: "RoleBinding"},
+	}
+)
+
+// applyTiers splits objects into ApplyOrderTiered's tiers and applies each one in turn, skipping empty tiers
+func (r *Reconciler) applyTiers(ctx context.Context, objects []*unstructured.Unstructured, opts ApplyOpts, result *Result) error {
+	tiers := kindTiers(objects)
+
+	for idx, tier := range tiers {
+		if err := checkpoint(ctx, nil); err != nil {
+			return err
+		}
+		r.log(ctx, "applying tier", "tier", idx+1, "tiers", len(tiers))
+		if err := r.applyStage(ctx, tier, opts, result); err != nil {
+			return fmt.Errorf("error applying tier %v: %w", idx+1, err)
+		}
+	}
+
+	return nil
+}
+
+// kindTiers groups objects by tier, keeping their order within each tier and dropping empty tiers
+func kindTiers(objects []*unstructured.Unstructured) [][]*unstructured.Unstructured {
+	tiers := make([][]*unstructured.Unstructured, 3)
+	for _, obj := range objects {
+		gk := obj.GroupVersionKind().GroupKind()
+		switch {
+		case lo.Contains(tierOneKinds, gk):
+			tiers[0] = append(tiers[0], obj)
+		case lo.Contains(tierTwoKinds, gk):
+			tiers[1] = append(tiers[1], obj)
+		default:
+			tiers[2] = append(tiers[2], obj)
+		}
+	}
+
+	return lo.Filter(tiers, func(tier []*unstructured.Unstructured, _ int) bool { return len(tier) > 0 })
+}
+
+func (o ApplyOrder) validate() error {
+	if o != ApplyOrderDefault && o != ApplyOrderTiered {
+		return fmt.Errorf("%w %q", ErrUnsupportedApplyOrderError, o)
+	}
+	return nil
+}
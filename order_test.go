@@ -0,0 +1,72 @@
+package goply
+
+import (
+	"testing"
+
+	"github.com/lithammer/dedent"
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestKindTiers(t *testing.T) {
+	objs, err := GetObjects(dedent.Dedent(`
+		---
+		apiVersion: apps/v1
+		kind: Deployment
+		metadata:
+		  name: app
+		  namespace: goply-test
+		---
+		apiVersion: rbac.authorization.k8s.io/v1
+		kind: RoleBinding
+		metadata:
+		  name: app
+		  namespace: goply-test
+		---
+		apiVersion: v1
+		kind: Secret
+		metadata:
+		  name: creds
+		  namespace: goply-test
+		---
+		apiVersion: v1
+		kind: ServiceAccount
+		metadata:
+		  name: app
+		  namespace: goply-test
+		---
+		apiVersion: v1
+		kind: Service
+		metadata:
+		  name: app
+		  namespace: goply-test
+		---
+		apiVersion: v1
+		kind: ConfigMap
+		metadata:
+		  name: config
+		  namespace: goply-test
+	`)[1:])
+	require.NoError(t, err)
+
+	kinds := func(tier []*unstructured.Unstructured) []string {
+		return lo.Map(tier, func(obj *unstructured.Unstructured, _ int) string { return obj.GetKind() })
+	}
+
+	tiers := kindTiers(objs)
+	require.Len(t, tiers, 3)
+	require.Equal(t, []string{"Secret", "ServiceAccount", "ConfigMap"}, kinds(tiers[0]))
+	require.Equal(t, []string{"RoleBinding"}, kinds(tiers[1]))
+	require.Equal(t, []string{"Deployment", "Service"}, kinds(tiers[2]))
+
+	// Empty tiers are dropped
+	tiers = kindTiers(lo.Filter(objs, func(obj *unstructured.Unstructured, _ int) bool { return obj.GetKind() != "RoleBinding" }))
+	require.Len(t, tiers, 2)
+	require.Equal(t, []string{"Deployment", "Service"}, kinds(tiers[1]))
+}
+
+func TestUnsupportedApplyOrder(t *testing.T) {
+	_, err := offlineReconciler(t).Reconcile("", ApplyOpts{ApplyOrder: "Alphabetical"}, nil)
+	require.ErrorIs(t, err, ErrUnsupportedApplyOrderError)
+}
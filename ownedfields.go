@@ -0,0 +1,113 @@
+package goply
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/fluxcd/pkg/ssa"
+	ssautils "github.com/fluxcd/pkg/ssa/utils"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/cli-utils/pkg/object"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/structured-merge-diff/v4/fieldpath"
+)
+
+// OwnedFieldsChange is how the set of fields goply owns on an object changed over an apply. Fields are formatted the
+// same way the API server formats conflicts, e.g. .data.foo. Lost fields are usually ones dropped from the manifest,
+// but a field that keeps turning up as gained on every apply is being taken away in between, by something else
+type OwnedFieldsChange struct {
+	InventoryItem
+	Gained []string
+	Lost   []string
+}
+
+// ownedFields reads the fields goply currently owns on each object that exists. Objects that don't exist yet, or that
+// goply has never applied, are left out
+func (r *Reconciler) ownedFields(ctx context.Context, objects []*unstructured.Unstructured) (map[object.ObjMetadata]*fieldpath.Set, error) {
+	owned := make(map[object.ObjMetadata]*fieldpath.Set, len(objects))
+
+	for _, obj := range objects {
+		// Never from the cache, it has to reflect the apply that just happened
+		live := &metav1.PartialObjectMetadata{}
+		live.SetGroupVersionKind(obj.GroupVersionKind())
+		err := r.mgr.Client().Get(ctx, client.ObjectKeyFromObject(obj), live)
+		if apierrors.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error reading managed fields of %v: %w", ssautils.FmtUnstructured(obj), err)
+		}
+
+		fields, err := goplyFields(live.GetManagedFields())
+		if err != nil {
+			return nil, fmt.Errorf("error decoding managed fields of %v: %w", ssautils.FmtUnstructured(obj), err)
+		}
+		if fields != nil {
+			owned[toInventoryItem(obj).ObjMetadata] = fields
+		}
+	}
+
+	return owned, nil
+}
+
+// goplyFields returns the fields owned by goply's applies in entries, or nil if goply has never applied the object
+func goplyFields(entries []metav1.ManagedFieldsEntry) (*fieldpath.Set, error) {
+	var fields *fieldpath.Set
+	for _, entry := range entries {
+		if entry.Manager != fieldManager || entry.Operation != metav1.ManagedFieldsOperationApply ||
+			entry.Subresource != "" || entry.FieldsV1 == nil {
+			continue
+		}
+		set, err := ssa.FieldsToSet(*entry.FieldsV1)
+		if err != nil {
+			return nil, err
+		}
+		if fields == nil {
+			fields = &fieldpath.Set{}
+		}
+		fields = fields.Union(&set)
+	}
+	return fields, nil
+}
+
+// ownedFieldsChanges compares what goply owned on each object before and after an apply, in the order of objects.
+// Objects goply didn't own anything on beforehand, and objects whose fields didn't change, are left out
+func ownedFieldsChanges(objects []*unstructured.Unstructured, before map[object.ObjMetadata]*fieldpath.Set, after map[object.ObjMetadata]*fieldpath.Set) []OwnedFieldsChange {
+	changes := []OwnedFieldsChange{}
+
+	for _, obj := range objects {
+		item := toInventoryItem(obj)
+		was, ok := before[item.ObjMetadata]
+		if !ok {
+			continue
+		}
+		now, ok := after[item.ObjMetadata]
+		if !ok {
+			now = &fieldpath.Set{}
+		}
+
+		change := OwnedFieldsChange{
+			InventoryItem: item,
+			Gained:        fieldPaths(now.Difference(was)),
+			Lost:          fieldPaths(was.Difference(now)),
+		}
+		if len(change.Gained) > 0 || len(change.Lost) > 0 {
+			changes = append(changes, change)
+		}
+	}
+
+	return changes
+}
+
+// fieldPaths lists the leaves of set, sorted. Parents are left out, they're implied by their children
+func fieldPaths(set *fieldpath.Set) []string {
+	paths := []string{}
+	set.Leaves().Iterate(func(p fieldpath.Path) {
+		paths = append(paths, p.String())
+	})
+	sort.Strings(paths)
+	return paths
+}
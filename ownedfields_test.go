@@ -0,0 +1,63 @@
+package goply
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/cli-utils/pkg/object"
+	"sigs.k8s.io/structured-merge-diff/v4/fieldpath"
+)
+
+func TestGoplyFields(t *testing.T) {
+	fields, err := goplyFields([]metav1.ManagedFieldsEntry{
+		{Manager: "kubectl-edit", Operation: metav1.ManagedFieldsOperationUpdate, FieldsV1: &metav1.FieldsV1{Raw: []byte(`{"f:data":{"f:other":{}}}`)}},
+		// An update under goply's name, such as a metadata patch, isn't part of what it applies
+		{Manager: fieldManager, Operation: metav1.ManagedFieldsOperationUpdate, FieldsV1: &metav1.FieldsV1{Raw: []byte(`{"f:metadata":{"f:annotations":{}}}`)}},
+	})
+	require.NoError(t, err)
+	require.Nil(t, fields)
+
+	fields, err = goplyFields([]metav1.ManagedFieldsEntry{
+		{Manager: fieldManager, Operation: metav1.ManagedFieldsOperationApply, FieldsV1: &metav1.FieldsV1{Raw: []byte(`{"f:data":{"f:foo":{}}}`)}},
+	})
+	require.NoError(t, err)
+	require.Equal(t, []string{".data.foo"}, fieldPaths(fields))
+}
+
+func TestOwnedFieldsChanges(t *testing.T) {
+	cm := func(name string) *unstructured.Unstructured {
+		obj := &unstructured.Unstructured{}
+		obj.SetAPIVersion("v1")
+		obj.SetKind("ConfigMap")
+		obj.SetNamespace("default")
+		obj.SetName(name)
+		return obj
+	}
+	fields := func(raw string) *fieldpath.Set {
+		set, err := goplyFields([]metav1.ManagedFieldsEntry{
+			{Manager: fieldManager, Operation: metav1.ManagedFieldsOperationApply, FieldsV1: &metav1.FieldsV1{Raw: []byte(raw)}},
+		})
+		require.NoError(t, err)
+		return set
+	}
+
+	changed, unchanged, created := cm("changed"), cm("unchanged"), cm("created")
+	objects := []*unstructured.Unstructured{changed, unchanged, created}
+	id := func(obj *unstructured.Unstructured) object.ObjMetadata { return toInventoryItem(obj).ObjMetadata }
+
+	before := map[object.ObjMetadata]*fieldpath.Set{
+		id(changed):   fields(`{"f:data":{"f:foo":{},"f:bar":{}}}`),
+		id(unchanged): fields(`{"f:data":{"f:foo":{}}}`),
+	}
+	after := map[object.ObjMetadata]*fieldpath.Set{
+		id(changed):   fields(`{"f:data":{"f:foo":{},"f:baz":{}}}`),
+		id(unchanged): fields(`{"f:data":{"f:foo":{}}}`),
+		id(created):   fields(`{"f:data":{"f:foo":{}}}`),
+	}
+
+	require.Equal(t, []OwnedFieldsChange{
+		{InventoryItem: toInventoryItem(changed), Gained: []string{".data.baz"}, Lost: []string{".data.bar"}},
+	}, ownedFieldsChanges(objects, before, after))
+}
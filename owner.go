@@ -0,0 +1,129 @@
+package goply
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/samber/lo"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
+	"sigs.k8s.io/cli-utils/pkg/object"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+var ErrNoOwnerError = errors.New("an owner is required")
+
+// Owner identifies a set of objects managed together. Objects applied with an owner are labelled goply/name and
+// goply/namespace, so the set can later be found in the cluster without a stored inventory
+type Owner struct {
+	Name      string
+	Namespace string
+}
+
+// LiveInventory builds an inventory of every object in the cluster carrying owner's labels, across every kind the
+// API server can list
+func (r *Reconciler) LiveInventory(ctx context.Context, owner Owner) (Inventory, error) {
+	return r.ListOwned(ctx, r.mgr.GetOwnerLabels(owner.Name, owner.Namespace))
+}
+
+// ListOwned builds an inventory of every object in the cluster carrying all of labels, across every kind the API
+// server can list. Paired with ApplyOpts.ManagementLabels it finds everything a manifest applied
+func (r *Reconciler) ListOwned(ctx context.Context, labels map[string]string) (Inventory, error) {
+	resources, err := r.listableResources()
+	if err != nil {
+		return Inventory{}, err
+	}
+
+	selector := client.MatchingLabels(labels)
+
+	inv := Inventory{}
+	for _, resource := range resources {
+		list := &metav1.PartialObjectMetadataList{}
+		list.SetGroupVersionKind(resource.listGVK())
+		if err := r.mgr.Client().List(ctx, list, selector); err != nil {
+			return Inventory{}, fmt.Errorf("error listing %v: %w", resource.GroupVersionKind, err)
+		}
+
+		for _, item := range list.Items {
+			if r.isIgnored(resource.GroupKind(), item.Namespace, item.Name, item.Labels) {
+				continue
+			}
+			inv.Items = append(inv.Items, InventoryItem{
+				ObjMetadata: object.ObjMetadata{
+					Namespace: item.Namespace,
+					Name:      item.Name,
+					GroupKind: resource.GroupKind(),
+				},
+				GroupVersion:      resource.Version,
+				ExternallyManaged: isExternallyManaged(item.Annotations),
+			})
+		}
+	}
+
+	return inv, nil
+}
+
+type listableResource struct {
+	schema.GroupVersionKind
+	Namespaced bool
+}
+
+// stampLabels sets labels on every object, on top of whatever labels they already have
+func stampLabels(objects []*unstructured.Unstructured, labels map[string]string) {
+	for _, obj := range objects {
+		objLabels := obj.GetLabels()
+		if objLabels == nil {
+			objLabels = make(map[string]string, len(labels))
+		}
+		for k, v := range labels {
+			objLabels[k] = v
+		}
+		obj.SetLabels(objLabels)
+	}
+}
+
+func (l listableResource) listGVK() schema.GroupVersionKind {
+	return l.GroupVersion().WithKind(l.Kind + "List")
+}
+
+// listableResources returns the preferred version of every kind the API server can list
+func (r *Reconciler) listableResources() ([]listableResource, error) {
+	if r.cache != nil {
+		if resources, ok := r.cache.resources.get(struct{}{}); ok {
+			return resources, nil
+		}
+	}
+
+	resourceLists, err := r.discovery.ServerPreferredResources()
+	if err != nil && !discovery.IsGroupDiscoveryFailedError(err) {
+		return nil, fmt.Errorf("error discovering resource types: %w", err)
+	}
+
+	resources := []listableResource{}
+	for _, resourceList := range resourceLists {
+		gv, err := schema.ParseGroupVersion(resourceList.GroupVersion)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing group version %v: %w", resourceList.GroupVersion, err)
+		}
+
+		for _, resource := range resourceList.APIResources {
+			// Subresources can't be listed on their own
+			if strings.Contains(resource.Name, "/") || !lo.Contains(resource.Verbs, "list") {
+				continue
+			}
+			resources = append(resources, listableResource{
+				GroupVersionKind: gv.WithKind(resource.Kind),
+				Namespaced:       resource.Namespaced,
+			})
+		}
+	}
+
+	if r.cache != nil {
+		r.cache.resources.set(struct{}{}, resources)
+	}
+	return resources, nil
+}
@@ -0,0 +1,74 @@
+package goply
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	ssautils "github.com/fluxcd/pkg/ssa/utils"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+var ErrUnsupportedPatchStrategyError = errors.New("unsupported patch strategy")
+
+// applyPatched creates or patches the objects whose kind has an entry in PatchStrategies, recording what was done, and
+// returns the remaining objects, which still need to be server-side applied
+func (r *Reconciler) applyPatched(ctx context.Context, objects []*unstructured.Unstructured, opts ApplyOpts, result *Result) ([]*unstructured.Unstructured, error) {
+	toApply := []*unstructured.Unstructured{}
+
+	for _, obj := range objects {
+		patchType, ok := opts.PatchStrategies[obj.GroupVersionKind().GroupKind()]
+		if !ok {
+			toApply = append(toApply, obj)
+			continue
+		}
+
+		action, err := r.patch(ctx, obj, patchType)
+		if err != nil {
+			return nil, err
+		}
+		change := Change{InventoryItem: toInventoryItem(obj), Action: action}
+		result.Changes = append(result.Changes, change)
+		reportApplied(ctx, opts, change)
+	}
+
+	return toApply, nil
+}
+
+func (r *Reconciler) patch(ctx context.Context, obj *unstructured.Unstructured, patchType types.PatchType) (Action, error) {
+	if patchType != types.MergePatchType && patchType != types.StrategicMergePatchType {
+		return ActionUnknown, fmt.Errorf("%w %v for %v", ErrUnsupportedPatchStrategyError, patchType, ssautils.FmtUnstructured(obj))
+	}
+
+	existing := &unstructured.Unstructured{}
+	existing.SetGroupVersionKind(obj.GroupVersionKind())
+	err := r.mgr.Client().Get(ctx, client.ObjectKeyFromObject(obj), existing)
+	if apierrors.IsNotFound(err) {
+		if err := r.mgr.Client().Create(ctx, obj.DeepCopy(), client.FieldOwner(fieldManager)); err != nil {
+			return ActionUnknown, fmt.Errorf("error creating %v: %w", ssautils.FmtUnstructured(obj), err)
+		}
+		return ActionCreated, nil
+	}
+	if err != nil {
+		return ActionUnknown, fmt.Errorf("error getting %v: %w", ssautils.FmtUnstructured(obj), err)
+	}
+
+	body, err := obj.MarshalJSON()
+	if err != nil {
+		return ActionUnknown, fmt.Errorf("error encoding %v: %w", ssautils.FmtUnstructured(obj), err)
+	}
+
+	resourceVersion := existing.GetResourceVersion()
+	if err := r.mgr.Client().Patch(ctx, existing, client.RawPatch(patchType, body), client.FieldOwner(fieldManager)); err != nil {
+		return ActionUnknown, fmt.Errorf("error patching %v: %w", ssautils.FmtUnstructured(obj), err)
+	}
+
+	// The server doesn't bump the resource version for a no-op patch
+	if existing.GetResourceVersion() == resourceVersion {
+		return ActionUnchanged, nil
+	}
+	return ActionConfigured, nil
+}
@@ -0,0 +1,67 @@
+package goply
+
+import (
+	"path"
+
+	"github.com/samber/lo"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// IgnorePattern matches objects to leave out of listings and diffs, see ReconcilerConfig.IgnorePatterns. Empty fields
+// match anything. Namespace and Name are path.Match patterns, and every one of Labels has to be present with the given
+// value
+type IgnorePattern struct {
+	Group     string
+	Kind      string
+	Namespace string
+	Name      string
+	Labels    map[string]string
+}
+
+// DefaultIgnorePatterns match the objects Kubernetes creates by itself, in every namespace
+var DefaultIgnorePatterns = []IgnorePattern{
+	{Kind: "ConfigMap", Name: "kube-root-ca.crt"},
+	{Kind: "Event"},
+	{Group: "events.k8s.io", Kind: "Event"},
+}
+
+func (p IgnorePattern) matches(gk schema.GroupKind, namespace string, name string, labels map[string]string) bool {
+	if p.Group != "" && p.Group != gk.Group || p.Kind != "" && p.Kind != gk.Kind {
+		return false
+	}
+	if !globMatches(p.Namespace, namespace) || !globMatches(p.Name, name) {
+		return false
+	}
+	for k, v := range p.Labels {
+		if value, ok := labels[k]; !ok || value != v {
+			return false
+		}
+	}
+	return true
+}
+
+func globMatches(pattern string, value string) bool {
+	if pattern == "" {
+		return true
+	}
+	matched, err := path.Match(pattern, value)
+	return err == nil && matched
+}
+
+func (r *Reconciler) isIgnored(gk schema.GroupKind, namespace string, name string, labels map[string]string) bool {
+	return lo.ContainsBy(r.ignorePatterns, func(p IgnorePattern) bool { return p.matches(gk, namespace, name, labels) })
+}
+
+func (r *Reconciler) withoutIgnoredObjects(objects []*unstructured.Unstructured) []*unstructured.Unstructured {
+	return lo.Reject(objects, func(obj *unstructured.Unstructured, _ int) bool {
+		return r.isIgnored(obj.GroupVersionKind().GroupKind(), obj.GetNamespace(), obj.GetName(), obj.GetLabels())
+	})
+}
+
+// withoutIgnoredItems only has the items' identities to go on, so patterns with Labels never match
+func (r *Reconciler) withoutIgnoredItems(items []InventoryItem) []InventoryItem {
+	return lo.Reject(items, func(item InventoryItem, _ int) bool {
+		return r.isIgnored(item.GroupKind, item.Namespace, item.Name, nil)
+	})
+}
@@ -0,0 +1,62 @@
+package goply
+
+import (
+	"testing"
+
+	"github.com/lithammer/dedent"
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/cli-utils/pkg/object"
+)
+
+func TestIgnorePatternMatches(t *testing.T) {
+	configMap := schema.GroupKind{Kind: "ConfigMap"}
+
+	require.True(t, DefaultIgnorePatterns[0].matches(configMap, "anywhere", "kube-root-ca.crt", nil))
+	require.False(t, DefaultIgnorePatterns[0].matches(configMap, "anywhere", "config", nil))
+	require.False(t, DefaultIgnorePatterns[0].matches(schema.GroupKind{Kind: "Secret"}, "anywhere", "kube-root-ca.crt", nil))
+
+	globs := IgnorePattern{Namespace: "kube-*", Name: "*-generated"}
+	require.True(t, globs.matches(configMap, "kube-system", "config-generated", nil))
+	require.False(t, globs.matches(configMap, "default", "config-generated", nil))
+
+	labelled := IgnorePattern{Labels: map[string]string{"app.kubernetes.io/managed-by": "system"}}
+	require.True(t, labelled.matches(configMap, "default", "config", map[string]string{"app.kubernetes.io/managed-by": "system", "other": "label"}))
+	require.False(t, labelled.matches(configMap, "default", "config", map[string]string{"app.kubernetes.io/managed-by": "helm"}))
+	require.False(t, labelled.matches(configMap, "default", "config", nil))
+}
+
+func TestSnapshotDiffIgnorePatterns(t *testing.T) {
+	r, err := NewReconciler(&ReconcilerConfig{
+		Kubeconfig:     offlineKubeconfig,
+		IgnorePatterns: append(DefaultIgnorePatterns, IgnorePattern{Labels: map[string]string{"noise": "true"}}),
+	})
+	require.NoError(t, err)
+
+	previous := Inventory{Items: []InventoryItem{{
+		ObjMetadata:  object.ObjMetadata{Namespace: "goply-test", Name: "kube-root-ca.crt", GroupKind: schema.GroupKind{Kind: "ConfigMap"}},
+		GroupVersion: "v1",
+	}}}
+
+	diff, err := r.SnapshotDiff(dedent.Dedent(`
+		---
+		apiVersion: v1
+		kind: ConfigMap
+		metadata:
+		  name: config
+		  namespace: goply-test
+		---
+		apiVersion: v1
+		kind: ConfigMap
+		metadata:
+		  name: generated
+		  namespace: goply-test
+		  labels:
+		    noise: "true"
+	`)[1:], previous)
+	require.NoError(t, err)
+
+	require.Equal(t, []string{"config"}, lo.Map(diff.Added, func(item InventoryItem, _ int) string { return item.Name }))
+	require.Empty(t, diff.Removed)
+}
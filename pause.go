@@ -0,0 +1,14 @@
+package goply
+
+import (
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// PauseAnnotation, set to "true" on an object in the manifest, stops goply from applying it, leaving whatever is live
+// in the cluster untouched. Paused objects are reported in Result.Skipped and stay in the inventory, so they aren't
+// pruned either
+const PauseAnnotation = "goply.io/pause"
+
+func isPaused(obj *unstructured.Unstructured) bool {
+	return obj.GetAnnotations()[PauseAnnotation] == "true"
+}
@@ -0,0 +1,76 @@
+package goply
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/fluxcd/pkg/ssa"
+	ssautils "github.com/fluxcd/pkg/ssa/utils"
+	"github.com/google/uuid"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// ApplyPayload is the server-side apply request goply sends for a single object: a PATCH of the object's resource
+// with PatchType as the content type, Body as the body, and FieldManager and Force as the fieldManager and force query
+// parameters
+type ApplyPayload struct {
+	InventoryItem
+	Stage        string
+	PatchType    types.PatchType
+	FieldManager string
+	Force        bool
+	Body         []byte
+}
+
+// ApplyPayloads returns the apply requests reconciling yaml with opts would send, in the order they'd be sent, without
+// contacting the cluster. Everything goply does to the objects up front is reflected (normalization, stamped labels and
+// annotations, ordering), but not the ApplyOpts that decide per object, against the cluster, whether or how to apply
+// it: skipped, conflicting, patched or unchanged objects would not all be sent as shown. Paused objects are never sent
+func (r *Reconciler) ApplyPayloads(yaml string, opts ApplyOpts) ([]ApplyPayload, error) {
+	objects, err := GetObjects(yaml)
+	if err != nil {
+		return nil, fmt.Errorf("error decoding yaml to unstructured: %w", err)
+	}
+
+	stageOne, stageTwo, _, err := r.stagesFor(objects, r.transformers, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	reconcileID := opts.ReconcileID
+	if reconcileID == "" {
+		reconcileID = uuid.NewString()
+	}
+	if err := r.decorateStages(stageOne, stageTwo, opts, reconcileID); err != nil {
+		return nil, err
+	}
+
+	payloads := []ApplyPayload{}
+	for _, stage := range []struct {
+		name    string
+		objects []*unstructured.Unstructured
+	}{{StageOne, stageOne}, {StageTwo, stageTwo}} {
+		ordered := append([]*unstructured.Unstructured{}, stage.objects...)
+		if !opts.SortByKind && !opts.DisableStaging {
+			sort.Sort(ssa.SortableUnstructureds(ordered))
+		}
+
+		for _, obj := range ordered {
+			body, err := obj.MarshalJSON()
+			if err != nil {
+				return nil, fmt.Errorf("error encoding %v: %w", ssautils.FmtUnstructured(obj), err)
+			}
+			payloads = append(payloads, ApplyPayload{
+				InventoryItem: toInventoryItem(obj),
+				Stage:         stage.name,
+				PatchType:     types.ApplyPatchType,
+				FieldManager:  fieldManager,
+				Force:         true,
+				Body:          body,
+			})
+		}
+	}
+
+	return payloads, nil
+}
@@ -0,0 +1,55 @@
+package goply
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/lithammer/dedent"
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func TestApplyPayloads(t *testing.T) {
+	r := offlineReconciler(t)
+
+	payloads, err := r.ApplyPayloads(dedent.Dedent(`
+		---
+		apiVersion: v1
+		kind: Service
+		metadata:
+		  name: service-one
+		  namespace: goply-test
+		spec:
+		  ports:
+		  - port: 80
+		---
+		apiVersion: v1
+		kind: ConfigMap
+		metadata:
+		  name: config-one
+		  namespace: goply-test
+		---
+		apiVersion: v1
+		kind: Namespace
+		metadata:
+		  name: goply-test
+	`)[1:], ApplyOpts{ManagementLabels: map[string]string{"app": "goply"}})
+	require.NoError(t, err)
+
+	require.Equal(t, []string{"goply-test", "config-one", "service-one"}, lo.Map(payloads, func(p ApplyPayload, _ int) string { return p.Name }))
+	require.Equal(t, []string{StageOne, StageTwo, StageTwo}, lo.Map(payloads, func(p ApplyPayload, _ int) string { return p.Stage }))
+
+	service := payloads[2]
+	require.Equal(t, types.ApplyPatchType, service.PatchType)
+	require.Equal(t, "goply", service.FieldManager)
+	require.True(t, service.Force)
+
+	body := map[string]any{}
+	require.NoError(t, json.Unmarshal(service.Body, &body))
+	metadata := body["metadata"].(map[string]any)
+	require.Equal(t, map[string]any{"app": "goply"}, metadata["labels"])
+	// Normalized, as it would be sent
+	ports := body["spec"].(map[string]any)["ports"].([]any)
+	require.Equal(t, "TCP", ports[0].(map[string]any)["protocol"])
+}
@@ -0,0 +1,139 @@
+package goply
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/fluxcd/pkg/ssa"
+	ssautils "github.com/fluxcd/pkg/ssa/utils"
+	"github.com/samber/lo"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// Plan is everything a reconcile of a manifest would do, computed without changing anything in the cluster.
+// Rejected holds the objects an admission webhook would deny, the server-side dry-run runs validating webhooks just
+// like a real apply does
+type Plan struct {
+	Create    []InventoryItem
+	Update    []PlannedUpdate
+	Unchanged []InventoryItem
+	Prune     []InventoryItem
+	Rejected  []*WebhookRejectionError
+}
+
+// PlannedUpdate is an object that exists but has drifted from the manifest. Live is the object as it is in the cluster,
+// Merged is what it would look like after the apply. Secret data is masked in both
+type PlannedUpdate struct {
+	InventoryItem
+	Live   *unstructured.Unstructured
+	Merged *unstructured.Unstructured
+}
+
+// Plan computes what reconciling yaml against previous would do: which objects would be created, which would be
+// updated (with their live and merged state), which are already up to date, and which would be pruned. previous may be
+// nil, in which case nothing is pruned. Externally managed objects are never pruned, just like a reconcile
+func (r *Reconciler) Plan(ctx context.Context, yaml string, previous *Inventory) (Plan, error) {
+	allObjects, err := GetObjects(yaml)
+	if err != nil {
+		return Plan{}, fmt.Errorf("error decoding yaml to unstructured: %w", err)
+	}
+	return r.planObjects(ctx, allObjects, previous)
+}
+
+// DiffAgainstOwned is a Plan grounded in what's live in the cluster rather than in a stored inventory: the objects
+// carrying all of labels, as found by ListOwned, are what yaml is planned against, so anything owned but no longer in
+// yaml is a prune. labels are stamped onto yaml's objects first, just like ApplyOpts.ManagementLabels does, so they
+// don't show up as an update
+func (r *Reconciler) DiffAgainstOwned(ctx context.Context, yaml string, labels map[string]string) (Plan, error) {
+	if len(labels) == 0 {
+		return Plan{}, fmt.Errorf("%w to diff against, labels can't be empty", ErrNoOwnerError)
+	}
+
+	allObjects, err := GetObjects(yaml)
+	if err != nil {
+		return Plan{}, fmt.Errorf("error decoding yaml to unstructured: %w", err)
+	}
+	stampLabels(allObjects, labels)
+
+	owned, err := r.ListOwned(ctx, labels)
+	if err != nil {
+		return Plan{}, err
+	}
+
+	return r.planObjects(ctx, allObjects, &owned)
+}
+
+func (r *Reconciler) planObjects(ctx context.Context, allObjects []*unstructured.Unstructured, previous *Inventory) (Plan, error) {
+	// Paused objects are left alone, but still count towards the new inventory for pruning
+	stageOne, stageTwo, _, err := getResourceStages(allObjects, r.stages, r.transformers)
+	if err != nil {
+		return Plan{}, fmt.Errorf("error getting resource stages: %w", err)
+	}
+
+	plan := Plan{}
+
+	// Objects in a namespace, or of a kind, that doesn't exist yet can't be dry-run. If this same manifest creates that
+	// namespace or CRD, they're creates too
+	pendingNamespaces := newSet[string]()
+	pendingKinds := newSet[schema.GroupKind]()
+
+	for _, obj := range r.withoutIgnoredObjects(append(append([]*unstructured.Unstructured{}, stageOne...), stageTwo...)) {
+		item := toInventoryItem(obj)
+
+		entry, live, merged, err := r.mgr.Diff(withCachedReads(ctx), obj, ssa.DefaultDiffOptions())
+		if rejection := asWebhookRejection(err); rejection != nil {
+			if rejection.Object == nil {
+				rejection.Object = &item
+			}
+			plan.Rejected = append(plan.Rejected, rejection)
+			continue
+		}
+		if err != nil {
+			pending := pendingNamespaces.Contains(obj.GetNamespace()) || pendingKinds.Contains(item.GroupKind)
+			if !pending || !(apierrors.IsNotFound(err) || meta.IsNoMatchError(err)) {
+				return Plan{}, fmt.Errorf("error planning %v: %w", ssautils.FmtUnstructured(obj), err)
+			}
+			plan.Create = append(plan.Create, item)
+			continue
+		}
+
+		switch entry.Action {
+		case ssa.CreatedAction:
+			plan.Create = append(plan.Create, item)
+			switch {
+			case ssautils.IsNamespace(obj):
+				pendingNamespaces.Add(obj.GetName())
+			case ssautils.IsCRD(obj):
+				if gk, ok := crdGroupKind(obj); ok {
+					pendingKinds.Add(gk)
+				}
+			}
+		case ssa.ConfiguredAction:
+			plan.Update = append(plan.Update, PlannedUpdate{InventoryItem: item, Live: live, Merged: merged})
+		default:
+			plan.Unchanged = append(plan.Unchanged, item)
+		}
+	}
+
+	if previous != nil {
+		// Just like a reconcile, externally managed objects are never pruned
+		newInventory := Inventory{Items: toInventoryItems(allObjects)}
+		prunable := Inventory{Items: lo.Reject(previous.Items, func(item InventoryItem, _ int) bool { return item.ExternallyManaged })}
+		plan.Prune = r.withoutIgnoredItems(toInventoryItems(prunable.ItemsToRemove(newInventory)))
+	}
+
+	return plan, nil
+}
+
+// crdGroupKind returns the kind defined by a CustomResourceDefinition
+func crdGroupKind(crd *unstructured.Unstructured) (schema.GroupKind, bool) {
+	group, _, _ := unstructured.NestedString(crd.Object, "spec", "group")
+	kind, _, _ := unstructured.NestedString(crd.Object, "spec", "names", "kind")
+	if kind == "" {
+		return schema.GroupKind{}, false
+	}
+	return schema.GroupKind{Group: group, Kind: kind}, true
+}
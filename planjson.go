@@ -0,0 +1,176 @@
+package goply
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	apiequality "k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// PlanSchemaVersion is the schemaVersion of the documents Plan.ToJSON produces. Within a schema version fields are
+// only ever added, never renamed, removed or given a new meaning, so a document can always be read by anything written
+// against an earlier document of the same version. Anything else bumps the version
+const PlanSchemaVersion = 1
+
+type PlanAction string
+
+const (
+	PlanActionCreate    PlanAction = "create"
+	PlanActionUpdate    PlanAction = "update"
+	PlanActionUnchanged PlanAction = "unchanged"
+	PlanActionPrune     PlanAction = "prune"
+	PlanActionRejected  PlanAction = "rejected"
+)
+
+type FieldOp string
+
+const (
+	FieldOpAdd     FieldOp = "add"
+	FieldOpRemove  FieldOp = "remove"
+	FieldOpReplace FieldOp = "replace"
+)
+
+// PlanDocument is the document produced by Plan.ToJSON
+type PlanDocument struct {
+	SchemaVersion int          `json:"schemaVersion"`
+	Objects       []PlanObject `json:"objects"`
+}
+
+// PlanObject is what the plan would do to a single object. Changes is only set for updates, and Reason only for
+// objects an admission webhook would reject
+type PlanObject struct {
+	Group     string        `json:"group"`
+	Version   string        `json:"version"`
+	Kind      string        `json:"kind"`
+	Namespace string        `json:"namespace,omitempty"`
+	Name      string        `json:"name"`
+	Action    PlanAction    `json:"action"`
+	Changes   []FieldChange `json:"changes,omitempty"`
+	Reason    string        `json:"reason,omitempty"`
+}
+
+// FieldChange is a single field an update would change. Path is formatted like .spec.template.spec.containers[0].image.
+// Before is null for an added field, and After is null for a removed one
+type FieldChange struct {
+	Path   string  `json:"path"`
+	Op     FieldOp `json:"op"`
+	Before any     `json:"before"`
+	After  any     `json:"after"`
+}
+
+func newPlanObject(item InventoryItem, action PlanAction) PlanObject {
+	return PlanObject{
+		Group:     item.GroupKind.Group,
+		Version:   item.GroupVersion,
+		Kind:      item.GroupKind.Kind,
+		Namespace: item.Namespace,
+		Name:      item.Name,
+		Action:    action,
+	}
+}
+
+// Document builds the versioned plan document. Objects are grouped by action, creates first, followed by updates,
+// unchanged objects, prunes and rejections, each in the order they appear in the plan. Field changes are sorted by path
+func (p Plan) Document() PlanDocument {
+	objects := make([]PlanObject, 0, len(p.Create)+len(p.Update)+len(p.Unchanged)+len(p.Prune)+len(p.Rejected))
+	for _, item := range p.Create {
+		objects = append(objects, newPlanObject(item, PlanActionCreate))
+	}
+	for _, update := range p.Update {
+		obj := newPlanObject(update.InventoryItem, PlanActionUpdate)
+		obj.Changes = fieldChanges(update.Live, update.Merged)
+		objects = append(objects, obj)
+	}
+	for _, item := range p.Unchanged {
+		objects = append(objects, newPlanObject(item, PlanActionUnchanged))
+	}
+	for _, item := range p.Prune {
+		objects = append(objects, newPlanObject(item, PlanActionPrune))
+	}
+	for _, rejection := range p.Rejected {
+		item := InventoryItem{}
+		if rejection.Object != nil {
+			item = *rejection.Object
+		}
+		obj := newPlanObject(item, PlanActionRejected)
+		obj.Reason = fmt.Sprintf("rejected by webhook %v: %v", rejection.Webhook, rejection.Reason)
+		objects = append(objects, obj)
+	}
+
+	return PlanDocument{SchemaVersion: PlanSchemaVersion, Objects: objects}
+}
+
+// ToJSON renders the plan as an indented, versioned JSON document, see PlanDocument and PlanSchemaVersion. The output
+// for the same plan is always byte for byte identical, so documents from different runs can be compared directly
+func (p Plan) ToJSON() ([]byte, error) {
+	return json.MarshalIndent(p.Document(), "", "  ")
+}
+
+// fieldChanges lists every field that differs between live and merged, ignoring status and the metadata the API server
+// maintains itself
+func fieldChanges(live *unstructured.Unstructured, merged *unstructured.Unstructured) []FieldChange {
+	if live == nil || merged == nil {
+		return nil
+	}
+
+	changes := []FieldChange{}
+	diffFields("", planComparable(live), planComparable(merged), &changes)
+	sort.SliceStable(changes, func(i, j int) bool { return changes[i].Path < changes[j].Path })
+	return changes
+}
+
+func planComparable(obj *unstructured.Unstructured) map[string]any {
+	c := obj.DeepCopy()
+	unstructured.RemoveNestedField(c.Object, "status")
+	for _, field := range []string{"managedFields", "resourceVersion", "generation", "creationTimestamp", "uid"} {
+		unstructured.RemoveNestedField(c.Object, "metadata", field)
+	}
+	return c.Object
+}
+
+func diffFields(path string, before any, after any, changes *[]FieldChange) {
+	switch b := before.(type) {
+	case map[string]any:
+		a, ok := after.(map[string]any)
+		if !ok {
+			break
+		}
+		for key, bv := range b {
+			av, ok := a[key]
+			if !ok {
+				*changes = append(*changes, FieldChange{Path: path + "." + key, Op: FieldOpRemove, Before: bv})
+				continue
+			}
+			diffFields(path+"."+key, bv, av, changes)
+		}
+		for key, av := range a {
+			if _, ok := b[key]; !ok {
+				*changes = append(*changes, FieldChange{Path: path + "." + key, Op: FieldOpAdd, After: av})
+			}
+		}
+		return
+	case []any:
+		a, ok := after.([]any)
+		if !ok {
+			break
+		}
+		for idx := 0; idx < max(len(a), len(b)); idx++ {
+			elemPath := fmt.Sprintf("%v[%v]", path, idx)
+			switch {
+			case idx >= len(a):
+				*changes = append(*changes, FieldChange{Path: elemPath, Op: FieldOpRemove, Before: b[idx]})
+			case idx >= len(b):
+				*changes = append(*changes, FieldChange{Path: elemPath, Op: FieldOpAdd, After: a[idx]})
+			default:
+				diffFields(elemPath, b[idx], a[idx], changes)
+			}
+		}
+		return
+	}
+
+	if !apiequality.Semantic.DeepEqual(before, after) {
+		*changes = append(*changes, FieldChange{Path: path, Op: FieldOpReplace, Before: before, After: after})
+	}
+}
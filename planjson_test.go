@@ -0,0 +1,83 @@
+package goply
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/cli-utils/pkg/object"
+)
+
+func TestPlanToJSON(t *testing.T) {
+	item := func(group string, kind string, namespace string, name string) InventoryItem {
+		return InventoryItem{
+			ObjMetadata: object.ObjMetadata{
+				GroupKind: schema.GroupKind{Group: group, Kind: kind},
+				Namespace: namespace,
+				Name:      name,
+			},
+			GroupVersion: "v1",
+		}
+	}
+
+	live := &unstructured.Unstructured{Object: map[string]any{
+		"apiVersion": "v1",
+		"kind":       "ConfigMap",
+		"metadata": map[string]any{
+			"name":            "config",
+			"namespace":       "my-ns",
+			"resourceVersion": "12",
+			"labels":          map[string]any{"app": "goply"},
+		},
+		"data": map[string]any{"foo": "old", "gone": "soon"},
+	}}
+	merged := live.DeepCopy()
+	merged.SetResourceVersion("13")
+	merged.Object["data"] = map[string]any{"foo": "new", "added": "value"}
+
+	rejected := item("", "Secret", "my-ns", "creds")
+	plan := Plan{
+		Create:    []InventoryItem{item("", "Namespace", "", "my-ns")},
+		Update:    []PlannedUpdate{{InventoryItem: item("", "ConfigMap", "my-ns", "config"), Live: live, Merged: merged}},
+		Unchanged: []InventoryItem{item("", "Service", "my-ns", "svc")},
+		Prune:     []InventoryItem{item("", "ConfigMap", "my-ns", "old-config")},
+		Rejected: []*WebhookRejectionError{
+			{Object: &rejected, Webhook: "policy.example.com", Reason: "no secrets", err: errors.New("denied")},
+		},
+	}
+
+	got, err := plan.ToJSON()
+	require.NoError(t, err)
+	require.JSONEq(t, `{
+		"schemaVersion": 1,
+		"objects": [
+			{"group": "", "version": "v1", "kind": "Namespace", "name": "my-ns", "action": "create"},
+			{"group": "", "version": "v1", "kind": "ConfigMap", "namespace": "my-ns", "name": "config", "action": "update", "changes": [
+				{"path": ".data.added", "op": "add", "before": null, "after": "value"},
+				{"path": ".data.foo", "op": "replace", "before": "old", "after": "new"},
+				{"path": ".data.gone", "op": "remove", "before": "soon", "after": null}
+			]},
+			{"group": "", "version": "v1", "kind": "Service", "namespace": "my-ns", "name": "svc", "action": "unchanged"},
+			{"group": "", "version": "v1", "kind": "ConfigMap", "namespace": "my-ns", "name": "old-config", "action": "prune"},
+			{"group": "", "version": "v1", "kind": "Secret", "namespace": "my-ns", "name": "creds", "action": "rejected",
+				"reason": "rejected by webhook policy.example.com: no secrets"}
+		]
+	}`, string(got))
+
+	// Stable output, run to run
+	again, err := plan.ToJSON()
+	require.NoError(t, err)
+	require.Equal(t, got, again)
+}
+
+func TestFieldChangesLists(t *testing.T) {
+	before := &unstructured.Unstructured{Object: map[string]any{"spec": map[string]any{"ports": []any{int64(80), int64(443)}}}}
+	after := &unstructured.Unstructured{Object: map[string]any{"spec": map[string]any{"ports": []any{int64(8080)}}}}
+
+	require.Equal(t, []FieldChange{
+		{Path: ".spec.ports[0]", Op: FieldOpReplace, Before: int64(80), After: int64(8080)},
+		{Path: ".spec.ports[1]", Op: FieldOpRemove, Before: int64(443)},
+	}, fieldChanges(before, after))
+}
@@ -0,0 +1,72 @@
+package goply
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/samber/lo"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+var (
+	ErrPreflightFailedError = errors.New("preflight checks failed")
+)
+
+type PreflightOpts struct {
+	// MinReadyNodes is the minimum number of nodes that must have a Ready condition of True
+	MinReadyNodes int
+	// RequiredNamespaces must all already exist
+	RequiredNamespaces []string
+}
+
+// Preflight checks the cluster is healthy enough to be applied to. The API server's readiness endpoint is always
+// checked, everything else is configured via opts. It is never run as part of Apply/Reconcile unless
+// ApplyOpts.Preflight is set
+func (r *Reconciler) Preflight(ctx context.Context, opts PreflightOpts) error {
+	body, err := r.discovery.RESTClient().Get().AbsPath("/readyz").DoRaw(ctx)
+	if err != nil {
+		return fmt.Errorf("%w: API server is not ready: %v", ErrPreflightFailedError, err)
+	}
+	if string(body) != "ok" {
+		return fmt.Errorf("%w: API server is not ready: %v", ErrPreflightFailedError, string(body))
+	}
+
+	failures := []string{}
+
+	if opts.MinReadyNodes > 0 {
+		nodes := &corev1.NodeList{}
+		if err := r.mgr.Client().List(ctx, nodes); err != nil {
+			return fmt.Errorf("error listing nodes: %w", err)
+		}
+
+		ready := lo.CountBy(nodes.Items, func(n corev1.Node) bool {
+			return lo.ContainsBy(n.Status.Conditions, func(c corev1.NodeCondition) bool {
+				return c.Type == corev1.NodeReady && c.Status == corev1.ConditionTrue
+			})
+		})
+		if ready < opts.MinReadyNodes {
+			failures = append(failures, fmt.Sprintf("%v ready nodes, need at least %v", ready, opts.MinReadyNodes))
+		}
+	}
+
+	for _, ns := range opts.RequiredNamespaces {
+		err := r.mgr.Client().Get(ctx, client.ObjectKey{Name: ns}, &corev1.Namespace{})
+		if apierrors.IsNotFound(err) {
+			failures = append(failures, fmt.Sprintf("namespace %v does not exist", ns))
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("error getting namespace %v: %w", ns, err)
+		}
+	}
+
+	if len(failures) > 0 {
+		return fmt.Errorf("%w: %v", ErrPreflightFailedError, strings.Join(failures, ", "))
+	}
+
+	return nil
+}
@@ -0,0 +1,66 @@
+package goply
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/fluxcd/pkg/ssa"
+)
+
+type ProgressPhase string
+
+const (
+	// ProgressApplied is reported once an object has been applied, along with what was done to it
+	ProgressApplied ProgressPhase = "Applied"
+	// ProgressReady is reported once an object that's being waited on is first seen to be ready
+	ProgressReady ProgressPhase = "Ready"
+)
+
+// ProgressUpdate is a single object reaching a ProgressPhase, see ApplyOpts.ObjectProgress
+type ProgressUpdate struct {
+	InventoryItem
+	Stage string
+	Phase ProgressPhase
+	// Action is only set for ProgressApplied
+	Action Action
+	// ReadyAfter is only set for ProgressReady, and is the same duration as in Result.ReadyAfter
+	ReadyAfter time.Duration
+}
+
+// serializeProgress wraps f so it's never called concurrently, since concurrent applies report their progress from
+// their own goroutines
+func serializeProgress(f func(ProgressUpdate)) func(ProgressUpdate) {
+	if f == nil {
+		return nil
+	}
+	var mu sync.Mutex
+	return func(update ProgressUpdate) {
+		mu.Lock()
+		defer mu.Unlock()
+		f(update)
+	}
+}
+
+// reportApplied sends a ProgressApplied update for every change
+func reportApplied(ctx context.Context, opts ApplyOpts, changes ...Change) {
+	if opts.ObjectProgress == nil {
+		return
+	}
+	for _, change := range changes {
+		opts.ObjectProgress(ProgressUpdate{
+			InventoryItem: change.InventoryItem,
+			Stage:         stageFrom(ctx),
+			Phase:         ProgressApplied,
+			Action:        change.Action,
+		})
+	}
+}
+
+// reportChangeSet sends a ProgressApplied update for every entry in changeSet
+func reportChangeSet(ctx context.Context, opts ApplyOpts, changeSet *ssa.ChangeSet) {
+	if opts.ObjectProgress == nil || changeSet == nil {
+		return
+	}
+	reportApplied(ctx, opts, changeSetChanges(changeSet)...)
+}
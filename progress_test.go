@@ -0,0 +1,81 @@
+package goply
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/fluxcd/cli-utils/pkg/kstatus/polling/event"
+	"github.com/fluxcd/cli-utils/pkg/kstatus/status"
+	fluxobject "github.com/fluxcd/cli-utils/pkg/object"
+	"github.com/fluxcd/pkg/ssa"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/cli-utils/pkg/object"
+)
+
+func TestReportApplied(t *testing.T) {
+	updates := []ProgressUpdate{}
+	opts := ApplyOpts{ObjectProgress: func(u ProgressUpdate) { updates = append(updates, u) }}
+
+	changeSet := ssa.NewChangeSet()
+	changeSet.Add(ssa.ChangeSetEntry{
+		ObjMetadata:  fluxobject.ObjMetadata{Namespace: "goply-test", Name: "config", GroupKind: schema.GroupKind{Kind: "ConfigMap"}},
+		GroupVersion: "v1",
+		Action:       ssa.CreatedAction,
+	})
+	reportChangeSet(withStage(context.Background(), StageTwo), opts, changeSet)
+
+	require.Len(t, updates, 1)
+	require.Equal(t, "config", updates[0].Name)
+	require.Equal(t, "v1", updates[0].GroupVersion)
+	require.Equal(t, StageTwo, updates[0].Stage)
+	require.Equal(t, ProgressApplied, updates[0].Phase)
+	require.Equal(t, ActionCreated, updates[0].Action)
+
+	// Nothing to report to is fine
+	reportChangeSet(context.Background(), ApplyOpts{}, changeSet)
+}
+
+func TestReadinessProgress(t *testing.T) {
+	id := fluxobject.ObjMetadata{Namespace: "goply-test", Name: "app", GroupKind: schema.GroupKind{Group: "apps", Kind: "Deployment"}}
+	item := InventoryItem{ObjMetadata: fromFluxObjMetadata(id), GroupVersion: "apps/v1"}
+
+	updates := []ProgressUpdate{}
+	ready := &readiness{
+		start:    time.Now(),
+		after:    map[object.ObjMetadata]time.Duration{},
+		stage:    StageTwo,
+		items:    map[object.ObjMetadata]InventoryItem{item.ObjMetadata: item},
+		progress: func(u ProgressUpdate) { updates = append(updates, u) },
+	}
+
+	ready.observe(&event.ResourceStatus{Identifier: id, Status: status.InProgressStatus})
+	ready.observe(&event.ResourceStatus{Identifier: id, Status: status.CurrentStatus})
+	ready.observe(&event.ResourceStatus{Identifier: id, Status: status.CurrentStatus})
+
+	require.Len(t, updates, 1)
+	require.Equal(t, item, updates[0].InventoryItem)
+	require.Equal(t, ProgressReady, updates[0].Phase)
+	require.Equal(t, ready.after[item.ObjMetadata], updates[0].ReadyAfter)
+}
+
+func TestSerializeProgress(t *testing.T) {
+	require.Nil(t, serializeProgress(nil))
+
+	// Unsynchronized on purpose, the race detector flags it if calls ever overlap
+	count := 0
+	progress := serializeProgress(func(ProgressUpdate) { count++ })
+
+	var wg sync.WaitGroup
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			progress(ProgressUpdate{})
+		}()
+	}
+	wg.Wait()
+	require.Equal(t, 10, count)
+}
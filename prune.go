@@ -0,0 +1,48 @@
+package goply
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	ssautils "github.com/fluxcd/pkg/ssa/utils"
+	"github.com/samber/lo"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+var ErrPruneRefusedError = errors.New("refusing to prune")
+
+// inNamespaceScope reports whether obj may be pruned when pruning is restricted to the namespaces in scope. A Namespace
+// counts as part of its own scope, and an empty scope allows everything
+func inNamespaceScope(obj *unstructured.Unstructured, scope []string) bool {
+	if len(scope) == 0 {
+		return true
+	}
+	if ssautils.IsNamespace(obj) {
+		return lo.Contains(scope, obj.GetName())
+	}
+	return lo.Contains(scope, obj.GetNamespace())
+}
+
+// checkPruneLimits refuses a prune that would remove more than MaxPrune objects, or any object of a protected kind
+func checkPruneLimits(toRemove []*unstructured.Unstructured, opts ApplyOpts) error {
+	if len(opts.ProtectedPruneKinds) > 0 {
+		protected := newSet(opts.ProtectedPruneKinds...)
+		blocked := lo.Filter(toRemove, func(u *unstructured.Unstructured, _ int) bool {
+			return protected.Contains(u.GroupVersionKind().GroupKind())
+		})
+		if len(blocked) > 0 {
+			return fmt.Errorf(
+				"%w objects of protected kinds: [%v]",
+				ErrPruneRefusedError,
+				strings.Join(lo.Map(blocked, func(u *unstructured.Unstructured, _ int) string { return ssautils.FmtUnstructured(u) }), ", "),
+			)
+		}
+	}
+
+	if opts.MaxPrune != nil && len(toRemove) > *opts.MaxPrune {
+		return fmt.Errorf("%w %v objects, at most %v are allowed", ErrPruneRefusedError, len(toRemove), *opts.MaxPrune)
+	}
+
+	return nil
+}
@@ -0,0 +1,142 @@
+package goply
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/lithammer/dedent"
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestCheckPruneLimits(t *testing.T) {
+	toRemove, err := GetObjects(dedent.Dedent(`
+		---
+		apiVersion: v1
+		kind: ConfigMap
+		metadata:
+		  name: config-one
+		  namespace: goply-test
+		---
+		apiVersion: v1
+		kind: PersistentVolumeClaim
+		metadata:
+		  name: data
+		  namespace: goply-test
+	`)[1:])
+	require.NoError(t, err)
+
+	require.NoError(t, checkPruneLimits(toRemove, ApplyOpts{}))
+	require.NoError(t, checkPruneLimits(toRemove, ApplyOpts{MaxPrune: ptr(2)}))
+
+	err = checkPruneLimits(toRemove, ApplyOpts{MaxPrune: ptr(1)})
+	require.ErrorIs(t, err, ErrPruneRefusedError)
+	require.ErrorContains(t, err, "refusing to prune 2 objects, at most 1 are allowed")
+
+	err = checkPruneLimits(toRemove, ApplyOpts{ProtectedPruneKinds: []schema.GroupKind{{Kind: "PersistentVolumeClaim"}}})
+	require.ErrorIs(t, err, ErrPruneRefusedError)
+	require.ErrorContains(t, err, "PersistentVolumeClaim/goply-test/data")
+}
+
+func TestPrePrune(t *testing.T) {
+	objs, err := GetObjects(dedent.Dedent(`
+		---
+		apiVersion: v1
+		kind: ConfigMap
+		metadata:
+		  name: config-one
+		  namespace: goply-test
+	`)[1:])
+	require.NoError(t, err)
+	previous := Inventory{Items: toInventoryItems(objs)}
+
+	var vetoed []InventoryItem
+	veto := errors.New("not today")
+	err = offlineReconciler(t).removeItems(context.Background(), previous, &Result{}, ApplyOpts{
+		PrePrune: func(items []InventoryItem) error {
+			vetoed = items
+			return veto
+		},
+	})
+	require.ErrorIs(t, err, ErrPruneRefusedError)
+	require.ErrorIs(t, err, veto)
+	require.Equal(t, previous.Items, vetoed)
+}
+
+func TestNamespaceScope(t *testing.T) {
+	objs, err := GetObjects(dedent.Dedent(`
+		---
+		apiVersion: v1
+		kind: Namespace
+		metadata:
+		  name: tenant-a
+		---
+		apiVersion: v1
+		kind: ConfigMap
+		metadata:
+		  name: config-one
+		  namespace: tenant-a
+		---
+		apiVersion: v1
+		kind: ConfigMap
+		metadata:
+		  name: config-two
+		  namespace: tenant-b
+		---
+		apiVersion: rbac.authorization.k8s.io/v1
+		kind: ClusterRole
+		metadata:
+		  name: role-one
+	`)[1:])
+	require.NoError(t, err)
+
+	scope := []string{"tenant-a"}
+	require.Equal(t, []bool{true, true, false, false}, lo.Map(objs, func(u *unstructured.Unstructured, _ int) bool { return inNamespaceScope(u, scope) }))
+	require.True(t, inNamespaceScope(objs[3], []string{""}))
+	require.True(t, inNamespaceScope(objs[2], nil))
+
+	// Nothing in scope, so nothing is deleted, and what was held back is still tracked
+	previous := Inventory{Items: toInventoryItems(objs[2:])}
+	result := Result{}
+	err = offlineReconciler(t).removeItems(context.Background(), previous, &result, ApplyOpts{NamespaceScope: scope})
+	require.NoError(t, err)
+	require.Empty(t, result.Pruned)
+	require.Equal(t, previous.Items, result.Inventory.Items)
+}
+
+func TestExternallyManagedNotPruned(t *testing.T) {
+	objs, err := GetObjects(dedent.Dedent(`
+		---
+		apiVersion: v1
+		kind: ConfigMap
+		metadata:
+		  name: config-one
+		  namespace: goply-test
+		  annotations:
+		    goply.io/externally-managed: "true"
+		---
+		apiVersion: v1
+		kind: ConfigMap
+		metadata:
+		  name: config-two
+		  namespace: goply-test
+	`)[1:])
+	require.NoError(t, err)
+	previous := Inventory{Items: toInventoryItems(objs)}
+	require.Equal(t, []bool{true, false}, lo.Map(previous.Items, func(i InventoryItem, _ int) bool { return i.ExternallyManaged }))
+	require.Equal(t, []*unstructured.Unstructured{objs[1]}, withoutExternallyManaged(objs))
+
+	// Only config-two is offered up for pruning
+	var offered []InventoryItem
+	err = offlineReconciler(t).removeItems(context.Background(), previous, &Result{}, ApplyOpts{
+		PrePrune: func(items []InventoryItem) error {
+			offered = items
+			return errors.New("not today")
+		},
+	})
+	require.ErrorIs(t, err, ErrPruneRefusedError)
+	require.Equal(t, []string{"config-two"}, lo.Map(offered, func(i InventoryItem, _ int) string { return i.Name }))
+}
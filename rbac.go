@@ -0,0 +1,58 @@
+package goply
+
+import (
+	"context"
+	"time"
+
+	"github.com/fluxcd/pkg/ssa"
+	"github.com/samber/lo"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// rbacPropagationKinds are the kinds whose creation can leave the API server briefly refusing requests that depend on
+// them, until the new permissions take effect
+var rbacPropagationKinds = []schema.GroupKind{
+	{Kind: "Namespace"},
+	{Kind: "ServiceAccount"},
+	{Group: "rbac.authorization.k8s.io", Kind: "Role"},
+	{Group: "rbac.authorization.k8s.io", Kind: "RoleBinding"},
+	{Group: "rbac.authorization.k8s.io", Kind: "ClusterRole"},
+	{Group: "rbac.authorization.k8s.io", Kind: "ClusterRoleBinding"},
+}
+
+// withRBACPropagation runs apply, and when it fails with Forbidden during stage two shortly after this reconcile created
+// RBAC objects, keeps running it again until it gets past the error or RBACPropagationTimeout is up
+func (r *Reconciler) withRBACPropagation(ctx context.Context, opts ApplyOpts, result *Result, apply func() (*ssa.ChangeSet, error)) (*ssa.ChangeSet, error) {
+	changeSet, err := apply()
+	if err == nil || opts.RBACPropagationTimeout <= 0 || stageFrom(ctx) != StageTwo || !apierrors.IsForbidden(err) {
+		return changeSet, err
+	}
+	if !createdRBAC(result.Changes) && (changeSet == nil || !createdRBAC(changeSetChanges(changeSet))) {
+		return changeSet, err
+	}
+
+	deadline := time.Now().Add(opts.RBACPropagationTimeout)
+	for apierrors.IsForbidden(err) && time.Now().Before(deadline) {
+		r.log(ctx, "apply forbidden, retrying while RBAC created by this reconcile propagates", "error", err)
+		select {
+		case <-time.After(500 * time.Millisecond):
+		case <-ctx.Done():
+			return changeSet, err
+		}
+		changeSet, err = apply()
+	}
+	return changeSet, err
+}
+
+func createdRBAC(changes []Change) bool {
+	return lo.SomeBy(changes, func(c Change) bool {
+		return c.Action == ActionCreated && lo.Contains(rbacPropagationKinds, c.GroupKind)
+	})
+}
+
+func changeSetChanges(changeSet *ssa.ChangeSet) []Change {
+	result := Result{}
+	result.addChangeSet(changeSet)
+	return result.Changes
+}
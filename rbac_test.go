@@ -0,0 +1,53 @@
+package goply
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/fluxcd/pkg/ssa"
+	"github.com/stretchr/testify/require"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/cli-utils/pkg/object"
+)
+
+func TestWithRBACPropagation(t *testing.T) {
+	r := offlineReconciler(t)
+	opts := ApplyOpts{RBACPropagationTimeout: 10 * time.Second}
+	createdNamespace := &Result{Changes: []Change{{
+		InventoryItem: InventoryItem{ObjMetadata: object.ObjMetadata{Name: "goply-test", GroupKind: schema.GroupKind{Kind: "Namespace"}}},
+		Action:        ActionCreated,
+	}}}
+
+	forbiddenOnce := func(calls *int) func() (*ssa.ChangeSet, error) {
+		return func() (*ssa.ChangeSet, error) {
+			*calls++
+			if *calls == 1 {
+				return nil, apierrors.NewForbidden(schema.GroupResource{Resource: "configmaps"}, "config-one", nil)
+			}
+			return ssa.NewChangeSet(), nil
+		}
+	}
+
+	t.Run("retried", func(t *testing.T) {
+		calls := 0
+		_, err := r.withRBACPropagation(withStage(context.Background(), StageTwo), opts, createdNamespace, forbiddenOnce(&calls))
+		require.NoError(t, err)
+		require.Equal(t, 2, calls)
+	})
+
+	t.Run("nothing created", func(t *testing.T) {
+		calls := 0
+		_, err := r.withRBACPropagation(withStage(context.Background(), StageTwo), opts, &Result{}, forbiddenOnce(&calls))
+		require.True(t, apierrors.IsForbidden(err))
+		require.Equal(t, 1, calls)
+	})
+
+	t.Run("stage one", func(t *testing.T) {
+		calls := 0
+		_, err := r.withRBACPropagation(withStage(context.Background(), StageOne), opts, createdNamespace, forbiddenOnce(&calls))
+		require.True(t, apierrors.IsForbidden(err))
+		require.Equal(t, 1, calls)
+	})
+}
@@ -0,0 +1,134 @@
+package goply
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"unicode"
+
+	ssautils "github.com/fluxcd/pkg/ssa/utils"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	yamlutil "k8s.io/apimachinery/pkg/util/yaml"
+)
+
+// readObjects is ssautils.ReadObjects, except objects using generateName are an error rather than being silently
+// dropped along with everything else that has no name
+func readObjects(r io.Reader) ([]*unstructured.Unstructured, error) {
+	decoded, err := decodeObjects(r)
+	if err != nil {
+		return []*unstructured.Unstructured{}, err
+	}
+
+	objects := make([]*unstructured.Unstructured, 0, len(decoded))
+	for _, obj := range decoded {
+		if obj.GetName() == "" && obj.GetGenerateName() != "" {
+			return objects, fmt.Errorf("%w: %v with generateName %v", ErrGenerateNameError, obj.GetKind(), obj.GetGenerateName())
+		}
+
+		if ssautils.IsKubernetesObject(obj) && !ssautils.IsKustomization(obj) {
+			objects = append(objects, obj)
+		}
+	}
+
+	return objects, nil
+}
+
+// decodeObjects decodes every document in r, flattening lists, without filtering anything out. Alongside YAML and
+// JSON objects, r can hold JSON arrays of objects
+func decodeObjects(r io.Reader) ([]*unstructured.Unstructured, error) {
+	br := bufio.NewReader(r)
+	first, err := firstNonSpace(br)
+	if err != nil {
+		return []*unstructured.Unstructured{}, err
+	}
+	if first == '[' {
+		return decodeJSONArrays(br)
+	}
+
+	reader := yamlutil.NewYAMLOrJSONDecoder(br, 2048)
+	objects := make([]*unstructured.Unstructured, 0)
+
+	for {
+		obj := &unstructured.Unstructured{}
+		err := reader.Decode(obj)
+		if errors.Is(err, io.EOF) {
+			return objects, nil
+		}
+		if err != nil {
+			return objects, err
+		}
+
+		objects, err = appendFlattened(objects, obj)
+		if err != nil {
+			return objects, err
+		}
+	}
+}
+
+// decodeJSONArrays decodes one or more JSON arrays of objects
+func decodeJSONArrays(r io.Reader) ([]*unstructured.Unstructured, error) {
+	decoder := json.NewDecoder(r)
+	objects := make([]*unstructured.Unstructured, 0)
+
+	for {
+		items := []json.RawMessage{}
+		err := decoder.Decode(&items)
+		if errors.Is(err, io.EOF) {
+			return objects, nil
+		}
+		if err != nil {
+			return objects, err
+		}
+
+		for idx, item := range items {
+			obj := &unstructured.Unstructured{}
+			if err := obj.UnmarshalJSON(item); err != nil {
+				return objects, fmt.Errorf("error decoding array item %v: %w", idx, err)
+			}
+			objects, err = appendFlattened(objects, obj)
+			if err != nil {
+				return objects, err
+			}
+		}
+	}
+}
+
+// appendFlattened appends obj to objects, or its items if it's a list
+func appendFlattened(objects []*unstructured.Unstructured, obj *unstructured.Unstructured) ([]*unstructured.Unstructured, error) {
+	if !obj.IsList() {
+		return append(objects, obj), nil
+	}
+
+	err := obj.EachListItem(func(item runtime.Object) error {
+		objects = append(objects, item.(*unstructured.Unstructured))
+		return nil
+	})
+	return objects, err
+}
+
+// firstNonSpace returns the first byte of r that isn't whitespace or part of a leading comment line, without consuming
+// it. The comment lines are consumed, so a JSON decoder reading on from r never sees them. An r holding nothing but
+// whitespace and comments returns 0
+func firstNonSpace(r *bufio.Reader) (byte, error) {
+	for {
+		b, err := r.ReadByte()
+		if errors.Is(err, io.EOF) {
+			return 0, nil
+		}
+		if err != nil {
+			return 0, err
+		}
+		if b == '#' {
+			if _, err := r.ReadString('\n'); err != nil && !errors.Is(err, io.EOF) {
+				return 0, err
+			}
+			continue
+		}
+		if !unicode.IsSpace(rune(b)) {
+			return b, r.UnreadByte()
+		}
+	}
+}
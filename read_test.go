@@ -0,0 +1,84 @@
+package goply
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestGetObjectsJSON(t *testing.T) {
+	names := func(objs []*unstructured.Unstructured) []string {
+		return lo.Map(objs, func(u *unstructured.Unstructured, _ int) string { return u.GetName() })
+	}
+
+	t.Run("array", func(t *testing.T) {
+		objs, err := GetObjects(`
+			[
+				{"apiVersion": "v1", "kind": "ConfigMap", "metadata": {"name": "config-one", "namespace": "goply-test"}},
+				{"apiVersion": "v1", "kind": "List", "items": [
+					{"apiVersion": "v1", "kind": "ConfigMap", "metadata": {"name": "config-two", "namespace": "goply-test"}}
+				]}
+			]
+		`)
+		require.NoError(t, err)
+		require.Equal(t, []string{"config-one", "config-two"}, names(objs))
+	})
+
+	t.Run("array after comments", func(t *testing.T) {
+		objs, err := GetObjects("# generated, do not edit\n# by hand\n" +
+			`[{"apiVersion": "v1", "kind": "ConfigMap", "metadata": {"name": "config-one", "namespace": "goply-test"}}]`,
+		)
+		require.NoError(t, err)
+		require.Equal(t, []string{"config-one"}, names(objs))
+	})
+
+	t.Run("lines", func(t *testing.T) {
+		objs, err := GetObjects(
+			`{"apiVersion": "v1", "kind": "ConfigMap", "metadata": {"name": "config-one", "namespace": "goply-test"}}` + "\n" +
+				`{"apiVersion": "v1", "kind": "ConfigMap", "metadata": {"name": "config-two", "namespace": "goply-test"}}` + "\n",
+		)
+		require.NoError(t, err)
+		require.Equal(t, []string{"config-one", "config-two"}, names(objs))
+	})
+
+	t.Run("malformed array", func(t *testing.T) {
+		_, err := GetObjects(`[{"apiVersion": "v1", "kind": "ConfigMap"`)
+		require.Error(t, err)
+	})
+}
+
+func TestGetObjectsFromReaderEmpty(t *testing.T) {
+	for name, input := range map[string]string{
+		"empty":      "",
+		"whitespace": "  \n\t\n",
+		"separators": "---\n---\n",
+		"comments":   "# nothing to see here\n---\n# or here\n",
+		"comment":    "# no trailing newline",
+	} {
+		t.Run(name, func(t *testing.T) {
+			objs, err := GetObjectsFromReader(strings.NewReader(input))
+			require.NoError(t, err)
+			require.Empty(t, objs)
+		})
+	}
+}
+
+func TestReconcileEmpty(t *testing.T) {
+	r := offlineReconciler(t)
+
+	for _, yaml := range []string{"", "  \n---\n# nothing here\n"} {
+		_, err := r.Reconcile(yaml, ApplyOpts{}, nil)
+		require.ErrorIs(t, err, ErrEmptyManifestError)
+	}
+
+	_, err := r.ReconcileObjects(nil, ApplyOpts{}, nil)
+	require.ErrorIs(t, err, ErrEmptyManifestError)
+
+	// With nothing to apply and nothing to prune, the cluster never has to be contacted
+	result, err := r.Reconcile("", ApplyOpts{AllowEmpty: true}, nil)
+	require.NoError(t, err)
+	require.Empty(t, result.Inventory.Items)
+}
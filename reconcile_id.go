@@ -0,0 +1,34 @@
+package goply
+
+import (
+	"context"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// ReconcileIDAnnotation is stamped onto every applied object when ApplyOpts.StampReconcileID is set, holding the ID of
+// the reconcile that last applied it
+const ReconcileIDAnnotation = "goply.io/reconcile-id"
+
+type reconcileIDKey struct{}
+
+func withReconcileID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, reconcileIDKey{}, id)
+}
+
+// reconcileIDFrom returns the ID of the reconcile ctx belongs to, or "" outside of a reconcile
+func reconcileIDFrom(ctx context.Context) string {
+	id, _ := ctx.Value(reconcileIDKey{}).(string)
+	return id
+}
+
+func stampReconcileID(objects []*unstructured.Unstructured, id string) {
+	for _, obj := range objects {
+		annotations := obj.GetAnnotations()
+		if annotations == nil {
+			annotations = map[string]string{}
+		}
+		annotations[ReconcileIDAnnotation] = id
+		obj.SetAnnotations(annotations)
+	}
+}
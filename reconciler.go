@@ -4,32 +4,52 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
+	"net/http"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/fluxcd/cli-utils/pkg/kstatus/polling"
 	"github.com/fluxcd/pkg/ssa"
-	"github.com/fluxcd/pkg/ssa/normalize"
 	ssautils "github.com/fluxcd/pkg/ssa/utils"
 	"github.com/go-logr/logr"
+	"github.com/google/uuid"
 	"github.com/samber/lo"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/discovery"
 	"k8s.io/client-go/discovery/cached/memory"
+	"k8s.io/client-go/rest"
 	"k8s.io/client-go/restmapper"
 	"k8s.io/client-go/tools/clientcmd"
+	"sigs.k8s.io/cli-utils/pkg/object"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/structured-merge-diff/v4/fieldpath"
 )
 
 var (
 	ErrNoConfigError     = errors.New("must supply config")
 	ErrNoKubeconfigError = errors.New("kubeconfig is required")
+	// ErrUnexpectedClusterError is returned by NewReconciler when the kubeconfig doesn't point at the expected cluster
+	ErrUnexpectedClusterError = errors.New("kubeconfig points at an unexpected cluster")
+	// ErrGenerateNameError is returned for objects that rely on metadata.generateName. Server-side apply requires a
+	// name, and an object without a stable name couldn't be tracked in the inventory anyway
+	ErrGenerateNameError  = errors.New("generateName is not supported, objects must have a name")
+	ErrEmptyManifestError = errors.New("manifest has no objects")
+	// ErrConflictingStageOverrideError is returned by NewReconciler when a kind is forced into both stages
+	ErrConflictingStageOverrideError = errors.New("kind forced into both stages")
 )
 
 const (
 	DefaultTimeout = 5 * time.Minute
+
+	fieldManager = "goply"
 )
 
 func ptr[T any](x T) *T {
@@ -38,17 +58,235 @@ func ptr[T any](x T) *T {
 
 type ApplyOpts struct {
 	WaitTimeout *time.Duration
-	SkipWait    bool
-}
-
-type DeleteOpts struct {
-	WaitTimeout *time.Duration
-	SkipWait    bool
+	// WaitTimeoutPerObject, when set, is added to WaitTimeout once for every object being waited on, so large manifests
+	// get proportionally more time
+	WaitTimeoutPerObject time.Duration
+	// SkipWait doesn't wait for stage two to become ready. Otherwise every object has to reach the kstatus Current
+	// status, which for a PersistentVolumeClaim means Bound. A claim on a WaitForFirstConsumer storage class is only
+	// bound once a pod using it is scheduled, so it has to be applied together with its consumer
+	SkipWait bool
+	// WaitForLabels and CriticalKinds, when either is set, limit the stage two wait to the critical objects: those
+	// carrying every one of WaitForLabels, or of one of CriticalKinds. Everything is still applied, the rest just
+	// becomes ready in its own time. Dependency levels are still waited on in full, see OrderByDependencies
+	WaitForLabels map[string]string
+	CriticalKinds []schema.GroupKind
+	// WaitBackoff, when set, polls for readiness with an exponentially growing interval rather than every 2s
+	WaitBackoff *WaitBackoff
+	// WaitBatchSize, when set, waits on objects in batches of this size rather than all at once, emitting a
+	// WaitProgress event as each batch becomes ready. WaitTimeout still covers the whole wait, not each batch
+	WaitBatchSize int
+	// RequireObservedGeneration additionally holds off on calling an object ready until its status.observedGeneration
+	// has caught up with its metadata.generation, for kinds that report one
+	RequireObservedGeneration bool
+	// SkipRolloutCheck leaves Deployments, StatefulSets and DaemonSets to kstatus alone. Otherwise they're only ready
+	// once every replica has been updated to the latest revision and is ready
+	SkipRolloutCheck bool
+	ConflictResolver ConflictResolver
+	ConflictPolicy   ConflictPolicy
+	// ConflictRetries is how many times an object with unforced conflicts is re-checked before the ConflictPolicy is
+	// applied, as conflicts are often transient. The wait between checks starts at ConflictRetryBackoff (1s if unset)
+	// and doubles each time
+	ConflictRetries      int
+	ConflictRetryBackoff time.Duration
+	// TakeOver is a one-time migration from another tool: it forces ownership of every field in the manifest on every
+	// object, ignoring ConflictPolicy and ConflictResolver, and stamps the Owner and ManagementLabels (at least one of
+	// which is required) so goply recognizes the objects as its own from then on. Because it seizes fields from whoever
+	// owns them, it's refused unless ConfirmTakeOver is set too, and it's announced with a warning event
+	TakeOver        bool
+	ConfirmTakeOver bool
+	// AtomicDryRunFirst dry-runs every object in the manifest before applying any of them, failing with a DryRunError
+	// naming the object if the server refuses one, so an invalid object can't leave the manifest half applied. Objects
+	// that depend on a Namespace or CRD from the same manifest can only be checked by the real apply
+	AtomicDryRunFirst bool
+	// TrackOwnedFields compares the fields goply owns on each object before and after applying it, reporting any
+	// difference in Result.OwnedFieldsChanges. It costs two extra reads per object
+	TrackOwnedFields bool
+	// Adopt takes over objects that already exist in the cluster but have never been applied by goply, forcing
+	// ownership of the fields in the manifest even when the ConflictPolicy or ConflictResolver wouldn't. Only the
+	// manifest's fields are taken over, everything else stays with whoever set it. Adopted objects are reported in
+	// Result.Adopted
+	Adopt bool
+	// RBACPropagationTimeout, when set, retries stage two applies that are Forbidden, for up to this long, as long as
+	// this reconcile has just created Namespaces, ServiceAccounts or RBAC objects. New permissions can take a moment to
+	// take effect, so such errors are often transient
+	RBACPropagationTimeout time.Duration
+	// SkipMissingKinds skips, rather than fails on, objects whose kind is neither installed in the cluster nor defined
+	// by a CRD in the same manifest. Skipped objects are reported in Result.Skipped and are left out of the inventory
+	SkipMissingKinds bool
+	// PruneManagedFields, when set, removes stale managedFields entries from every applied object once the apply is
+	// done, see ManagedFieldsPruning. This rewrites other managers' field ownership, so it's strictly opt-in
+	PruneManagedFields *ManagedFieldsPruning
+	// RelinquishAfterApply, when set, selects objects to hand off to another field manager once they've been applied and
+	// waited on. goply's managedFields entries are removed from them, leaving every field in place but unowned, so the
+	// next manager to apply takes them over without conflicts. (An empty apply would drop ownership too, but it also
+	// deletes every field goply alone owned.) Handed off objects are reported in Result.HandedOff and marked
+	// ExternallyManaged in the inventory
+	RelinquishAfterApply func(obj *unstructured.Unstructured) bool
+	// PreserveMetadata keeps labels and annotations that are on the live object but not in the manifest, by merging them
+	// into what's applied. SSA only ever removes keys goply's field manager owns, but a key goply once set stays owned
+	// by it even after another tool has come to rely on it. The catch is that goply then owns every preserved key too,
+	// so removing a label or annotation from the manifest no longer removes it from the cluster
+	PreserveMetadata bool
+	// MigrateToServerSide hands ownership of fields set by a client-side `kubectl apply` over to goply, and removes the
+	// last-applied-configuration annotation, before applying
+	MigrateToServerSide bool
+	// VerifyReadback re-reads every applied object and reports, in Result.Discrepancies, any field whose persisted value
+	// doesn't match what was sent (for example, mutated by an admission webhook). Objects that weren't sent, the ones in
+	// Result.Skipped, aren't read back
+	VerifyReadback bool
+	// PruneExclusions are never pruned, even when they're no longer part of the manifest. They're carried over into the
+	// new inventory, so they will be pruned by a later reconcile once they're no longer excluded
+	PruneExclusions []object.ObjMetadata
+	// NamespaceScope, when set, restricts pruning, including PruneOrphanedNamespaces, to objects in these namespaces,
+	// and to the Namespaces themselves. Cluster scoped objects are only pruned if "" is in the scope. Objects held back
+	// are carried over into the new inventory, like PruneExclusions are
+	NamespaceScope []string
+	// MaxPrune, when set, fails the reconcile before anything is pruned if more than this many objects would be.
+	// ProtectedPruneKinds does the same if any object of those kinds would be. Both guard against a mangled manifest
+	// deleting most of what it used to manage
+	MaxPrune            *int
+	ProtectedPruneKinds []schema.GroupKind
+	// PrePrune, when set, is called with the objects about to be pruned, after every other prune check has passed, for
+	// recording them or vetoing the prune. Returning an error aborts the prune before anything is deleted
+	PrePrune func(items []InventoryItem) error
+	// AllowCRDDeletion permits pruning CustomResourceDefinitions, see DeleteOpts.AllowCRDDeletion
+	AllowCRDDeletion bool
+	// PruneOrphanedNamespaces holds namespaces from the previous inventory that are no longer in the manifest back from
+	// the prune, and only deletes them once they're empty of everything but Kubernetes' own defaults, restricted to
+	// Owner's namespaces if it's set. Ones that aren't empty yet stay in the inventory. See
+	// Reconciler.PruneOrphanedNamespaces. Without it, namespaces are pruned like any other object, whatever they hold.
+	// Only done when there's a previous inventory to prune against
+	PruneOrphanedNamespaces bool
+	// IgnoreFields lists, per kind, JSON pointers (e.g. /spec/replicas) to fields that are disregarded when deciding
+	// whether an object has drifted. An object whose only differences are in ignored fields isn't applied at all
+	IgnoreFields map[schema.GroupKind][]string
+	// ApplyManifestSink, when set, is written each stage's objects as YAML, exactly as they're about to be applied
+	// (normalized, labelled, sorted, and so on), with a comment heading each stage
+	ApplyManifestSink io.Writer
+	// DriftOnly diffs every object against the cluster first and only applies the ones that are missing or have
+	// drifted. Objects that are already up to date are reported in Result.Skipped rather than Result.Changes, and are
+	// kept in the inventory
+	DriftOnly bool
+	// Preflight, when set, runs the given preflight checks before anything is applied
+	Preflight *PreflightOpts
+	// RecreateOnImmutableError deletes and recreates objects whose apply is rejected because it changes an immutable
+	// field. This is destructive, so it is opt-in. RecreateDelay is an additional pause between the old object being
+	// fully gone and the new one being created
+	RecreateOnImmutableError bool
+	RecreateDelay            time.Duration
+	// PerObjectApplyTimeout, when set, applies objects one at a time, each bounded by this timeout, so a single object
+	// stuck behind a slow admission webhook fails on its own rather than eating the whole budget. The objects after it
+	// are still applied, and the stage fails once they have been. This is slower than the default batched apply
+	PerObjectApplyTimeout time.Duration
+	// MaxApplyBatchBytes, when set, splits stage two into batches whose objects add up to no more than this many bytes
+	// of JSON, and applies them one after the other, to stay under request size limits when there's a lot of embedded
+	// data. An object that's bigger than this on its own is applied by itself
+	MaxApplyBatchBytes int
+	// SortByKind applies the objects within each stage in Helm's install order (ConfigMaps/Secrets before the
+	// Deployments that mount them, ServiceAccounts before RoleBindings, etc), one kind at a time. Without it, each stage is
+	// applied in the resource manager's own kind ordering
+	SortByKind bool
+	// CreateOnly only creates objects that don't exist yet, objects already in the cluster aren't touched at all and are
+	// reported in Result.Skipped. They are still tracked in the inventory
+	CreateOnly bool
+	// PatchStrategies makes objects of the given kinds bypass server-side apply, and instead be created or patched with
+	// the given patch type, for resources that misbehave under SSA field management. Only merge and strategic merge
+	// patches are supported
+	PatchStrategies map[schema.GroupKind]types.PatchType
+	// OrderByDependencies applies stage two in levels computed from config.kubernetes.io/depends-on annotations: objects
+	// within a level are applied concurrently, up to MaxParallelism at a time, and each level is waited on before the
+	// next one starts, regardless of SkipWait
+	OrderByDependencies bool
+	MaxParallelism      int
+	// ObjectProgress, when set, is called as each object is applied and, if it's waited on, as it becomes ready, for
+	// live progress reporting. Calls never overlap. The batched apply only reports once the whole batch is done, so
+	// for updates as each object is applied, set PerObjectApplyTimeout (or OrderByDependencies) to apply objects one at
+	// a time, at the cost of a slower apply
+	ObjectProgress func(ProgressUpdate)
+	// ApplyOrder applies stage two in a built-in order, see ApplyOrderTiered, for the common cases that don't need
+	// depends-on annotations. OrderByDependencies takes precedence over it
+	ApplyOrder ApplyOrder
+	// Owner, when set, labels every applied object as belonging to it, see Owner
+	Owner *Owner
+	// ManagementLabels are set on every applied object, so they can be found again with Reconciler.ListOwned. goply
+	// owns these labels like any other field it applies, so re-applying with the same values never conflicts
+	ManagementLabels map[string]string
+	// PruneFromCluster, which requires Owner, also prunes every object in the cluster carrying the owner's labels that's
+	// no longer part of the manifest, in addition to anything in the previous inventory. This repairs a lost or stale
+	// stored inventory
+	PruneFromCluster bool
+	// AllowEmpty permits reconciling no objects at all, which prunes everything in the previous inventory. Without it
+	// that's refused before anything is touched, since an empty manifest is far more often a broken template or an
+	// unmounted file than a request to tear everything down
+	AllowEmpty bool
+	// InventoryKey, when set, names the stored inventory. The previous inventory is loaded from InventoryStore under it,
+	// unless one is passed in, and the new inventory saved back once the reconcile succeeds. Only the Reconcile calls
+	// use it, Apply, ApplyObjects and ApplyObject leave the stored inventory alone
+	InventoryKey string
+	// InventoryStore defaults to a ConfigMap store in InventoryNamespace, itself defaulting to "default"
+	InventoryStore     InventoryStore
+	InventoryNamespace string
+	// InterStageDelay is an additional pause between stage one being established and stage two being applied, for
+	// clusters where new namespaces and CRDs take a while longer to be picked up by caches and admission webhooks. It's
+	// skipped when stage one is empty
+	InterStageDelay time.Duration
+	// DisableStaging applies everything as a single stage, one object at a time in input order, followed by a single
+	// wait. Nothing is done to make sure namespaces and CRDs exist before the objects that need them, so a manifest
+	// with, for example, a CRD ahead of its custom resources may well fail. Ordering is entirely up to the caller
+	DisableStaging bool
+	// NormalizePolicy decides what happens to objects that can't be normalized before being applied
+	NormalizePolicy NormalizePolicy
+	// ReconcileID identifies this reconcile in logs, events and Result.ReconcileID. A random one is generated if unset
+	ReconcileID string
+	// StampReconcileID sets the ReconcileIDAnnotation on every applied object, so the cluster can be queried for
+	// everything touched by a given reconcile. Since the ID changes every run, this makes every object count as
+	// configured on every reconcile
+	StampReconcileID bool
+	// Rollback snapshots every object before anything is applied and, if the apply or wait then fails, tries to put
+	// them back: objects the reconcile created are deleted, and objects it changed have the fields goply owned
+	// re-applied as they were, without forcing, so other managers' fields are left alone. Objects goply had never applied
+	// can't be restored. The outcome is reported in a RollbackError wrapping the failure. Pruning happens after this
+	// point and is never rolled back
+	Rollback bool
+	// RecordLastApplied sets the LastAppliedAnnotation on every applied object, so a later PreviewFromLastApplied can
+	// diff against it. It's recorded before goply's own labels are added
+	RecordLastApplied bool
 }
 
 type ReconcilerConfig struct {
 	Kubeconfig string
 	Logger     *logr.Logger
+	// WrapTransport, when set, wraps the HTTP transport of every client talking to the cluster, for adding things like
+	// tracing headers, request logging, or custom auth
+	WrapTransport func(http.RoundTripper) http.RoundTripper
+	// RequestTimeout, when set, bounds every individual request to the API server, so a single hung request fails
+	// rather than holding things up. It applies on top of any context deadline, whichever is sooner wins, and a request
+	// timing out fails the operation it was part of just like any other API error
+	RequestTimeout time.Duration
+	// ExpectedAPIServerURL, when set, must match the API server URL from the kubeconfig
+	ExpectedAPIServerURL string
+	// ExpectedClusterID, when set, must match the UID of the cluster's kube-system namespace, which is a stable
+	// identifier for the cluster regardless of how it's addressed. Checking it requires contacting the cluster
+	ExpectedClusterID string
+	// ForceStageOneKinds and ForceStageTwoKinds override which stage objects of these kinds are applied in, rather than
+	// leaving it to whether they look like cluster definitions (CRDs, Namespaces, and the like)
+	ForceStageOneKinds []schema.GroupKind
+	ForceStageTwoKinds []schema.GroupKind
+	// CacheTTL, when set, caches the discovery of listable resources, and the object reads done to diff or check for
+	// existing objects (Plan, DriftOnly and CreateOnly), for this long. Objects goply writes are evicted straight away,
+	// but changes made by anything else can go unnoticed until their entry expires. Waits always read live
+	CacheTTL time.Duration
+	// IgnorePatterns leaves matching objects out of ListOwned (and so LiveInventory and PruneFromCluster), Plan,
+	// PreviewFromLastApplied and SnapshotDiff, for objects such as kube-root-ca.crt that show up without anyone having
+	// asked for them. See DefaultIgnorePatterns. They're still applied and tracked as normal if they're in a manifest
+	IgnorePatterns []IgnorePattern
+	// AnnotationHooks add per-object hooks, driven by annotations on the objects in a manifest, to the
+	// DefaultAnnotationHooks. One for the same annotation as a default hook replaces it
+	AnnotationHooks []AnnotationHook
+	// Transformers change every object in a manifest, in order, before anything else is done with it, for things like
+	// pointing images at a mirror or adding common labels. See CommonLabels, CommonAnnotations and RegistryRewrite for
+	// the built-in ones, and Chain for combining them. Plan, Stages and the previews see the transformed objects too
+	Transformers []Transformer
 }
 
 func NewReconciler(config *ReconcilerConfig) (*Reconciler, error) {
@@ -58,39 +296,70 @@ func NewReconciler(config *ReconcilerConfig) (*Reconciler, error) {
 	if config.Kubeconfig == "" {
 		return nil, ErrNoKubeconfigError
 	}
+	if both := lo.Intersect(config.ForceStageOneKinds, config.ForceStageTwoKinds); len(both) > 0 {
+		return nil, fmt.Errorf("%w: %v", ErrConflictingStageOverrideError, both)
+	}
 
-	mgr, err := newResourceManager(config.Kubeconfig, config.Logger)
+	var cache *cachingClient
+	mgr, poller, dc, err := newResourceManager(config, func(c client.Client) client.Client {
+		if config.CacheTTL <= 0 {
+			return c
+		}
+		cache = newCachingClient(c, config.CacheTTL)
+		return cache
+	})
 	if err != nil {
 		return nil, err
 	}
 
 	return &Reconciler{
-		mgr: mgr,
+		mgr:       mgr,
+		poller:    poller,
+		discovery: dc,
+		cache:     cache,
+		stages: stageOverrides{
+			stageOne: config.ForceStageOneKinds,
+			stageTwo: config.ForceStageTwoKinds,
+		},
+		ignorePatterns:  config.IgnorePatterns,
+		annotationHooks: annotationHooks(config.AnnotationHooks),
+		transformers:    config.Transformers,
 	}, nil
 }
 
-func newResourceManager(kubeconf string, log *logr.Logger) (*ssa.ResourceManager, error) {
+// newResourceManager builds everything needed to talk to the cluster. wrapClient is given the chance to wrap the client
+// before anything else uses it
+func newResourceManager(config *ReconcilerConfig, wrapClient func(client.Client) client.Client) (*ssa.ResourceManager, *polling.StatusPoller, discovery.DiscoveryInterface, error) {
 	var l logr.Logger
-	if log == nil {
+	if config.Logger == nil {
 		l = logr.New(logf.NullLogSink{})
 	} else {
-		l = *log
+		l = *config.Logger
 	}
 	logf.SetLogger(l)
 
-	restConfig, err := clientcmd.RESTConfigFromKubeConfig([]byte(kubeconf))
+	restConfig, err := clientcmd.RESTConfigFromKubeConfig([]byte(config.Kubeconfig))
 	if err != nil {
-		return nil, fmt.Errorf("error getting rest config: %w", err)
+		return nil, nil, nil, fmt.Errorf("error getting rest config: %w", err)
+	}
+	if config.WrapTransport != nil {
+		restConfig.Wrap(config.WrapTransport)
 	}
+	restConfig.Timeout = config.RequestTimeout
 
 	client, err := client.New(restConfig, client.Options{})
 	if err != nil {
-		return nil, fmt.Errorf("error building controller runtime client: %w", err)
+		return nil, nil, nil, fmt.Errorf("error building controller runtime client: %w", err)
+	}
+	client = wrapClient(client)
+
+	if err := verifyCluster(config, restConfig, client); err != nil {
+		return nil, nil, nil, err
 	}
 
 	dc, err := discovery.NewDiscoveryClientForConfig(restConfig)
 	if err != nil {
-		return nil, fmt.Errorf("error creating discovery client: %w", err)
+		return nil, nil, nil, fmt.Errorf("error creating discovery client: %w", err)
 	}
 
 	mapper := restmapper.NewDeferredDiscoveryRESTMapper(memory.NewMemCacheClient(dc))
@@ -98,170 +367,744 @@ func newResourceManager(kubeconf string, log *logr.Logger) (*ssa.ResourceManager
 	poller := polling.NewStatusPoller(client, mapper, polling.Options{})
 
 	mgr := ssa.NewResourceManager(client, poller, ssa.Owner{
-		Field: "goply",
-		Group: "goply",
+		Field: fieldManager,
+		Group: fieldManager,
 	})
 
-	return mgr, nil
+	return mgr, poller, dc, nil
+}
+
+// verifyCluster checks the kubeconfig points at the cluster the config expects, if it expects one
+func verifyCluster(config *ReconcilerConfig, restConfig *rest.Config, c client.Client) error {
+	if config.ExpectedAPIServerURL != "" {
+		if strings.TrimSuffix(restConfig.Host, "/") != strings.TrimSuffix(config.ExpectedAPIServerURL, "/") {
+			return fmt.Errorf("%w: API server is %v, expected %v", ErrUnexpectedClusterError, restConfig.Host, config.ExpectedAPIServerURL)
+		}
+	}
+
+	if config.ExpectedClusterID != "" {
+		ns := &corev1.Namespace{}
+		if err := c.Get(context.TODO(), client.ObjectKey{Name: metav1.NamespaceSystem}, ns); err != nil {
+			return fmt.Errorf("error getting cluster ID: %w", err)
+		}
+		if string(ns.UID) != config.ExpectedClusterID {
+			return fmt.Errorf("%w: cluster ID is %v, expected %v", ErrUnexpectedClusterError, ns.UID, config.ExpectedClusterID)
+		}
+	}
+
+	return nil
+}
+
+// stageOverrides holds the kinds forced into one stage or the other, regardless of whether they're cluster definitions
+type stageOverrides struct {
+	stageOne []schema.GroupKind
+	stageTwo []schema.GroupKind
+}
+
+func (s stageOverrides) isStageOne(obj *unstructured.Unstructured) bool {
+	gk := obj.GroupVersionKind().GroupKind()
+	switch {
+	case lo.Contains(s.stageOne, gk):
+		return true
+	case lo.Contains(s.stageTwo, gk):
+		return false
+	default:
+		return ssautils.IsClusterDefinition(obj)
+	}
 }
 
-func getResourceStages(yaml string) ([]*unstructured.Unstructured, []*unstructured.Unstructured, error) {
+// getResourceStages splits allObjects into the stages they're applied in. Paused objects (see PauseAnnotation) aren't
+// in either stage, they're returned separately
+func getResourceStages(allObjects []*unstructured.Unstructured, overrides stageOverrides, transformers []Transformer) ([]*unstructured.Unstructured, []*unstructured.Unstructured, []*unstructured.Unstructured, error) {
 	stageOne := []*unstructured.Unstructured{}
 	stageTwo := []*unstructured.Unstructured{}
+	paused := []*unstructured.Unstructured{}
 
-	allObjects, err := GetObjects(yaml)
-	if err != nil {
-		return stageOne, stageTwo, fmt.Errorf("error decoding yaml to unstructured: %w", err)
+	if err := transformObjects(allObjects, transformers); err != nil {
+		return stageOne, stageTwo, paused, err
 	}
-
-	if err := normalize.UnstructuredList(allObjects); err != nil {
-		return stageOne, stageTwo, fmt.Errorf("error setting defaults: %w", err)
+	for _, obj := range allObjects {
+		if err := normalizeObject(obj); err != nil {
+			return stageOne, stageTwo, paused, fmt.Errorf("error setting defaults on %v: %w", ssautils.FmtUnstructured(obj), err)
+		}
 	}
 
 	for _, obj := range allObjects {
-		if ssautils.IsClusterDefinition(obj) {
+		if obj.GetName() == "" && obj.GetGenerateName() != "" {
+			return stageOne, stageTwo, paused, fmt.Errorf("%w: %v with generateName %v", ErrGenerateNameError, obj.GetKind(), obj.GetGenerateName())
+		}
+		switch {
+		case isPaused(obj):
+			paused = append(paused, obj)
+		case overrides.isStageOne(obj):
 			stageOne = append(stageOne, obj)
-		} else {
+		default:
 			stageTwo = append(stageTwo, obj)
 		}
 	}
 
-	return stageOne, stageTwo, nil
+	return stageOne, stageTwo, paused, nil
+}
+
+// Stages is the set of objects goply will send to the server, split into the stages they'll be applied in
+type Stages struct {
+	StageOne []*unstructured.Unstructured
+	StageTwo []*unstructured.Unstructured
+}
+
+// Stages returns the objects in yaml exactly as they would be applied, after the Transformers and normalization, without
+// contacting the cluster. Paused objects aren't applied, so they're left out
+func (r *Reconciler) Stages(yaml string) (Stages, error) {
+	allObjects, err := GetObjects(yaml)
+	if err != nil {
+		return Stages{}, fmt.Errorf("error decoding yaml to unstructured: %w", err)
+	}
+
+	stageOne, stageTwo, _, err := getResourceStages(allObjects, r.stages, r.transformers)
+	if err != nil {
+		return Stages{}, fmt.Errorf("error getting resource stages: %w", err)
+	}
+
+	return Stages{StageOne: stageOne, StageTwo: stageTwo}, nil
 }
 
 func GetObjects(yaml string) ([]*unstructured.Unstructured, error) {
-	allObjects, err := ssautils.ReadObjects(strings.NewReader(yaml))
+	return GetObjectsFromReader(strings.NewReader(yaml))
+}
+
+// GetObjectsFromReader decodes every object in r, reading it to the end. Input that's empty, or holds nothing but
+// whitespace, comments and document separators, is no objects rather than an error (reconciling no objects is refused
+// unless ApplyOpts.AllowEmpty is set, though), so piping from stdin is as simple as
+//
+//	objects, err := goply.GetObjectsFromReader(os.Stdin)
+//	if err != nil {
+//		return err
+//	}
+//	result, err := reconciler.ReconcileObjects(objects, opts, previous)
+func GetObjectsFromReader(r io.Reader) ([]*unstructured.Unstructured, error) {
+	allObjects, err := readObjects(r)
 	if err != nil {
-		return []*unstructured.Unstructured{}, fmt.Errorf("error decoding yaml to unstructured: %w", err)
+		return []*unstructured.Unstructured{}, &ParseError{err: fmt.Errorf("error decoding yaml to unstructured: %w", err)}
 	}
 
 	return allObjects, nil
 }
 
+// Reconciler is safe for concurrent use, a single instance can serve simultaneous Apply/Reconcile/Delete calls for
+// different manifests. Concurrent calls touching the same objects will race each other on the cluster side though, so
+// that's on the caller to avoid
 type Reconciler struct {
-	mgr     *ssa.ResourceManager
-	logFunc func(string)
+	mgr       *ssa.ResourceManager
+	poller    *polling.StatusPoller
+	discovery discovery.DiscoveryInterface
+	cache     *cachingClient
+	stages    stageOverrides
+
+	ignorePatterns  []IgnorePattern
+	annotationHooks []AnnotationHook
+	transformers    []Transformer
+
+	mu              sync.RWMutex
+	logFunc         func(string)
+	logger          *logr.Logger
+	eventFunc       func(Event)
+	eventChan       chan<- Event
+	eventChanPolicy EventChannelPolicy
+}
+
+// Client returns the controller-runtime client goply uses, for operations goply doesn't cover itself. Anything created,
+// changed or removed through it bypasses inventory tracking entirely
+func (r *Reconciler) Client() client.Client {
+	return r.mgr.Client()
+}
+
+// RESTMapper returns the mapper backing Client
+func (r *Reconciler) RESTMapper() meta.RESTMapper {
+	return r.mgr.Client().RESTMapper()
+}
+
+// Apply applies yaml without pruning anything. The stored inventory isn't consulted or saved, see partialApply
+func (r *Reconciler) Apply(yaml string, opts ApplyOpts) (Result, error) {
+	return r.Reconcile(yaml, partialApply(opts), nil)
+}
+
+// ApplyObjects is Apply for already decoded objects
+func (r *Reconciler) ApplyObjects(objects []*unstructured.Unstructured, opts ApplyOpts) (Result, error) {
+	return r.ReconcileObjects(objects, partialApply(opts), nil)
+}
+
+// partialApply turns off everything in opts that treats the objects as the whole manifest. Loading the stored
+// inventory or pruning from the cluster would prune everything not in a partial apply, and saving would overwrite the
+// stored inventory with just the objects applied
+func partialApply(opts ApplyOpts) ApplyOpts {
+	opts.InventoryKey = ""
+	opts.InventoryStore = nil
+	opts.PruneFromCluster = false
+	return opts
+}
+
+// ApplyObject applies a single object, returning it as it is in the cluster afterwards along with what was done to it.
+// If the object was skipped (see SkipMissingKinds and the like), the action is ActionSkipped and no object is returned.
+// Like Apply, nothing is pruned and the stored inventory is left alone
+func (r *Reconciler) ApplyObject(ctx context.Context, obj *unstructured.Unstructured, opts ApplyOpts) (*unstructured.Unstructured, Action, error) {
+	result, err := r.ReconcileObjectsContext(ctx, []*unstructured.Unstructured{obj.DeepCopy()}, partialApply(opts), nil)
+	if err != nil {
+		return nil, ActionUnknown, err
+	}
+	if len(result.Skipped) > 0 {
+		return nil, ActionSkipped, nil
+	}
+
+	action := ActionUnknown
+	if len(result.Changes) > 0 {
+		action = result.Changes[0].Action
+	}
+
+	live := &unstructured.Unstructured{}
+	live.SetGroupVersionKind(obj.GroupVersionKind())
+	if err := r.mgr.Client().Get(ctx, client.ObjectKeyFromObject(obj), live); err != nil {
+		return nil, action, fmt.Errorf("error getting applied %v: %w", ssautils.FmtUnstructured(obj), err)
+	}
+
+	return live, action, nil
 }
 
-func (r *Reconciler) SetLogFunc(f func(string)) {
-	r.logFunc = f
+func (r *Reconciler) Reconcile(yaml string, opts ApplyOpts, previousInventory *Inventory) (Result, error) {
+	return r.ReconcileContext(context.TODO(), yaml, opts, previousInventory)
 }
 
-func (r *Reconciler) log(msg string) {
-	if r.logFunc == nil {
-		return
+// ReconcileContext is Reconcile, stopping cleanly if ctx is cancelled, see CancelledError
+func (r *Reconciler) ReconcileContext(ctx context.Context, yaml string, opts ApplyOpts, previousInventory *Inventory) (Result, error) {
+	allObjects, err := GetObjects(yaml)
+	if err != nil {
+		return Result{}, fmt.Errorf("error decoding yaml to unstructured: %w", err)
 	}
-	r.logFunc(msg)
+
+	return r.ReconcileObjectsContext(ctx, allObjects, opts, previousInventory)
 }
 
-func (r *Reconciler) Apply(yaml string, opts ApplyOpts) (Inventory, error) {
-	return r.Reconcile(yaml, opts, nil)
+func (r *Reconciler) ReconcileObjects(objects []*unstructured.Unstructured, opts ApplyOpts, previousInventory *Inventory) (Result, error) {
+	return r.ReconcileObjectsContext(context.TODO(), objects, opts, previousInventory)
 }
 
-func (r *Reconciler) Reconcile(yaml string, opts ApplyOpts, previousInventory *Inventory) (Inventory, error) {
+// ReconcileObjectsContext is ReconcileObjects, stopping cleanly if ctx is cancelled, see CancelledError
+func (r *Reconciler) ReconcileObjectsContext(ctx context.Context, objects []*unstructured.Unstructured, opts ApplyOpts, previousInventory *Inventory) (Result, error) {
 	if opts.WaitTimeout == nil {
 		opts.WaitTimeout = ptr(DefaultTimeout)
 	}
 
-	stageOne, stageTwo, err := getResourceStages(yaml)
+	reconcileID := opts.ReconcileID
+	if reconcileID == "" {
+		reconcileID = uuid.NewString()
+	}
+	ctx = withReconcileID(ctx, reconcileID)
+
+	result := Result{
+		ReconcileID: reconcileID,
+		Timestamp:   time.Now(),
+		Attempts:    1,
+	}
+
+	if err := opts.ApplyOrder.validate(); err != nil {
+		return Result{}, err
+	}
+	if err := validateTakeOver(opts); err != nil {
+		return Result{}, err
+	}
+	if len(objects) == 0 && !opts.AllowEmpty {
+		return Result{}, fmt.Errorf("%w, set AllowEmpty to reconcile it anyway, pruning everything previously applied", ErrEmptyManifestError)
+	}
+	opts.ObjectProgress = serializeProgress(opts.ObjectProgress)
+
+	store, err := r.inventoryStore(opts)
+	if err != nil {
+		return Result{}, err
+	}
+	if store != nil && previousInventory == nil {
+		previousInventory, err = loadInventory(ctx, store, opts.InventoryKey)
+		if err != nil {
+			return Result{}, err
+		}
+	}
+
+	transformers := r.transformers
+	if opts.NormalizePolicy == NormalizePolicySkip {
+		// Whether an object normalizes depends on what the transformers made of it
+		if err := transformObjects(objects, transformers); err != nil {
+			return Result{}, fmt.Errorf("error getting resource stages: %w", err)
+		}
+		transformers = nil
+		objects = r.skipUnnormalizable(ctx, objects, &result)
+	}
+
+	stageOne, stageTwo, paused, err := r.stagesFor(objects, transformers, opts)
 	if err != nil {
-		return Inventory{}, fmt.Errorf("error getting resource stages: %w", err)
-	}
-
-	inventory := Inventory{}
-	inventory.Items = append(
-		inventory.Items,
-		lo.Map(stageOne, func(u *unstructured.Unstructured, _ int) InventoryItem {
-			return toInventoryItem(u)
-		})...,
-	)
-	inventory.Items = append(
-		inventory.Items,
-		lo.Map(stageTwo, func(u *unstructured.Unstructured, _ int) InventoryItem {
-			return toInventoryItem(u)
-		})...,
-	)
-
-	r.log("beginning apply of stage one resources")
-	_, err = r.mgr.ApplyAll(context.TODO(), stageOne, ssa.ApplyOptions{})
+		return Result{}, err
+	}
+
+	// Before goply adds anything of its own to the objects
+	hashes, err := contentHashes(append(append(append([]*unstructured.Unstructured{}, stageOne...), stageTwo...), paused...))
 	if err != nil {
-		return Inventory{}, fmt.Errorf("error applying stage one resources: %w", err)
+		return Result{}, err
+	}
+
+	for _, obj := range paused {
+		r.log(ctx, "skipping object, it is paused", objectKV(obj)...)
+		result.Skipped = append(result.Skipped, SkippedItem{
+			InventoryItem: toInventoryItem(obj),
+			Reason:        SkipReasonPaused,
+		})
+	}
+	result.Inventory.Items = append(result.Inventory.Items, toInventoryItems(paused)...)
+
+	if opts.PruneFromCluster && opts.Owner == nil {
+		return Result{}, fmt.Errorf("%w to prune from the cluster", ErrNoOwnerError)
+	}
+	if err := r.decorateStages(stageOne, stageTwo, opts, reconcileID); err != nil {
+		return Result{}, err
+	}
+	if opts.TakeOver {
+		r.announceTakeOver(ctx, append(append([]*unstructured.Unstructured{}, stageOne...), stageTwo...))
+	}
+
+	if opts.Preflight != nil {
+		r.log(ctx, "running preflight checks")
+		if err := r.Preflight(ctx, *opts.Preflight); err != nil {
+			return Result{}, err
+		}
+	}
+
+	if opts.AtomicDryRunFirst {
+		r.log(ctx, "dry-running every object before applying any")
+		if err := r.dryRunAll(ctx, append(append([]*unstructured.Unstructured{}, stageOne...), stageTwo...), opts); err != nil {
+			return Result{}, err
+		}
+	}
+
+	ctx = withStage(ctx, StageOne)
+	if opts.SkipMissingKinds {
+		stageOne = r.skipMissingKinds(ctx, stageOne, &result)
+	}
+	result.Inventory.Items = append(result.Inventory.Items, toInventoryItems(stageOne)...)
+
+	if opts.ApplyManifestSink != nil {
+		if err := writeManifests(opts.ApplyManifestSink, StageOne, stageOne); err != nil {
+			return Result{}, err
+		}
+	}
+
+	var snap *snapshot
+	if opts.Rollback {
+		r.log(ctx, "capturing pre-apply state for rollback")
+		snap, err = r.takeSnapshot(ctx, append(append([]*unstructured.Unstructured{}, stageOne...), stageTwo...))
+		if err != nil {
+			return Result{}, fmt.Errorf("error capturing pre-apply state: %w", err)
+		}
+	}
+	// Cancellation stops at a checkpoint, so there's no half done apply to roll back, just a partial result
+	fail := func(err error) (Result, error) {
+		var cancelled *CancelledError
+		if errors.As(err, &cancelled) {
+			return result, err
+		}
+		if ctx.Err() != nil {
+			return result, &CancelledError{Stage: stageFrom(ctx), err: err}
+		}
+		if snap == nil {
+			return Result{}, err
+		}
+		return Result{}, r.rollback(ctx, snap, err)
+	}
+
+	if err := checkpoint(ctx, nil); err != nil {
+		return result, err
+	}
+
+	r.log(ctx, "beginning apply of stage one resources")
+	err = r.applyStage(ctx, stageOne, opts, &result)
+	if err != nil {
+		return fail(&StageError{Stage: StageOne, err: fmt.Errorf("error applying stage one resources: %w", err)})
 	}
 
 	// Can't skip the stage1 wait, because it's got the NS and CRD objects, so if we don't wait for
 	// those to show up, stage2 will probably fail
-	r.log("waiting for stage one resources to reconcile")
-	err = r.mgr.Wait(stageOne, ssa.WaitOptions{
-		Interval: 2 * time.Second,
-		Timeout:  30 * time.Second,
-	})
+	r.log(ctx, "waiting for stage one resources to be established")
+	err = r.waitEstablished(ctx, stageOne, 30*time.Second)
 	if err != nil {
-		return Inventory{}, fmt.Errorf("timed out waiting for objects to reconcile")
+		return fail(&StageError{Stage: StageOne, err: fmt.Errorf("error waiting for stage one resources: %w", err)})
+	}
+
+	if opts.InterStageDelay > 0 && len(stageOne) > 0 {
+		r.log(ctx, "letting stage one resources settle", "delay", opts.InterStageDelay)
+		select {
+		case <-ctx.Done():
+			return fail(checkpoint(ctx, nil))
+		case <-time.After(opts.InterStageDelay):
+		}
+	}
+
+	ctx = withStage(ctx, StageTwo)
+	if err := checkpoint(ctx, nil); err != nil {
+		return fail(err)
+	}
+	// Has to happen after the stage one wait, so CRDs from this same manifest are visible
+	if opts.SkipMissingKinds {
+		stageTwo = r.skipMissingKinds(ctx, stageTwo, &result)
 	}
+	result.Inventory.Items = append(result.Inventory.Items, toInventoryItems(stageTwo)...)
 
-	r.log("beginning apply of stage two resources")
-	_, err = r.mgr.ApplyAll(context.TODO(), stageTwo, ssa.ApplyOptions{})
+	if opts.ApplyManifestSink != nil {
+		if err := writeManifests(opts.ApplyManifestSink, StageTwo, stageTwo); err != nil {
+			return fail(err)
+		}
+	}
+
+	r.log(ctx, "beginning apply of stage two resources")
+	switch {
+	case opts.OrderByDependencies:
+		err = r.applyLevels(ctx, stageOne, stageTwo, opts, &result)
+	case opts.ApplyOrder == ApplyOrderTiered:
+		err = r.applyTiers(ctx, stageTwo, opts, &result)
+	default:
+		err = r.applyStage(ctx, stageTwo, opts, &result)
+	}
 	if err != nil {
-		return Inventory{}, fmt.Errorf("error applying stage two resources: %w", err)
+		return fail(&StageError{Stage: StageTwo, err: fmt.Errorf("error applying stage two resources: %w", err)})
+	}
+
+	if opts.VerifyReadback {
+		r.log(ctx, "verifying applied resources")
+		discrepancies, err := r.verifyReadback(ctx, append(append([]*unstructured.Unstructured{}, stageOne...), stageTwo...), result.Skipped)
+		if err != nil {
+			return fail(&StageError{Stage: StageTwo, err: fmt.Errorf("error verifying applied resources: %w", err)})
+		}
+		result.Discrepancies = discrepancies
 	}
 
 	if !opts.SkipWait {
-		r.log("waiting for stage two resources to reconcile")
-		err = r.mgr.Wait(stageTwo, ssa.WaitOptions{
+		r.log(ctx, "waiting for stage two resources to reconcile")
+		toWait := criticalObjects(withoutExternallyManaged(stageTwo), opts)
+		err = r.wait(ctx, toWait, ssa.WaitOptions{
 			Interval: 2 * time.Second,
-			Timeout:  *opts.WaitTimeout,
-		})
+			Timeout:  scaledWaitTimeout(opts, len(toWait)),
+		}, opts, &result)
+		if err != nil {
+			return fail(&StageError{Stage: StageTwo, err: fmt.Errorf("error waiting for stage two resources: %w", err)})
+		}
+	}
+
+	if opts.PruneManagedFields != nil {
+		err := r.pruneManagedFields(ctx, append(append([]*unstructured.Unstructured{}, stageOne...), stageTwo...), *opts.PruneManagedFields)
+		if err != nil {
+			return fail(&StageError{Stage: StageTwo, err: fmt.Errorf("error pruning managed fields: %w", err)})
+		}
+	}
+
+	if opts.RelinquishAfterApply != nil {
+		err := r.relinquish(ctx, append(append([]*unstructured.Unstructured{}, stageOne...), stageTwo...), opts, &result)
+		if err != nil {
+			return fail(&StageError{Stage: StageTwo, err: fmt.Errorf("error relinquishing field ownership: %w", err)})
+		}
+	}
+
+	ctx = withStage(ctx, StagePrune)
+	if err := checkpoint(ctx, nil); err != nil {
+		return result, err
+	}
+	if opts.PruneFromCluster {
+		r.log(ctx, "building inventory from the cluster")
+		live, err := r.LiveInventory(ctx, *opts.Owner)
 		if err != nil {
-			return Inventory{}, fmt.Errorf("timed out waiting for objects to reconcile")
+			return fail(fmt.Errorf("error building inventory from the cluster: %w", err))
 		}
+		if previousInventory != nil {
+			live = previousInventory.union(live)
+		}
+		previousInventory = &live
 	}
 
 	if previousInventory != nil {
-		if err := r.removeItems(*previousInventory, inventory, opts); err != nil {
-			return Inventory{}, fmt.Errorf("error pruning items: %w", err)
+		if err := r.removeItems(ctx, *previousInventory, &result, opts); err != nil {
+			return Result{}, &StageError{Stage: StagePrune, err: fmt.Errorf("error pruning items: %w", err)}
+		}
+
+		if opts.PruneOrphanedNamespaces {
+			r.log(ctx, "pruning orphaned namespaces")
+			deleted, err := r.pruneOrphanedNamespaces(ctx, *previousInventory, result.Inventory, opts.Owner, opts.NamespaceScope, DeleteOpts{WaitTimeout: opts.WaitTimeout, SkipWait: opts.SkipWait})
+			result.Pruned = append(result.Pruned, deleted.Deleted...)
+			// Keep tracking the ones still holding something, so they're pruned once they're empty
+			kept, pruned := result.Inventory.Index(), Inventory{Items: deleted.Deleted}.Index()
+			result.Inventory.Items = append(result.Inventory.Items, lo.Filter(previousInventory.Items, func(item InventoryItem, _ int) bool {
+				return isNamespace(item) && !item.ExternallyManaged && !kept.Contains(item.ObjMetadata) && !pruned.Contains(item.ObjMetadata)
+			})...)
+			if err != nil {
+				return Result{}, &StageError{Stage: StagePrune, err: &PruneError{
+					Failed: deleted.Failed,
+					err:    fmt.Errorf("error pruning orphaned namespaces: %w", err),
+				}}
+			}
+		}
+	}
+
+	for idx, item := range result.Inventory.Items {
+		if hash, ok := hashes[item.ObjMetadata]; ok {
+			result.Inventory.Items[idx].ContentHash = hash
 		}
 	}
 
-	return inventory, nil
+	if store != nil {
+		if err := store.Save(ctx, opts.InventoryKey, result.Inventory); err != nil {
+			return Result{}, fmt.Errorf("error saving inventory: %w", err)
+		}
+	}
+
+	return result, nil
 }
 
-func (r *Reconciler) removeItems(previousInventory Inventory, newInventory Inventory, opts ApplyOpts) error {
-	toRemove := previousInventory.ItemsToRemove(newInventory)
-	if len(toRemove) == 0 {
-		return nil
+// stagesFor splits objects into stages as opts asks for, running transformers over them first, see getResourceStages
+func (r *Reconciler) stagesFor(objects []*unstructured.Unstructured, transformers []Transformer, opts ApplyOpts) ([]*unstructured.Unstructured, []*unstructured.Unstructured, []*unstructured.Unstructured, error) {
+	stageOne, stageTwo, paused, err := getResourceStages(objects, r.stages, transformers)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("error getting resource stages: %w", err)
+	}
+	if opts.DisableStaging {
+		stageOne, stageTwo = []*unstructured.Unstructured{}, lo.Reject(objects, func(obj *unstructured.Unstructured, _ int) bool {
+			return isPaused(obj)
+		})
+	}
+	return stageOne, stageTwo, paused, nil
+}
+
+// decorateStages makes the changes opts asks goply to make to the objects before they're applied: annotations,
+// labels, and ordering
+func (r *Reconciler) decorateStages(stageOne []*unstructured.Unstructured, stageTwo []*unstructured.Unstructured, opts ApplyOpts, reconcileID string) error {
+	if opts.RecordLastApplied {
+		if err := recordLastApplied(stageOne); err != nil {
+			return err
+		}
+		if err := recordLastApplied(stageTwo); err != nil {
+			return err
+		}
+	}
+
+	if opts.StampReconcileID {
+		stampReconcileID(stageOne, reconcileID)
+		stampReconcileID(stageTwo, reconcileID)
+	}
+
+	if opts.Owner != nil {
+		r.mgr.SetOwnerLabels(stageOne, opts.Owner.Name, opts.Owner.Namespace)
+		r.mgr.SetOwnerLabels(stageTwo, opts.Owner.Name, opts.Owner.Namespace)
+	}
+	if len(opts.ManagementLabels) > 0 {
+		stampLabels(stageOne, opts.ManagementLabels)
+		stampLabels(stageTwo, opts.ManagementLabels)
 	}
 
-	r.log("pruning resources")
-	return r.delete(toRemove, DeleteOpts{WaitTimeout: opts.WaitTimeout, SkipWait: opts.SkipWait})
+	if opts.SortByKind {
+		sortByKind(stageOne)
+		sortByKind(stageTwo)
+	}
+	return nil
 }
 
-func (r *Reconciler) delete(items []*unstructured.Unstructured, opts DeleteOpts) error {
-	if opts.WaitTimeout == nil {
-		opts.WaitTimeout = ptr(DefaultTimeout)
+func scaledWaitTimeout(opts ApplyOpts, count int) time.Duration {
+	return *opts.WaitTimeout + time.Duration(count)*opts.WaitTimeoutPerObject
+}
+
+// skipMissingKinds filters out objects whose kind the API server doesn't know about, recording them as skipped
+func (r *Reconciler) skipMissingKinds(ctx context.Context, objects []*unstructured.Unstructured, result *Result) []*unstructured.Unstructured {
+	mapper := r.mgr.Client().RESTMapper()
+
+	return lo.Filter(objects, func(obj *unstructured.Unstructured, _ int) bool {
+		gvk := obj.GroupVersionKind()
+		_, err := mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+		if err == nil || !meta.IsNoMatchError(err) {
+			// Any other lookup failure will be reported by the apply
+			return true
+		}
+
+		r.log(ctx, "skipping object, kind is not installed in the cluster", objectKV(obj)...)
+		result.Skipped = append(result.Skipped, SkippedItem{
+			InventoryItem: toInventoryItem(obj),
+			Reason:        SkipReasonMissingKind,
+		})
+		return false
+	})
+}
+
+func (r *Reconciler) applyStage(ctx context.Context, objects []*unstructured.Unstructured, opts ApplyOpts, result *Result) error {
+	if opts.CreateOnly {
+		var err error
+		objects, err = r.skipExisting(ctx, objects, result)
+		if err != nil {
+			return err
+		}
+	}
+
+	if opts.PreserveMetadata {
+		r.preserveMetadata(ctx, objects, opts)
 	}
 
-	r.log("beginning delete of resources")
-	_, err := r.mgr.DeleteAll(context.TODO(), items, ssa.DeleteOptions{PropagationPolicy: metav1.DeletePropagationForeground})
+	if len(opts.PatchStrategies) > 0 {
+		var err error
+		objects, err = r.applyPatched(ctx, objects, opts, result)
+		if err != nil {
+			return newApplyError(err)
+		}
+	}
+
+	adopting := []*unstructured.Unstructured{}
+	if opts.Adopt {
+		var err error
+		adopting, err = r.adoptionCandidates(ctx, objects)
+		if err != nil {
+			return err
+		}
+	}
+
+	if !opts.TakeOver && (opts.ConflictResolver != nil || !opts.ConflictPolicy.forces()) {
+		var err error
+		objects, err = r.resolveConflicts(ctx, objects, adopting, opts, result)
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(opts.IgnoreFields) > 0 {
+		var err error
+		objects, err = r.skipIgnoredDrift(ctx, objects, opts, result)
+		if err != nil {
+			return err
+		}
+	}
+
+	if opts.DriftOnly {
+		objects = r.skipUnchanged(ctx, objects, result)
+	}
+
+	if err := r.runPreApplyHooks(ctx, objects); err != nil {
+		return err
+	}
+
+	var ownedBefore map[object.ObjMetadata]*fieldpath.Set
+	if opts.TrackOwnedFields {
+		var err error
+		ownedBefore, err = r.ownedFields(ctx, objects)
+		if err != nil {
+			return err
+		}
+	}
+
+	changeSet, err := r.withRBACPropagation(ctx, opts, result, func() (*ssa.ChangeSet, error) {
+		return r.applyAll(ctx, objects, opts)
+	})
 	if err != nil {
-		return fmt.Errorf("error during deletion: %w", err)
+		var cancelled *CancelledError
+		if errors.As(err, &cancelled) {
+			result.addChangeSet(changeSet)
+			return err
+		}
+		return newApplyError(r.reportWebhookRejection(ctx, err))
 	}
+	result.addChangeSet(changeSet)
 
-	if !opts.SkipWait {
-		r.log("waiting for resources to terminate")
-		err = r.mgr.WaitForTermination(items, ssa.WaitOptions{
-			Interval: 2 * time.Second,
-			Timeout:  *opts.WaitTimeout,
-		})
+	if err := r.runPostApplyHooks(ctx, objects, *opts.WaitTimeout); err != nil {
+		return err
+	}
+
+	if opts.TrackOwnedFields {
+		ownedAfter, err := r.ownedFields(ctx, objects)
+		if err != nil {
+			return err
+		}
+		for _, change := range ownedFieldsChanges(objects, ownedBefore, ownedAfter) {
+			r.log(ctx, "fields owned by goply changed", append(itemKV(change.InventoryItem), "gained", len(change.Gained), "lost", len(change.Lost))...)
+			result.OwnedFieldsChanges = append(result.OwnedFieldsChanges, change)
+		}
+	}
+
+	for _, obj := range lo.Intersect(adopting, objects) {
+		r.log(ctx, "adopted existing object", objectKV(obj)...)
+		result.Adopted = append(result.Adopted, toInventoryItem(obj))
 	}
 
 	return nil
 }
 
-func (r *Reconciler) Delete(yaml string, opts DeleteOpts) error {
-	allObjects, err := GetObjects(yaml)
-	if err != nil {
-		return fmt.Errorf("error decoding yaml to unstructured: %w", err)
+func ssaApplyOptions(opts ApplyOpts) ssa.ApplyOptions {
+	ssaOpts := ssa.ApplyOptions{}
+
+	if opts.MigrateToServerSide {
+		ssaOpts.Cleanup = ssa.ApplyCleanupOptions{
+			Annotations: []string{corev1.LastAppliedConfigAnnotation},
+			FieldManagers: []ssa.FieldManager{
+				// kubectl-client-side-apply, as well as plain kubectl create/edit/patch
+				{Name: "kubectl", OperationType: metav1.ManagedFieldsOperationUpdate},
+				{Name: "before-first-apply", OperationType: metav1.ManagedFieldsOperationUpdate},
+			},
+		}
 	}
 
-	return r.delete(allObjects, opts)
+	return ssaOpts
+}
+
+func (r *Reconciler) removeItems(ctx context.Context, previousInventory Inventory, result *Result, opts ApplyOpts) error {
+	previous := previousInventory.Index()
+	toRemove := lo.Filter(previousInventory.ItemsToRemove(result.Inventory), func(u *unstructured.Unstructured, _ int) bool {
+		item, _ := previous.Get(object.UnstructuredToObjMetadata(u))
+		if item.ExternallyManaged {
+			r.log(ctx, "not pruning object, it is externally managed", objectKV(u)...)
+			return false
+		}
+		// Left to pruneOrphanedNamespaces, which only deletes the ones that are empty
+		if opts.PruneOrphanedNamespaces && isNamespace(item) {
+			return false
+		}
+		return true
+	})
+
+	if len(opts.PruneExclusions) > 0 || len(opts.NamespaceScope) > 0 {
+		excluded := newSet(opts.PruneExclusions...)
+		toRemove = lo.Filter(toRemove, func(u *unstructured.Unstructured, _ int) bool {
+			id := object.UnstructuredToObjMetadata(u)
+			switch {
+			case excluded.Contains(id):
+				r.log(ctx, "not pruning object, it is excluded from pruning", objectKV(u)...)
+			case !inNamespaceScope(u, opts.NamespaceScope):
+				r.log(ctx, "not pruning object, it is outside the namespace scope", objectKV(u)...)
+			default:
+				return true
+			}
+
+			// Keep tracking it, so it's pruned once it's no longer held back
+			item, _ := previous.Get(id)
+			result.Inventory.Items = append(result.Inventory.Items, item)
+			return false
+		})
+	}
+
+	if len(toRemove) == 0 {
+		return nil
+	}
+
+	if err := checkPruneLimits(toRemove, opts); err != nil {
+		return &PruneError{err: err}
+	}
+	if err := checkCRDDeletion(toRemove, opts.AllowCRDDeletion); err != nil {
+		return &PruneError{err: err}
+	}
+	if opts.PrePrune != nil {
+		if err := opts.PrePrune(toInventoryItems(toRemove)); err != nil {
+			return &PruneError{err: fmt.Errorf("%w, pre-prune hook failed: %w", ErrPruneRefusedError, err)}
+		}
+	}
+
+	r.log(ctx, "pruning resources")
+	deleted, err := r.delete(ctx, toRemove, DeleteOpts{WaitTimeout: opts.WaitTimeout, SkipWait: opts.SkipWait, AllowCRDDeletion: opts.AllowCRDDeletion})
+	result.Pruned = deleted.Deleted
+	if err != nil {
+		return &PruneError{Failed: deleted.Failed, err: err}
+	}
+	return nil
 }
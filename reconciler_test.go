@@ -2,11 +2,21 @@ package goply
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
+	"net/http"
 	"os"
 	"sort"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/fluxcd/cli-utils/pkg/kstatus/polling/event"
+	"github.com/fluxcd/cli-utils/pkg/kstatus/status"
+	fluxobject "github.com/fluxcd/cli-utils/pkg/object"
+	"github.com/fluxcd/pkg/ssa"
 	"github.com/lithammer/dedent"
 	"github.com/samber/lo"
 	log "github.com/sirupsen/logrus"
@@ -14,8 +24,12 @@ import (
 	corev1 "k8s.io/api/core/v1"
 	k8serr "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/tools/clientcmd"
+	"sigs.k8s.io/cli-utils/pkg/object"
 )
 
 func requireLive(t *testing.T) {
@@ -64,6 +78,493 @@ func basicSetup(t *testing.T, namespace string) (*Reconciler, *kubernetes.Client
 	return r, client, cleanup
 }
 
+// offlineKubeconfig points at a cluster that doesn't exist
+var offlineKubeconfig = dedent.Dedent(`
+	apiVersion: v1
+	kind: Config
+	clusters:
+	- name: offline
+	  cluster:
+	    server: https://127.0.0.1:1
+	contexts:
+	- name: offline
+	  context:
+	    cluster: offline
+	    user: offline
+	current-context: offline
+	users:
+	- name: offline
+	  user:
+	    token: offline
+`)[1:]
+
+// offlineReconciler builds a reconciler pointed at a cluster that doesn't exist, for exercising code paths that never
+// talk to the API server
+func offlineReconciler(t *testing.T) *Reconciler {
+	t.Helper()
+
+	r, err := NewReconciler(&ReconcilerConfig{
+		Kubeconfig: offlineKubeconfig,
+	})
+	require.NoError(t, err)
+
+	return r
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// coreDiscovery answers the discovery requests for the core group with just Namespaces and ConfigMaps in it, so
+// requests for them get as far as the transport
+var coreDiscovery = map[string]string{
+	"/api":  `{"kind":"APIVersions","versions":["v1"]}`,
+	"/apis": `{"kind":"APIGroupList","apiVersion":"v1","groups":[]}`,
+	"/api/v1": `{"kind":"APIResourceList","groupVersion":"v1","resources":[` +
+		`{"name":"namespaces","namespaced":false,"kind":"Namespace","verbs":["get","list","delete"]},` +
+		`{"name":"configmaps","namespaced":true,"kind":"ConfigMap","verbs":["get","list","patch"]}]}`,
+}
+
+func jsonResponse(req *http.Request, code int, body string) *http.Response {
+	return &http.Response{
+		StatusCode: code,
+		Header:     http.Header{"Content-Type": []string{"application/json"}},
+		Body:       io.NopCloser(strings.NewReader(body)),
+		Request:    req,
+	}
+}
+
+func TestStages(t *testing.T) {
+	r := offlineReconciler(t)
+
+	stages, err := r.Stages(dedent.Dedent(`
+		---
+		apiVersion: v1
+		kind: Service
+		metadata:
+		  name: service-one
+		  namespace: goply-test
+		spec:
+		  ports:
+		  - port: 80
+		---
+		apiVersion: v1
+		kind: Namespace
+		metadata:
+		  name: goply-test
+	`)[1:])
+	require.NoError(t, err)
+
+	require.Equal(t, []string{"goply-test"}, lo.Map(stages.StageOne, func(u *unstructured.Unstructured, _ int) string { return u.GetName() }))
+	require.Equal(t, []string{"service-one"}, lo.Map(stages.StageTwo, func(u *unstructured.Unstructured, _ int) string { return u.GetName() }))
+
+	// Normalization should have defaulted the port protocol
+	ports, _, err := unstructured.NestedSlice(stages.StageTwo[0].Object, "spec", "ports")
+	require.NoError(t, err)
+	require.Equal(t, "TCP", ports[0].(map[string]any)["protocol"])
+}
+
+func TestStageOverrides(t *testing.T) {
+	r, err := NewReconciler(&ReconcilerConfig{
+		Kubeconfig:         offlineKubeconfig,
+		ForceStageOneKinds: []schema.GroupKind{{Group: "example.com", Kind: "Operator"}},
+		ForceStageTwoKinds: []schema.GroupKind{{Kind: "Namespace"}},
+	})
+	require.NoError(t, err)
+
+	stages, err := r.Stages(dedent.Dedent(`
+		---
+		apiVersion: v1
+		kind: Namespace
+		metadata:
+		  name: goply-test
+		---
+		apiVersion: example.com/v1
+		kind: Operator
+		metadata:
+		  name: operator-one
+	`)[1:])
+	require.NoError(t, err)
+
+	require.Equal(t, []string{"operator-one"}, lo.Map(stages.StageOne, func(u *unstructured.Unstructured, _ int) string { return u.GetName() }))
+	require.Equal(t, []string{"goply-test"}, lo.Map(stages.StageTwo, func(u *unstructured.Unstructured, _ int) string { return u.GetName() }))
+
+	_, err = NewReconciler(&ReconcilerConfig{
+		Kubeconfig:         offlineKubeconfig,
+		ForceStageOneKinds: []schema.GroupKind{{Kind: "Namespace"}},
+		ForceStageTwoKinds: []schema.GroupKind{{Kind: "Namespace"}},
+	})
+	require.ErrorIs(t, err, ErrConflictingStageOverrideError)
+}
+
+func TestGenerateNameRejected(t *testing.T) {
+	r := offlineReconciler(t)
+
+	_, err := r.Stages(dedent.Dedent(`
+		---
+		apiVersion: batch/v1
+		kind: Job
+		metadata:
+		  generateName: migrate-
+		  namespace: goply-test
+		spec:
+		  template:
+		    spec:
+		      restartPolicy: Never
+		      containers:
+		      - name: migrate
+		        image: busybox
+	`)[1:])
+	require.ErrorIs(t, err, ErrGenerateNameError)
+	require.ErrorContains(t, err, "Job with generateName migrate-")
+}
+
+func TestPausedObjects(t *testing.T) {
+	objs, err := GetObjects(dedent.Dedent(`
+		---
+		apiVersion: v1
+		kind: Namespace
+		metadata:
+		  name: goply-test
+		  annotations:
+		    goply.io/pause: "true"
+		---
+		apiVersion: v1
+		kind: ConfigMap
+		metadata:
+		  name: config-one
+		  namespace: goply-test
+		  annotations:
+		    goply.io/pause: "true"
+		---
+		apiVersion: v1
+		kind: ConfigMap
+		metadata:
+		  name: config-two
+		  namespace: goply-test
+		  annotations:
+		    goply.io/pause: "false"
+	`)[1:])
+	require.NoError(t, err)
+
+	stageOne, stageTwo, paused, err := getResourceStages(objs, stageOverrides{}, nil)
+	require.NoError(t, err)
+	require.Empty(t, stageOne)
+	require.Equal(t, []string{"config-two"}, lo.Map(stageTwo, func(u *unstructured.Unstructured, _ int) string { return u.GetName() }))
+	require.Equal(t, []string{"goply-test", "config-one"}, lo.Map(paused, func(u *unstructured.Unstructured, _ int) string { return u.GetName() }))
+}
+
+func TestDeleteRequireConfirmation(t *testing.T) {
+	r := offlineReconciler(t)
+	yaml := dedent.Dedent(`
+		---
+		apiVersion: v1
+		kind: ConfigMap
+		metadata:
+		  name: config-one
+		  namespace: goply-test
+		---
+		apiVersion: v1
+		kind: ConfigMap
+		metadata:
+		  name: config-two
+		  namespace: goply-test
+	`)[1:]
+	objs, err := GetObjects(yaml)
+	require.NoError(t, err)
+	items := toInventoryItems(objs)
+
+	token := ConfirmationToken(items)
+	require.Equal(t, token, ConfirmationToken(lo.Reverse(append([]InventoryItem{}, items...))))
+	require.NotEqual(t, token, ConfirmationToken(items[:1]))
+
+	_, err = r.Delete(yaml, DeleteOpts{RequireConfirmation: true})
+	require.ErrorIs(t, err, ErrDeleteNotConfirmedError)
+	require.ErrorContains(t, err, token)
+
+	_, err = r.Delete(yaml, DeleteOpts{RequireConfirmation: true, ConfirmationToken: ConfirmationToken(items[:1])})
+	require.ErrorIs(t, err, ErrDeleteNotConfirmedError)
+}
+
+func TestCRDDeletionRefused(t *testing.T) {
+	r := offlineReconciler(t)
+	yaml := dedent.Dedent(`
+		---
+		apiVersion: apiextensions.k8s.io/v1
+		kind: CustomResourceDefinition
+		metadata:
+		  name: widgets.example.com
+		---
+		apiVersion: v1
+		kind: ConfigMap
+		metadata:
+		  name: config-one
+		  namespace: goply-test
+	`)[1:]
+
+	_, err := r.Delete(yaml, DeleteOpts{})
+	require.ErrorIs(t, err, ErrCRDDeletionError)
+	require.ErrorContains(t, err, "widgets.example.com")
+
+	_, err = r.Delete(yaml, DeleteOpts{DryRun: true})
+	require.ErrorIs(t, err, ErrCRDDeletionError)
+
+	// Pruning is refused the same way
+	objs, err := GetObjects(yaml)
+	require.NoError(t, err)
+	err = r.removeItems(context.Background(), Inventory{Items: toInventoryItems(objs)}, &Result{}, ApplyOpts{})
+	require.ErrorIs(t, err, ErrCRDDeletionError)
+}
+
+func TestExpectedAPIServerURL(t *testing.T) {
+	_, err := NewReconciler(&ReconcilerConfig{
+		Kubeconfig:           offlineKubeconfig,
+		ExpectedAPIServerURL: "https://127.0.0.1:1/",
+	})
+	require.NoError(t, err)
+
+	_, err = NewReconciler(&ReconcilerConfig{
+		Kubeconfig:           offlineKubeconfig,
+		ExpectedAPIServerURL: "https://prod.example.com",
+	})
+	require.ErrorIs(t, err, ErrUnexpectedClusterError)
+}
+
+func TestWrapTransport(t *testing.T) {
+	var paths []string
+	r, err := NewReconciler(&ReconcilerConfig{
+		Kubeconfig: offlineKubeconfig,
+		WrapTransport: func(http.RoundTripper) http.RoundTripper {
+			return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+				paths = append(paths, req.URL.Path)
+				return nil, errors.New("intercepted")
+			})
+		},
+	})
+	require.NoError(t, err)
+
+	err = r.Preflight(context.Background(), PreflightOpts{})
+	require.ErrorContains(t, err, "intercepted")
+	require.Equal(t, []string{"/readyz"}, paths)
+}
+
+func TestRequestTimeout(t *testing.T) {
+	r, err := NewReconciler(&ReconcilerConfig{
+		Kubeconfig:     offlineKubeconfig,
+		RequestTimeout: 50 * time.Millisecond,
+		WrapTransport: func(http.RoundTripper) http.RoundTripper {
+			// Hang until the request is given up on
+			return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+				<-req.Context().Done()
+				return nil, req.Context().Err()
+			})
+		},
+	})
+	require.NoError(t, err)
+
+	start := time.Now()
+	err = r.Preflight(context.Background(), PreflightOpts{})
+	require.Error(t, err)
+	require.Less(t, time.Since(start), 5*time.Second)
+}
+
+func TestWaitCancelled(t *testing.T) {
+	r, err := NewReconciler(&ReconcilerConfig{
+		Kubeconfig: offlineKubeconfig,
+		WrapTransport: func(http.RoundTripper) http.RoundTripper {
+			// Answer discovery, then hang on status reads until the wait gives up on them
+			return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+				if body, ok := coreDiscovery[req.URL.Path]; ok {
+					return jsonResponse(req, http.StatusOK, body), nil
+				}
+				<-req.Context().Done()
+				return nil, req.Context().Err()
+			})
+		},
+	})
+	require.NoError(t, err)
+
+	objs, err := GetObjects(dedent.Dedent(`
+		---
+		apiVersion: v1
+		kind: ConfigMap
+		metadata:
+		  name: config-one
+		  namespace: goply-test
+	`)[1:])
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(withStage(context.Background(), StageTwo))
+	time.AfterFunc(200*time.Millisecond, cancel)
+
+	start := time.Now()
+	err = r.wait(ctx, objs, ssa.WaitOptions{Timeout: time.Minute, Interval: time.Second}, ApplyOpts{}, nil)
+	var cancelled *CancelledError
+	require.ErrorAs(t, err, &cancelled)
+	require.Equal(t, StageTwo, cancelled.Stage)
+	require.ErrorIs(t, err, context.Canceled)
+	require.Less(t, time.Since(start), 10*time.Second)
+}
+
+func TestScaledWaitTimeout(t *testing.T) {
+	require.Equal(t, time.Minute, scaledWaitTimeout(ApplyOpts{WaitTimeout: ptr(time.Minute)}, 100))
+	require.Equal(
+		t,
+		time.Minute+50*time.Second,
+		scaledWaitTimeout(ApplyOpts{WaitTimeout: ptr(time.Minute), WaitTimeoutPerObject: 500 * time.Millisecond}, 100),
+	)
+}
+
+func TestWaitBackoff(t *testing.T) {
+	backoff := WaitBackoff{Max: 3 * time.Second}.withDefaults()
+	require.Equal(t, 500*time.Millisecond, backoff.Initial)
+
+	intervals := []time.Duration{backoff.Initial}
+	for range 3 {
+		intervals = append(intervals, backoff.next(intervals[len(intervals)-1]))
+	}
+	require.Equal(t, []time.Duration{500 * time.Millisecond, time.Second, 2 * time.Second, 3 * time.Second}, intervals)
+}
+
+func TestWaitBatchTimeout(t *testing.T) {
+	objs, err := GetObjects(dedent.Dedent(`
+		---
+		apiVersion: v1
+		kind: ConfigMap
+		metadata:
+		  name: config-one
+		  namespace: goply-test
+		---
+		apiVersion: v1
+		kind: ConfigMap
+		metadata:
+		  name: config-two
+		  namespace: goply-test
+	`)[1:])
+	require.NoError(t, err)
+
+	// With no time left, the first batch times out straight away, and the second is reported without being waited on
+	err = offlineReconciler(t).wait(context.Background(), objs, ssa.WaitOptions{Timeout: 0}, ApplyOpts{WaitBatchSize: 1}, nil)
+	var timeoutErr *WaitTimeoutError
+	require.ErrorAs(t, err, &timeoutErr)
+	require.Equal(t, []string{"config-one", "config-two"}, lo.Map(timeoutErr.Objects, func(o ObjectStatus, _ int) string { return o.Name }))
+	require.Equal(t, "timed out before it was waited on", timeoutErr.Objects[1].Message)
+}
+
+func TestCriticalObjects(t *testing.T) {
+	objs, err := GetObjects(dedent.Dedent(`
+		---
+		apiVersion: apps/v1
+		kind: Deployment
+		metadata:
+		  name: main
+		  namespace: goply-test
+		  labels:
+		    goply.io/critical: "true"
+		    tier: web
+		---
+		apiVersion: apps/v1
+		kind: Deployment
+		metadata:
+		  name: worker
+		  namespace: goply-test
+		  labels:
+		    tier: web
+		---
+		apiVersion: batch/v1
+		kind: Job
+		metadata:
+		  name: migrate
+		  namespace: goply-test
+		---
+		apiVersion: v1
+		kind: ConfigMap
+		metadata:
+		  name: config
+		  namespace: goply-test
+	`)[1:])
+	require.NoError(t, err)
+
+	names := func(opts ApplyOpts) []string {
+		return lo.Map(criticalObjects(objs, opts), func(u *unstructured.Unstructured, _ int) string { return u.GetName() })
+	}
+
+	require.Equal(t, []string{"main", "worker", "migrate", "config"}, names(ApplyOpts{}))
+	require.Equal(t, []string{"main"}, names(ApplyOpts{WaitForLabels: map[string]string{"goply.io/critical": "true", "tier": "web"}}))
+	require.Equal(t, []string{"migrate"}, names(ApplyOpts{CriticalKinds: []schema.GroupKind{{Group: "batch", Kind: "Job"}}}))
+	require.Equal(t, []string{"main", "migrate"}, names(ApplyOpts{
+		WaitForLabels: map[string]string{"goply.io/critical": "true"},
+		CriticalKinds: []schema.GroupKind{{Group: "batch", Kind: "Job"}},
+	}))
+}
+
+func TestReadiness(t *testing.T) {
+	id := fluxobject.ObjMetadata{Namespace: "goply-test", Name: "config-one", GroupKind: schema.GroupKind{Kind: "ConfigMap"}}
+	ready := &readiness{start: time.Now().Add(-time.Minute), after: map[object.ObjMetadata]time.Duration{}}
+
+	ready.observe(&event.ResourceStatus{Identifier: id, Status: status.InProgressStatus})
+	require.Empty(t, ready.after)
+
+	ready.observe(&event.ResourceStatus{Identifier: id, Status: status.CurrentStatus})
+	first := ready.after[fromFluxObjMetadata(id)]
+	require.GreaterOrEqual(t, first, time.Minute)
+
+	// Only the first time it's seen current counts
+	ready.start = time.Now().Add(-time.Hour)
+	ready.observe(&event.ResourceStatus{Identifier: id, Status: status.CurrentStatus})
+	require.Equal(t, first, ready.after[fromFluxObjMetadata(id)])
+
+	// Waits without a result don't record anything
+	(*readiness)(nil).observe(&event.ResourceStatus{Identifier: id, Status: status.CurrentStatus})
+}
+
+func TestCheckObservedGeneration(t *testing.T) {
+	resource := func(generation int64, observed any) *event.ResourceStatus {
+		obj := &unstructured.Unstructured{Object: map[string]any{}}
+		obj.SetGeneration(generation)
+		if observed != nil {
+			obj.Object["status"] = map[string]any{"observedGeneration": observed}
+		}
+		return &event.ResourceStatus{Status: status.CurrentStatus, Resource: obj}
+	}
+
+	require.Equal(t, status.InProgressStatus, checkObservedGeneration(resource(2, int64(1))).Status)
+	require.Equal(t, status.CurrentStatus, checkObservedGeneration(resource(2, int64(2))).Status)
+	// Kinds without an observedGeneration can't lag
+	require.Equal(t, status.CurrentStatus, checkObservedGeneration(resource(2, nil)).Status)
+}
+
+func TestPVCWaitsForBound(t *testing.T) {
+	pvc := func(phase string) *unstructured.Unstructured {
+		objs, err := GetObjects(dedent.Dedent(fmt.Sprintf(`
+			apiVersion: v1
+			kind: PersistentVolumeClaim
+			metadata:
+			  name: pvc-one
+			  namespace: goply-test
+			spec:
+			  accessModes: [ReadWriteOnce]
+			  resources:
+			    requests:
+			      storage: 1Mi
+			status:
+			  phase: %v
+		`, phase))[1:])
+		require.NoError(t, err)
+		return objs[0]
+	}
+
+	pending, err := status.Compute(pvc("Pending"))
+	require.NoError(t, err)
+	require.Equal(t, status.InProgressStatus, pending.Status)
+
+	bound, err := status.Compute(pvc("Bound"))
+	require.NoError(t, err)
+	require.Equal(t, status.CurrentStatus, bound.Status)
+}
+
 func TestReconcile(t *testing.T) {
 	const ns = "goply-reconcile-test"
 	r, client, cleanup := basicSetup(t, ns)
@@ -93,8 +594,9 @@ func TestReconcile(t *testing.T) {
 		data:
 		  bar: bar1
 	`, ns, ns, ns))[1:]
-	origInventory, err := r.Apply(origYaml, ApplyOpts{})
+	origResult, err := r.Apply(origYaml, ApplyOpts{})
 	require.NoError(t, err)
+	origInventory := origResult.Inventory
 
 	// Should have an NS and 2 config maps
 	allConfigmaps, err := client.CoreV1().ConfigMaps(ns).List(context.TODO(), metav1.ListOptions{})
@@ -121,8 +623,9 @@ func TestReconcile(t *testing.T) {
 		data:
 		  bar: bar1
 	`, ns, ns))[1:]
-	newInventory, err := r.Reconcile(newYaml, ApplyOpts{}, &origInventory)
+	newResult, err := r.Reconcile(newYaml, ApplyOpts{}, &origInventory)
 	require.NoError(t, err)
+	newInventory := newResult.Inventory
 
 	// Should only have one configmap now
 	allConfigmaps, err = client.CoreV1().ConfigMaps(ns).List(context.TODO(), metav1.ListOptions{})
@@ -154,11 +657,1756 @@ func TestDelete(t *testing.T) {
 	_, err = client.CoreV1().Namespaces().Get(context.TODO(), ns, metav1.GetOptions{})
 	require.NoError(t, err)
 
+	// Dry-run deleting it should leave it in place
+	dryRunResult, err := r.Delete(yaml, DeleteOpts{DryRun: true})
+	require.NoError(t, err)
+	require.Equal(t, 1, len(dryRunResult.Deleted))
+	_, err = client.CoreV1().Namespaces().Get(context.TODO(), ns, metav1.GetOptions{})
+	require.NoError(t, err)
+
 	// Delete that same yaml
-	err = r.Delete(yaml, DeleteOpts{})
+	deleteResult, err := r.Delete(yaml, DeleteOpts{})
 	require.NoError(t, err)
+	require.Equal(t, 1, len(deleteResult.Deleted))
 
 	// Should not have a namespace
 	_, err = client.CoreV1().Namespaces().Get(context.TODO(), ns, metav1.GetOptions{})
 	require.Error(t, err)
+
+	// Deleting again is a no-op rather than a failure
+	deleteResult, err = r.Delete(yaml, DeleteOpts{})
+	require.NoError(t, err)
+	require.Equal(t, "requested 1, deleted 0, absent 1, failed 0", deleteResult.String())
+}
+
+func TestSkipMissingKinds(t *testing.T) {
+	const ns = "goply-skip-missing-kinds-test"
+	r, client, cleanup := basicSetup(t, ns)
+	defer cleanup()
+
+	yaml := dedent.Dedent(fmt.Sprintf(`
+		---
+		apiVersion: v1
+		kind: Namespace
+		metadata:
+		  name: %v
+		---
+		apiVersion: v1
+		kind: ConfigMap
+		metadata:
+		  name: config-one
+		  namespace: %v
+		data:
+		  foo: foo1
+		---
+		apiVersion: goply.example.com/v1
+		kind: NotInstalled
+		metadata:
+		  name: not-installed
+		  namespace: %v
+	`, ns, ns, ns))[1:]
+
+	// Without the option, the unknown kind fails the apply
+	_, err := r.Apply(yaml, ApplyOpts{})
+	require.Error(t, err)
+
+	result, err := r.Apply(yaml, ApplyOpts{SkipMissingKinds: true})
+	require.NoError(t, err)
+
+	_, err = client.CoreV1().ConfigMaps(ns).Get(context.TODO(), "config-one", metav1.GetOptions{})
+	require.NoError(t, err)
+
+	require.Equal(t, 2, len(result.Inventory.Items))
+	require.Equal(t, 1, len(result.Skipped))
+	require.Equal(t, "not-installed", result.Skipped[0].Name)
+	require.Equal(t, SkipReasonMissingKind, result.Skipped[0].Reason)
+}
+
+func TestDeleteOrder(t *testing.T) {
+	obj := func(kind string, name string, owners ...metav1.OwnerReference) *unstructured.Unstructured {
+		u := &unstructured.Unstructured{}
+		u.SetKind(kind)
+		u.SetName(name)
+		u.SetUID(types.UID(name))
+		u.SetOwnerReferences(owners)
+		return u
+	}
+	ownedBy := func(name string, controller bool) metav1.OwnerReference {
+		return metav1.OwnerReference{Name: name, UID: types.UID(name), Controller: &controller}
+	}
+
+	names := func(objs []*unstructured.Unstructured) []string {
+		return lo.Map(objs, func(u *unstructured.Unstructured, _ int) string { return u.GetName() })
+	}
+
+	// Owners that only reference their children go after them
+	require.Equal(t, []string{"config", "app", "other"}, names(deleteOrder([]*unstructured.Unstructured{
+		obj("App", "app"),
+		obj("ConfigMap", "config", ownedBy("app", false)),
+		obj("ConfigMap", "other"),
+	})))
+
+	// Controlled children are left to their owner's foreground deletion
+	require.Equal(t, []string{"deploy", "rs"}, names(deleteOrder([]*unstructured.Unstructured{
+		obj("ReplicaSet", "rs", ownedBy("deploy", true)),
+		obj("Deployment", "deploy"),
+	})))
+
+	// Owners outside the set leave the order alone
+	require.Equal(t, []string{"one", "two"}, names(deleteOrder([]*unstructured.Unstructured{
+		obj("ConfigMap", "one", ownedBy("elsewhere", false)),
+		obj("ConfigMap", "two"),
+	})))
+
+	// Cycles don't lose anything
+	require.ElementsMatch(t, []string{"one", "two"}, names(deleteOrder([]*unstructured.Unstructured{
+		obj("ConfigMap", "one", ownedBy("two", false)),
+		obj("ConfigMap", "two", ownedBy("one", false)),
+	})))
+}
+
+func TestDeleteKindPolicies(t *testing.T) {
+	const ns = "goply-delete-kind-policies-test"
+	r, client, cleanup := basicSetup(t, ns)
+	defer cleanup()
+
+	yaml := dedent.Dedent(fmt.Sprintf(`
+		---
+		apiVersion: v1
+		kind: Namespace
+		metadata:
+		  name: %v
+		---
+		apiVersion: v1
+		kind: ConfigMap
+		metadata:
+		  name: config-one
+		  namespace: %v
+		data:
+		  foo: foo1
+	`, ns, ns))[1:]
+	_, err := r.Apply(yaml, ApplyOpts{})
+	require.NoError(t, err)
+
+	_, err = r.Delete(yaml, DeleteOpts{
+		KindPolicies: map[schema.GroupKind]DeletePolicy{
+			{Kind: "ConfigMap"}: {PropagationPolicy: metav1.DeletePropagationBackground, GracePeriodSeconds: ptr(int64(0))},
+		},
+	})
+	require.NoError(t, err)
+
+	_, err = client.CoreV1().Namespaces().Get(context.TODO(), ns, metav1.GetOptions{})
+	require.Error(t, err)
+}
+
+func TestMigrateToServerSide(t *testing.T) {
+	const ns = "goply-migrate-ssa-test"
+	r, client, cleanup := basicSetup(t, ns)
+	defer cleanup()
+
+	_, err := client.CoreV1().Namespaces().Create(
+		context.TODO(),
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: ns}},
+		metav1.CreateOptions{},
+	)
+	require.NoError(t, err)
+
+	// Simulate a `kubectl apply` (client-side) of the configmap
+	_, err = client.CoreV1().ConfigMaps(ns).Create(
+		context.TODO(),
+		&corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "config-one",
+				Namespace: ns,
+				Annotations: map[string]string{
+					corev1.LastAppliedConfigAnnotation: `{"apiVersion":"v1","kind":"ConfigMap"}`,
+				},
+			},
+			Data: map[string]string{"foo": "foo1", "bar": "bar1"},
+		},
+		metav1.CreateOptions{FieldManager: "kubectl-client-side-apply"},
+	)
+	require.NoError(t, err)
+
+	yaml := dedent.Dedent(fmt.Sprintf(`
+		---
+		apiVersion: v1
+		kind: Namespace
+		metadata:
+		  name: %v
+		---
+		apiVersion: v1
+		kind: ConfigMap
+		metadata:
+		  name: config-one
+		  namespace: %v
+		data:
+		  foo: foo2
+	`, ns, ns))[1:]
+	_, err = r.Apply(yaml, ApplyOpts{MigrateToServerSide: true})
+	require.NoError(t, err)
+
+	cm, err := client.CoreV1().ConfigMaps(ns).Get(context.TODO(), "config-one", metav1.GetOptions{})
+	require.NoError(t, err)
+
+	managers := lo.Map(cm.ManagedFields, func(m metav1.ManagedFieldsEntry, _ int) string { return m.Manager })
+	require.NotContains(t, managers, "kubectl-client-side-apply")
+	require.Contains(t, managers, fieldManager)
+	require.NotContains(t, cm.Annotations, corev1.LastAppliedConfigAnnotation)
+	require.Equal(t, "foo2", cm.Data["foo"])
+}
+
+// Best run with -race
+func TestConcurrentApply(t *testing.T) {
+	namespaces := []string{"goply-concurrent-test-one", "goply-concurrent-test-two", "goply-concurrent-test-three"}
+	r, client, cleanup := basicSetup(t, namespaces[0])
+	defer cleanup()
+	for _, ns := range namespaces[1:] {
+		defer func() {
+			err := client.CoreV1().Namespaces().Delete(context.TODO(), ns, metav1.DeleteOptions{PropagationPolicy: ptr(metav1.DeletePropagationForeground)})
+			if !k8serr.IsNotFound(err) {
+				require.NoError(t, err)
+			}
+		}()
+	}
+
+	// Everything the applies report is shared between them, so it's all recorded under the lock
+	var mu sync.Mutex
+	logs := []string{}
+	applied := map[string][]string{}
+	results := map[string]Result{}
+
+	var wg sync.WaitGroup
+	errs := make([]error, len(namespaces))
+	for i, ns := range namespaces {
+		wg.Add(1)
+		go func(i int, ns string) {
+			defer wg.Done()
+			r.SetLogFunc(func(s string) {
+				mu.Lock()
+				defer mu.Unlock()
+				logs = append(logs, s)
+			})
+			result, err := r.Apply(dedent.Dedent(fmt.Sprintf(`
+				---
+				apiVersion: v1
+				kind: Namespace
+				metadata:
+				  name: %v
+				---
+				apiVersion: v1
+				kind: ConfigMap
+				metadata:
+				  name: config-one
+				  namespace: %v
+				data:
+				  foo: foo1
+			`, ns, ns))[1:], ApplyOpts{
+				ObjectProgress: func(update ProgressUpdate) {
+					if update.Phase != ProgressApplied {
+						return
+					}
+					mu.Lock()
+					defer mu.Unlock()
+					applied[ns] = append(applied[ns], update.Name)
+				},
+			})
+			mu.Lock()
+			defer mu.Unlock()
+			results[ns], errs[i] = result, err
+		}(i, ns)
+	}
+	wg.Wait()
+
+	mu.Lock()
+	defer mu.Unlock()
+	require.NotEmpty(t, logs)
+	for i, ns := range namespaces {
+		require.NoError(t, errs[i])
+		// Each apply only saw its own objects
+		require.ElementsMatch(t, []string{ns, "config-one"}, applied[ns])
+		require.ElementsMatch(t, []string{ns, "config-one"}, lo.Map(results[ns].Changes, func(c Change, _ int) string { return c.Name }))
+		require.ElementsMatch(t, []string{ns, "config-one"}, lo.Map(results[ns].Inventory.Items, func(i InventoryItem, _ int) string { return i.Name }))
+		for _, item := range results[ns].Inventory.Items {
+			require.Contains(t, []string{"", ns}, item.Namespace)
+		}
+		_, err := client.CoreV1().ConfigMaps(ns).Get(context.TODO(), "config-one", metav1.GetOptions{})
+		require.NoError(t, err)
+	}
+}
+
+func TestDeleteInventoryFiltered(t *testing.T) {
+	const ns = "goply-delete-inventory-filtered-test"
+	r, client, cleanup := basicSetup(t, ns)
+	defer cleanup()
+
+	yaml := dedent.Dedent(fmt.Sprintf(`
+		---
+		apiVersion: v1
+		kind: Namespace
+		metadata:
+		  name: %v
+		---
+		apiVersion: v1
+		kind: ConfigMap
+		metadata:
+		  name: config-one
+		  namespace: %v
+		data:
+		  foo: foo1
+		---
+		apiVersion: v1
+		kind: Secret
+		metadata:
+		  name: secret-one
+		  namespace: %v
+		stringData:
+		  foo: foo1
+	`, ns, ns, ns))[1:]
+	result, err := r.Apply(yaml, ApplyOpts{})
+	require.NoError(t, err)
+
+	_, err = r.DeleteInventoryFiltered(context.TODO(), result.Inventory, func(i InventoryItem) bool {
+		return i.GroupKind.Kind == "ConfigMap"
+	}, DeleteOpts{})
+	require.NoError(t, err)
+
+	_, err = client.CoreV1().ConfigMaps(ns).Get(context.TODO(), "config-one", metav1.GetOptions{})
+	require.True(t, k8serr.IsNotFound(err))
+	_, err = client.CoreV1().Secrets(ns).Get(context.TODO(), "secret-one", metav1.GetOptions{})
+	require.NoError(t, err)
+}
+
+func TestConflictPolicyWarn(t *testing.T) {
+	const ns = "goply-conflict-warn-test"
+	r, client, cleanup := basicSetup(t, ns)
+	defer cleanup()
+
+	yaml := dedent.Dedent(fmt.Sprintf(`
+		---
+		apiVersion: v1
+		kind: Namespace
+		metadata:
+		  name: %v
+		---
+		apiVersion: v1
+		kind: ConfigMap
+		metadata:
+		  name: config-one
+		  namespace: %v
+		data:
+		  foo: foo1
+		---
+		apiVersion: v1
+		kind: ConfigMap
+		metadata:
+		  name: config-two
+		  namespace: %v
+		data:
+		  bar: bar1
+	`, ns, ns, ns))[1:]
+	_, err := r.Apply(yaml, ApplyOpts{})
+	require.NoError(t, err)
+
+	// Some other manager takes over a field goply set
+	cm, err := client.CoreV1().ConfigMaps(ns).Get(context.TODO(), "config-one", metav1.GetOptions{})
+	require.NoError(t, err)
+	cm.Data["foo"] = "someone-else"
+	_, err = client.CoreV1().ConfigMaps(ns).Update(context.TODO(), cm, metav1.UpdateOptions{FieldManager: "someone-else"})
+	require.NoError(t, err)
+
+	events := []Event{}
+	r.SetEventFunc(func(e Event) { events = append(events, e) })
+
+	yaml = strings.ReplaceAll(strings.ReplaceAll(yaml, "foo1", "foo2"), "bar1", "bar2")
+
+	_, err = r.Apply(yaml, ApplyOpts{ConflictPolicy: ConflictPolicyFail})
+	require.ErrorIs(t, err, ErrConflictNotForcedError)
+
+	// The conflict isn't transient, so retrying doesn't help
+	_, err = r.Apply(yaml, ApplyOpts{ConflictPolicy: ConflictPolicyFail, ConflictRetries: 2, ConflictRetryBackoff: 10 * time.Millisecond})
+	require.ErrorIs(t, err, ErrConflictNotForcedError)
+	require.ErrorContains(t, err, "after 2 retries")
+
+	result, err := r.Apply(yaml, ApplyOpts{ConflictPolicy: ConflictPolicyWarn})
+	require.NoError(t, err)
+
+	require.Equal(t, []string{"config-one"}, lo.Map(result.Skipped, func(s SkippedItem, _ int) string { return s.Name }))
+	require.Equal(t, 1, len(events))
+	require.Equal(t, EventTypeWarning, events[0].Type)
+	require.Equal(t, "config-one", events[0].Object.Name)
+
+	// Conflicted object is left alone, the rest is applied
+	cm, err = client.CoreV1().ConfigMaps(ns).Get(context.TODO(), "config-one", metav1.GetOptions{})
+	require.NoError(t, err)
+	require.Equal(t, "someone-else", cm.Data["foo"])
+	cm, err = client.CoreV1().ConfigMaps(ns).Get(context.TODO(), "config-two", metav1.GetOptions{})
+	require.NoError(t, err)
+	require.Equal(t, "bar2", cm.Data["bar"])
+
+	// A resolver declining to force under the default policy skips the object rather than failing
+	yaml = strings.ReplaceAll(yaml, "foo2", "foo3")
+	result, err = r.Apply(yaml, ApplyOpts{ConflictResolver: func(*unstructured.Unstructured, []string) bool { return false }})
+	require.NoError(t, err)
+	require.Equal(t, []string{"config-one"}, lo.Map(result.Skipped, func(s SkippedItem, _ int) string { return s.Name }))
+	cm, err = client.CoreV1().ConfigMaps(ns).Get(context.TODO(), "config-one", metav1.GetOptions{})
+	require.NoError(t, err)
+	require.Equal(t, "someone-else", cm.Data["foo"])
+}
+
+func TestRecreateOnImmutableError(t *testing.T) {
+	const ns = "goply-recreate-immutable-test"
+	r, client, cleanup := basicSetup(t, ns)
+	defer cleanup()
+
+	yaml := dedent.Dedent(fmt.Sprintf(`
+		---
+		apiVersion: v1
+		kind: Namespace
+		metadata:
+		  name: %v
+		---
+		apiVersion: v1
+		kind: ConfigMap
+		metadata:
+		  name: config-one
+		  namespace: %v
+		immutable: true
+		data:
+		  foo: foo1
+	`, ns, ns))[1:]
+	_, err := r.Apply(yaml, ApplyOpts{})
+	require.NoError(t, err)
+
+	orig, err := client.CoreV1().ConfigMaps(ns).Get(context.TODO(), "config-one", metav1.GetOptions{})
+	require.NoError(t, err)
+
+	yaml = strings.ReplaceAll(yaml, "foo1", "foo2")
+
+	_, err = r.Apply(yaml, ApplyOpts{})
+	require.Error(t, err)
+
+	_, err = r.Apply(yaml, ApplyOpts{RecreateOnImmutableError: true})
+	require.NoError(t, err)
+
+	recreated, err := client.CoreV1().ConfigMaps(ns).Get(context.TODO(), "config-one", metav1.GetOptions{})
+	require.NoError(t, err)
+	require.Equal(t, "foo2", recreated.Data["foo"])
+	require.NotEqual(t, orig.UID, recreated.UID)
+}
+
+func TestPruneExclusions(t *testing.T) {
+	const ns = "goply-prune-exclusions-test"
+	r, client, cleanup := basicSetup(t, ns)
+	defer cleanup()
+
+	origYaml := dedent.Dedent(fmt.Sprintf(`
+		---
+		apiVersion: v1
+		kind: Namespace
+		metadata:
+		  name: %v
+		---
+		apiVersion: v1
+		kind: ConfigMap
+		metadata:
+		  name: config-one
+		  namespace: %v
+		data:
+		  foo: foo1
+	`, ns, ns))[1:]
+	origResult, err := r.Apply(origYaml, ApplyOpts{})
+	require.NoError(t, err)
+
+	newYaml := dedent.Dedent(fmt.Sprintf(`
+		---
+		apiVersion: v1
+		kind: Namespace
+		metadata:
+		  name: %v
+	`, ns))[1:]
+	configOne := object.ObjMetadata{Namespace: ns, Name: "config-one", GroupKind: schema.GroupKind{Kind: "ConfigMap"}}
+	newResult, err := r.Reconcile(newYaml, ApplyOpts{PruneExclusions: []object.ObjMetadata{configOne}}, &origResult.Inventory)
+	require.NoError(t, err)
+
+	// Not pruned, and still tracked
+	_, err = client.CoreV1().ConfigMaps(ns).Get(context.TODO(), "config-one", metav1.GetOptions{})
+	require.NoError(t, err)
+	require.True(t, newResult.Inventory.Contains(configOne))
+
+	// Lifting the exclusion prunes it
+	_, err = r.Reconcile(newYaml, ApplyOpts{}, &newResult.Inventory)
+	require.NoError(t, err)
+	_, err = client.CoreV1().ConfigMaps(ns).Get(context.TODO(), "config-one", metav1.GetOptions{})
+	require.True(t, k8serr.IsNotFound(err))
+}
+
+func TestPreflight(t *testing.T) {
+	const ns = "goply-preflight-test"
+	r, _, cleanup := basicSetup(t, ns)
+	defer cleanup()
+
+	require.NoError(t, r.Preflight(context.TODO(), PreflightOpts{MinReadyNodes: 1, RequiredNamespaces: []string{"kube-system"}}))
+
+	err := r.Preflight(context.TODO(), PreflightOpts{MinReadyNodes: 1000})
+	require.ErrorIs(t, err, ErrPreflightFailedError)
+
+	err = r.Preflight(context.TODO(), PreflightOpts{RequiredNamespaces: []string{ns}})
+	require.ErrorIs(t, err, ErrPreflightFailedError)
+}
+
+func TestCreateOnly(t *testing.T) {
+	const ns = "goply-create-only-test"
+	r, client, cleanup := basicSetup(t, ns)
+	defer cleanup()
+
+	origYaml := dedent.Dedent(fmt.Sprintf(`
+		---
+		apiVersion: v1
+		kind: Namespace
+		metadata:
+		  name: %v
+		---
+		apiVersion: v1
+		kind: ConfigMap
+		metadata:
+		  name: config-one
+		  namespace: %v
+		data:
+		  foo: foo1
+	`, ns, ns))[1:]
+	_, err := r.Apply(origYaml, ApplyOpts{})
+	require.NoError(t, err)
+
+	newYaml := strings.ReplaceAll(origYaml, "foo1", "foo2") + dedent.Dedent(fmt.Sprintf(`
+		---
+		apiVersion: v1
+		kind: ConfigMap
+		metadata:
+		  name: config-two
+		  namespace: %v
+		data:
+		  bar: bar1
+	`, ns))[1:]
+	result, err := r.Apply(newYaml, ApplyOpts{CreateOnly: true})
+	require.NoError(t, err)
+
+	// Existing objects are untouched, new ones are created
+	cm, err := client.CoreV1().ConfigMaps(ns).Get(context.TODO(), "config-one", metav1.GetOptions{})
+	require.NoError(t, err)
+	require.Equal(t, "foo1", cm.Data["foo"])
+	_, err = client.CoreV1().ConfigMaps(ns).Get(context.TODO(), "config-two", metav1.GetOptions{})
+	require.NoError(t, err)
+
+	skipped := lo.Map(result.Skipped, func(s SkippedItem, _ int) string { return s.Name })
+	sort.Strings(skipped)
+	require.Equal(t, []string{"config-one", ns}, skipped)
+	require.Equal(t, 3, len(result.Inventory.Items))
+}
+
+func TestReconcileID(t *testing.T) {
+	const ns = "goply-reconcile-id-test"
+	r, client, cleanup := basicSetup(t, ns)
+	defer cleanup()
+
+	yaml := dedent.Dedent(fmt.Sprintf(`
+		---
+		apiVersion: v1
+		kind: Namespace
+		metadata:
+		  name: %v
+		---
+		apiVersion: v1
+		kind: ConfigMap
+		metadata:
+		  name: config-one
+		  namespace: %v
+		data:
+		  foo: foo1
+	`, ns, ns))[1:]
+
+	result, err := r.Apply(yaml, ApplyOpts{ReconcileID: "run-one", StampReconcileID: true})
+	require.NoError(t, err)
+	require.Equal(t, "run-one", result.ReconcileID)
+
+	cm, err := client.CoreV1().ConfigMaps(ns).Get(context.TODO(), "config-one", metav1.GetOptions{})
+	require.NoError(t, err)
+	require.Equal(t, "run-one", cm.Annotations[ReconcileIDAnnotation])
+
+	// Generated when not supplied
+	result, err = r.Apply(yaml, ApplyOpts{})
+	require.NoError(t, err)
+	require.NotEmpty(t, result.ReconcileID)
+	require.NotEqual(t, "run-one", result.ReconcileID)
+}
+
+func TestPatchStrategies(t *testing.T) {
+	const ns = "goply-patch-strategies-test"
+	r, client, cleanup := basicSetup(t, ns)
+	defer cleanup()
+
+	yaml := dedent.Dedent(fmt.Sprintf(`
+		---
+		apiVersion: v1
+		kind: Namespace
+		metadata:
+		  name: %v
+		---
+		apiVersion: v1
+		kind: ConfigMap
+		metadata:
+		  name: config-one
+		  namespace: %v
+		data:
+		  foo: foo1
+	`, ns, ns))[1:]
+	opts := ApplyOpts{
+		PatchStrategies: map[schema.GroupKind]types.PatchType{
+			{Kind: "ConfigMap"}: types.MergePatchType,
+		},
+	}
+
+	result, err := r.Apply(yaml, opts)
+	require.NoError(t, err)
+	actions := lo.SliceToMap(result.Changes, func(c Change) (string, Action) { return c.Name, c.Action })
+	require.Equal(t, ActionCreated, actions["config-one"])
+
+	result, err = r.Apply(strings.ReplaceAll(yaml, "foo1", "foo2"), opts)
+	require.NoError(t, err)
+	actions = lo.SliceToMap(result.Changes, func(c Change) (string, Action) { return c.Name, c.Action })
+	require.Equal(t, ActionConfigured, actions["config-one"])
+
+	cm, err := client.CoreV1().ConfigMaps(ns).Get(context.TODO(), "config-one", metav1.GetOptions{})
+	require.NoError(t, err)
+	require.Equal(t, "foo2", cm.Data["foo"])
+	// Patched, not applied, so goply holds no apply ownership
+	require.False(t, lo.ContainsBy(cm.ManagedFields, func(m metav1.ManagedFieldsEntry) bool {
+		return m.Operation == metav1.ManagedFieldsOperationApply
+	}))
+}
+
+func TestPlanPruneSkipsExternallyManaged(t *testing.T) {
+	r := offlineReconciler(t)
+
+	previous := Inventory{Items: []InventoryItem{
+		{ObjMetadata: object.ObjMetadata{Namespace: "default", Name: "config-one", GroupKind: schema.GroupKind{Kind: "ConfigMap"}}, GroupVersion: "v1"},
+		{ObjMetadata: object.ObjMetadata{Namespace: "default", Name: "config-two", GroupKind: schema.GroupKind{Kind: "ConfigMap"}}, GroupVersion: "v1", ExternallyManaged: true},
+	}}
+
+	// Nothing to diff, so the cluster is never contacted
+	plan, err := r.Plan(context.Background(), "", &previous)
+	require.NoError(t, err)
+	require.Equal(t, []string{"config-one"}, lo.Map(plan.Prune, func(item InventoryItem, _ int) string { return item.Name }))
+}
+
+func TestPlan(t *testing.T) {
+	const ns = "goply-plan-test"
+	r, _, cleanup := basicSetup(t, ns)
+	defer cleanup()
+
+	origYaml := dedent.Dedent(fmt.Sprintf(`
+		---
+		apiVersion: v1
+		kind: Namespace
+		metadata:
+		  name: %v
+		---
+		apiVersion: v1
+		kind: ConfigMap
+		metadata:
+		  name: config-one
+		  namespace: %v
+		data:
+		  foo: foo1
+		---
+		apiVersion: v1
+		kind: ConfigMap
+		metadata:
+		  name: config-two
+		  namespace: %v
+		data:
+		  bar: bar1
+	`, ns, ns, ns))[1:]
+
+	// Nothing exists yet, so everything is a create, including objects in the not yet created namespace
+	plan, err := r.Plan(context.Background(), origYaml, nil)
+	require.NoError(t, err)
+	require.Equal(t, 3, len(plan.Create))
+
+	result, err := r.Apply(origYaml, ApplyOpts{})
+	require.NoError(t, err)
+
+	newYaml := dedent.Dedent(fmt.Sprintf(`
+		---
+		apiVersion: v1
+		kind: Namespace
+		metadata:
+		  name: %v
+		---
+		apiVersion: v1
+		kind: ConfigMap
+		metadata:
+		  name: config-one
+		  namespace: %v
+		data:
+		  foo: foo2
+		---
+		apiVersion: v1
+		kind: ConfigMap
+		metadata:
+		  name: config-three
+		  namespace: %v
+		data:
+		  baz: baz1
+	`, ns, ns, ns))[1:]
+
+	plan, err = r.Plan(context.Background(), newYaml, &result.Inventory)
+	require.NoError(t, err)
+
+	names := func(items []InventoryItem) []string {
+		return lo.Map(items, func(i InventoryItem, _ int) string { return i.Name })
+	}
+	require.Equal(t, []string{"config-three"}, names(plan.Create))
+	require.Equal(t, []string{"config-one"}, lo.Map(plan.Update, func(u PlannedUpdate, _ int) string { return u.Name }))
+	require.Equal(t, []string{ns}, names(plan.Unchanged))
+	require.Equal(t, []string{"config-two"}, names(plan.Prune))
+}
+
+func TestPruneFromClusterCancelled(t *testing.T) {
+	r, err := NewReconciler(&ReconcilerConfig{
+		Kubeconfig: offlineKubeconfig,
+		WrapTransport: func(http.RoundTripper) http.RoundTripper {
+			// Answer discovery, then hang on listing what's in the cluster
+			return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+				if body, ok := coreDiscovery[req.URL.Path]; ok {
+					return jsonResponse(req, http.StatusOK, body), nil
+				}
+				<-req.Context().Done()
+				return nil, req.Context().Err()
+			})
+		},
+	})
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(100*time.Millisecond, cancel)
+
+	result, err := r.ReconcileContext(ctx, "", ApplyOpts{
+		AllowEmpty:       true,
+		PruneFromCluster: true,
+		Owner:            &Owner{Name: "goply-test", Namespace: "default"},
+	}, nil)
+	var cancelled *CancelledError
+	require.ErrorAs(t, err, &cancelled)
+	require.Equal(t, StagePrune, cancelled.Stage)
+	require.NotEmpty(t, result.ReconcileID)
+}
+
+func TestPruneFromCluster(t *testing.T) {
+	const ns = "goply-prune-from-cluster-test"
+	r, client, cleanup := basicSetup(t, ns)
+	defer cleanup()
+
+	origYaml := dedent.Dedent(fmt.Sprintf(`
+		---
+		apiVersion: v1
+		kind: Namespace
+		metadata:
+		  name: %v
+		---
+		apiVersion: v1
+		kind: ConfigMap
+		metadata:
+		  name: config-one
+		  namespace: %v
+		---
+		apiVersion: v1
+		kind: ConfigMap
+		metadata:
+		  name: config-two
+		  namespace: %v
+	`, ns, ns, ns))[1:]
+	owner := &Owner{Name: "prune-from-cluster", Namespace: ns}
+
+	_, err := r.Apply(origYaml, ApplyOpts{Owner: owner})
+	require.NoError(t, err)
+
+	inv, err := r.LiveInventory(context.Background(), *owner)
+	require.NoError(t, err)
+	require.Equal(t, 3, len(inv.Items))
+
+	// No stored inventory, config-two is still found and pruned via its labels
+	newYaml := strings.Split(origYaml, "---\napiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: config-two")[0]
+	result, err := r.Reconcile(newYaml, ApplyOpts{Owner: owner, PruneFromCluster: true}, nil)
+	require.NoError(t, err)
+	require.Equal(t, []string{"config-two"}, lo.Map(result.Pruned, func(i InventoryItem, _ int) string { return i.Name }))
+
+	_, err = client.CoreV1().ConfigMaps(ns).Get(context.TODO(), "config-two", metav1.GetOptions{})
+	require.True(t, k8serr.IsNotFound(err))
+}
+
+func TestDisableStaging(t *testing.T) {
+	const ns = "goply-disable-staging-test"
+	r, _, cleanup := basicSetup(t, ns)
+	defer cleanup()
+
+	yaml := dedent.Dedent(fmt.Sprintf(`
+		---
+		apiVersion: v1
+		kind: Namespace
+		metadata:
+		  name: %v
+		---
+		apiVersion: v1
+		kind: ServiceAccount
+		metadata:
+		  name: sa-one
+		  namespace: %v
+		---
+		apiVersion: v1
+		kind: ConfigMap
+		metadata:
+		  name: config-one
+		  namespace: %v
+	`, ns, ns, ns))[1:]
+
+	result, err := r.Apply(yaml, ApplyOpts{DisableStaging: true})
+	require.NoError(t, err)
+	// The resource manager would have put the ConfigMap ahead of the ServiceAccount
+	require.Equal(t, []string{ns, "sa-one", "config-one"}, lo.Map(result.Changes, func(c Change, _ int) string { return c.Name }))
+}
+
+func TestInterStageDelay(t *testing.T) {
+	const ns = "goply-inter-stage-delay-test"
+	r, client, cleanup := basicSetup(t, ns)
+	defer cleanup()
+
+	yaml := dedent.Dedent(fmt.Sprintf(`
+		---
+		apiVersion: v1
+		kind: Namespace
+		metadata:
+		  name: %v
+		---
+		apiVersion: v1
+		kind: ConfigMap
+		metadata:
+		  name: config-one
+		  namespace: %v
+	`, ns, ns))[1:]
+
+	start := time.Now()
+	_, err := r.Apply(yaml, ApplyOpts{InterStageDelay: 2 * time.Second})
+	require.NoError(t, err)
+	require.GreaterOrEqual(t, time.Since(start), 2*time.Second)
+
+	// Cancelling during the delay stops it short, before stage two is applied
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(time.Second, cancel)
+	start = time.Now()
+	_, err = r.ReconcileContext(ctx, strings.ReplaceAll(yaml, "config-one", "config-two"), ApplyOpts{InterStageDelay: time.Minute}, nil)
+	var cancelled *CancelledError
+	require.ErrorAs(t, err, &cancelled)
+	require.Equal(t, StageOne, cancelled.Stage)
+	require.Less(t, time.Since(start), 30*time.Second)
+	_, err = client.CoreV1().ConfigMaps(ns).Get(context.Background(), "config-two", metav1.GetOptions{})
+	require.True(t, k8serr.IsNotFound(err))
+}
+
+func TestPruneOrphanedNamespacesNeedsPrevious(t *testing.T) {
+	r := offlineReconciler(t)
+
+	// Without a previous inventory naming any namespaces there's nothing to consider, so the cluster isn't even listed
+	deleted, err := r.PruneOrphanedNamespaces(context.Background(), Inventory{}, Inventory{}, nil, DeleteOpts{})
+	require.NoError(t, err)
+	require.Empty(t, deleted.Deleted)
+}
+
+func TestReconcileKeepsNonEmptyNamespace(t *testing.T) {
+	var mu sync.Mutex
+	deletes := []string{}
+	r, err := NewReconciler(&ReconcilerConfig{
+		Kubeconfig: offlineKubeconfig,
+		WrapTransport: func(http.RoundTripper) http.RoundTripper {
+			// The namespace goply applied still holds someone else's ConfigMap
+			responses := map[string]string{
+				"/api/v1/namespaces": `{"kind":"NamespaceList","apiVersion":"v1","metadata":{},"items":[` +
+					`{"metadata":{"name":"goply-test","managedFields":[{"manager":"goply","operation":"Apply"}]},"status":{"phase":"Active"}}]}`,
+				"/api/v1/namespaces/goply-test/configmaps": `{"kind":"PartialObjectMetadataList","apiVersion":"meta.k8s.io/v1","metadata":{},"items":[` +
+					`{"kind":"PartialObjectMetadata","apiVersion":"meta.k8s.io/v1","metadata":{"name":"someone-elses","namespace":"goply-test"}}]}`,
+			}
+			return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+				if body, ok := coreDiscovery[req.URL.Path]; ok {
+					return jsonResponse(req, http.StatusOK, body), nil
+				}
+				if req.Method == http.MethodDelete {
+					mu.Lock()
+					deletes = append(deletes, req.URL.Path)
+					mu.Unlock()
+					return jsonResponse(req, http.StatusOK, `{"kind":"Status","apiVersion":"v1","status":"Success"}`), nil
+				}
+				if body, ok := responses[req.URL.Path]; ok && req.Method == http.MethodGet {
+					return jsonResponse(req, http.StatusOK, body), nil
+				}
+				return nil, fmt.Errorf("unexpected request %v %v", req.Method, req.URL.Path)
+			})
+		},
+	})
+	require.NoError(t, err)
+
+	ns := &unstructured.Unstructured{}
+	ns.SetAPIVersion("v1")
+	ns.SetKind("Namespace")
+	ns.SetName("goply-test")
+	previous := Inventory{Items: []InventoryItem{toInventoryItem(ns)}}
+
+	result, err := r.Reconcile("", ApplyOpts{AllowEmpty: true, PruneOrphanedNamespaces: true}, &previous)
+	require.NoError(t, err)
+	require.Empty(t, deletes)
+	require.Empty(t, result.Pruned)
+	// Still tracked, so it's pruned once it is empty
+	require.Equal(t, previous.Items, result.Inventory.Items)
+}
+
+func TestPruneOrphanedNamespaces(t *testing.T) {
+	const ns = "goply-orphaned-namespaces-test"
+	const busyNs = "goply-orphaned-namespaces-busy-test"
+	const otherNs = "goply-orphaned-namespaces-other-test"
+	r, client, cleanup := basicSetup(t, ns)
+	defer cleanup()
+	_, _, cleanupBusy := basicSetup(t, busyNs)
+	defer cleanupBusy()
+	_, _, cleanupOther := basicSetup(t, otherNs)
+	defer cleanupOther()
+
+	owner := &Owner{Name: "orphaned-namespaces", Namespace: ns}
+	previous := Inventory{}
+	for _, name := range []string{ns, busyNs, otherNs} {
+		result, err := r.Apply(dedent.Dedent(fmt.Sprintf(`
+			---
+			apiVersion: v1
+			kind: Namespace
+			metadata:
+			  name: %v
+		`, name))[1:], ApplyOpts{Owner: owner})
+		require.NoError(t, err)
+		// Empty and goply's, but not part of the previous inventory, so it's someone else's to prune
+		if name != otherNs {
+			previous = previous.union(result.Inventory)
+		}
+	}
+
+	// Something managed by another tool keeps the namespace around
+	_, err := client.CoreV1().ConfigMaps(busyNs).Create(context.TODO(), &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "not-goply"},
+	}, metav1.CreateOptions{})
+	require.NoError(t, err)
+
+	deleted, err := r.PruneOrphanedNamespaces(context.Background(), previous, Inventory{}, owner, DeleteOpts{})
+	require.NoError(t, err)
+	require.Equal(t, []string{ns}, lo.Map(deleted.Deleted, func(i InventoryItem, _ int) string { return i.Name }))
+
+	_, err = client.CoreV1().Namespaces().Get(context.TODO(), busyNs, metav1.GetOptions{})
+	require.NoError(t, err)
+	_, err = client.CoreV1().Namespaces().Get(context.TODO(), otherNs, metav1.GetOptions{})
+	require.NoError(t, err)
+}
+
+func TestManagementLabels(t *testing.T) {
+	const ns = "goply-management-labels-test"
+	r, _, cleanup := basicSetup(t, ns)
+	defer cleanup()
+
+	yaml := dedent.Dedent(fmt.Sprintf(`
+		---
+		apiVersion: v1
+		kind: Namespace
+		metadata:
+		  name: %v
+		---
+		apiVersion: v1
+		kind: ConfigMap
+		metadata:
+		  name: config-one
+		  namespace: %v
+		  labels:
+		    app: config
+	`, ns, ns))[1:]
+	labels := map[string]string{"goply.io/managed-by-set": ns}
+
+	_, err := r.Apply(yaml, ApplyOpts{ManagementLabels: labels})
+	require.NoError(t, err)
+	// Re-applying is a no-op, not a conflict
+	result, err := r.Apply(yaml, ApplyOpts{ManagementLabels: labels, ConflictPolicy: ConflictPolicyFail})
+	require.NoError(t, err)
+	require.True(t, lo.EveryBy(result.Changes, func(c Change) bool { return c.Action == ActionUnchanged }))
+
+	inv, err := r.ListOwned(context.Background(), labels)
+	require.NoError(t, err)
+	names := lo.Map(inv.Items, func(i InventoryItem, _ int) string { return i.Name })
+	sort.Strings(names)
+	require.Equal(t, []string{"config-one", ns}, names)
+}
+
+func TestApplyObject(t *testing.T) {
+	const ns = "goply-apply-object-test"
+	r, _, cleanup := basicSetup(t, ns)
+	defer cleanup()
+
+	_, err := r.Apply(dedent.Dedent(fmt.Sprintf(`
+		---
+		apiVersion: v1
+		kind: Namespace
+		metadata:
+		  name: %v
+	`, ns))[1:], ApplyOpts{})
+	require.NoError(t, err)
+
+	obj := &unstructured.Unstructured{}
+	obj.SetAPIVersion("v1")
+	obj.SetKind("ConfigMap")
+	obj.SetNamespace(ns)
+	obj.SetName("config-one")
+	require.NoError(t, unstructured.SetNestedField(obj.Object, "foo1", "data", "foo"))
+
+	live, action, err := r.ApplyObject(context.Background(), obj, ApplyOpts{})
+	require.NoError(t, err)
+	require.Equal(t, ActionCreated, action)
+	require.NotEmpty(t, live.GetUID())
+
+	live, action, err = r.ApplyObject(context.Background(), obj, ApplyOpts{})
+	require.NoError(t, err)
+	require.Equal(t, ActionUnchanged, action)
+	foo, _, _ := unstructured.NestedString(live.Object, "data", "foo")
+	require.Equal(t, "foo1", foo)
+}
+
+func TestDriftOnly(t *testing.T) {
+	const ns = "goply-drift-only-test"
+	r, _, cleanup := basicSetup(t, ns)
+	defer cleanup()
+
+	yaml := dedent.Dedent(fmt.Sprintf(`
+		---
+		apiVersion: v1
+		kind: Namespace
+		metadata:
+		  name: %v
+		---
+		apiVersion: v1
+		kind: ConfigMap
+		metadata:
+		  name: config-one
+		  namespace: %v
+		data:
+		  foo: foo1
+		---
+		apiVersion: v1
+		kind: ConfigMap
+		metadata:
+		  name: config-two
+		  namespace: %v
+		data:
+		  bar: bar1
+	`, ns, ns, ns))[1:]
+	_, err := r.Apply(yaml, ApplyOpts{})
+	require.NoError(t, err)
+
+	result, err := r.Apply(strings.ReplaceAll(yaml, "foo1", "foo2"), ApplyOpts{DriftOnly: true})
+	require.NoError(t, err)
+
+	require.Equal(t, []Change{{
+		InventoryItem: InventoryItem{
+			ObjMetadata:  object.ObjMetadata{Namespace: ns, Name: "config-one", GroupKind: schema.GroupKind{Kind: "ConfigMap"}},
+			GroupVersion: "v1",
+		},
+		Action: ActionConfigured,
+	}}, result.Changes)
+	require.Equal(t, []string{ns, "config-two"}, lo.Map(result.Skipped, func(s SkippedItem, _ int) string { return s.Name }))
+	require.True(t, lo.EveryBy(result.Skipped, func(s SkippedItem) bool { return s.Reason == SkipReasonUnchanged }))
+	require.Equal(t, 3, len(result.Inventory.Items))
+}
+
+func TestPause(t *testing.T) {
+	const ns = "goply-pause-test"
+	r, client, cleanup := basicSetup(t, ns)
+	defer cleanup()
+
+	yaml := func(value string, annotations string) string {
+		return dedent.Dedent(fmt.Sprintf(`
+			---
+			apiVersion: v1
+			kind: Namespace
+			metadata:
+			  name: %v
+			---
+			apiVersion: v1
+			kind: ConfigMap
+			metadata:
+			  name: config-one
+			  namespace: %v
+			  annotations: {%v}
+			data:
+			  foo: %v
+		`, ns, ns, annotations, value))[1:]
+	}
+
+	result, err := r.Apply(yaml("foo1", ""), ApplyOpts{})
+	require.NoError(t, err)
+
+	prev := result.Inventory
+	result, err = r.Reconcile(yaml("foo2", `goply.io/pause: "true"`), ApplyOpts{}, &prev)
+	require.NoError(t, err)
+
+	// The paused object is neither applied nor pruned
+	cm, err := client.CoreV1().ConfigMaps(ns).Get(context.TODO(), "config-one", metav1.GetOptions{})
+	require.NoError(t, err)
+	require.Equal(t, "foo1", cm.Data["foo"])
+	require.Equal(t, []string{"config-one"}, lo.Map(result.Skipped, func(s SkippedItem, _ int) string { return s.Name }))
+	require.Equal(t, SkipReasonPaused, result.Skipped[0].Reason)
+	require.Empty(t, result.Pruned)
+	require.Equal(t, 2, len(result.Inventory.Items))
+}
+
+func TestPreviewFromLastApplied(t *testing.T) {
+	const ns = "goply-last-applied-test"
+	r, _, cleanup := basicSetup(t, ns)
+	defer cleanup()
+
+	yaml := dedent.Dedent(fmt.Sprintf(`
+		---
+		apiVersion: v1
+		kind: Namespace
+		metadata:
+		  name: %v
+		---
+		apiVersion: v1
+		kind: ConfigMap
+		metadata:
+		  name: config-one
+		  namespace: %v
+		data:
+		  foo: foo1
+		---
+		apiVersion: v1
+		kind: ConfigMap
+		metadata:
+		  name: config-two
+		  namespace: %v
+		data:
+		  bar: bar1
+	`, ns, ns, ns))[1:]
+	result, err := r.Apply(yaml, ApplyOpts{RecordLastApplied: true, Owner: &Owner{Name: "app", Namespace: ns}})
+	require.NoError(t, err)
+
+	newYaml := strings.ReplaceAll(yaml, "foo1", "foo2")
+	newYaml = newYaml[:strings.LastIndex(newYaml, "---")] + dedent.Dedent(fmt.Sprintf(`
+		---
+		apiVersion: v1
+		kind: ConfigMap
+		metadata:
+		  name: config-three
+		  namespace: %v
+	`, ns))[1:]
+	preview, err := r.PreviewFromLastApplied(context.TODO(), newYaml, &result.Inventory)
+	require.NoError(t, err)
+
+	names := func(items []InventoryItem) []string {
+		return lo.Map(items, func(i InventoryItem, _ int) string { return i.Name })
+	}
+	require.Equal(t, []string{"config-three"}, names(preview.Create))
+	require.Equal(t, []string{"config-one"}, lo.Map(preview.Update, func(u PreviewUpdate, _ int) string { return u.Name }))
+	require.Equal(t, "foo2", preview.Update[0].Desired.Object["data"].(map[string]any)["foo"])
+	require.Equal(t, []string{ns}, names(preview.Unchanged))
+	require.Equal(t, []string{"config-two"}, names(preview.Prune))
+	require.Empty(t, preview.Untracked)
+}
+
+func TestRollback(t *testing.T) {
+	const ns = "goply-rollback-test"
+	r, client, cleanup := basicSetup(t, ns)
+	defer cleanup()
+
+	origYaml := dedent.Dedent(fmt.Sprintf(`
+		---
+		apiVersion: v1
+		kind: Namespace
+		metadata:
+		  name: %v
+		---
+		apiVersion: v1
+		kind: ConfigMap
+		metadata:
+		  name: config-one
+		  namespace: %v
+		data:
+		  foo: foo1
+	`, ns, ns))[1:]
+	result, err := r.Apply(origYaml, ApplyOpts{})
+	require.NoError(t, err)
+
+	// The deployment never becomes ready, failing the reconcile in the wait
+	newYaml := strings.ReplaceAll(origYaml, "foo1", "foo2") + dedent.Dedent(fmt.Sprintf(`
+		---
+		apiVersion: apps/v1
+		kind: Deployment
+		metadata:
+		  name: deploy-one
+		  namespace: %v
+		spec:
+		  selector:
+		    matchLabels:
+		      app: deploy-one
+		  template:
+		    metadata:
+		      labels:
+		        app: deploy-one
+		    spec:
+		      containers:
+		      - name: app
+		        image: goply.invalid/does-not-exist:latest
+	`, ns))[1:]
+	_, err = r.Reconcile(newYaml, ApplyOpts{Rollback: true, WaitTimeout: ptr(5 * time.Second)}, &result.Inventory)
+	var rollbackErr *RollbackError
+	require.ErrorAs(t, err, &rollbackErr)
+	var timeoutErr *WaitTimeoutError
+	require.ErrorAs(t, err, &timeoutErr)
+	require.Empty(t, rollbackErr.Failed)
+
+	require.Equal(t, []string{"config-one"}, lo.Map(rollbackErr.Restored, func(i InventoryItem, _ int) string { return i.Name }))
+	require.Equal(t, []string{"deploy-one"}, lo.Map(rollbackErr.Deleted, func(i InventoryItem, _ int) string { return i.Name }))
+
+	cm, err := client.CoreV1().ConfigMaps(ns).Get(context.TODO(), "config-one", metav1.GetOptions{})
+	require.NoError(t, err)
+	require.Equal(t, "foo1", cm.Data["foo"])
+}
+
+func TestPreserveMetadata(t *testing.T) {
+	const ns = "goply-preserve-metadata-test"
+	r, client, cleanup := basicSetup(t, ns)
+	defer cleanup()
+
+	yaml := func(labels string) string {
+		return dedent.Dedent(fmt.Sprintf(`
+			---
+			apiVersion: v1
+			kind: Namespace
+			metadata:
+			  name: %v
+			---
+			apiVersion: v1
+			kind: ConfigMap
+			metadata:
+			  name: config-one
+			  namespace: %v
+			  labels: {%v}
+			data:
+			  foo: foo1
+		`, ns, ns, labels))[1:]
+	}
+
+	_, err := r.Apply(yaml("app: goply, shared: present"), ApplyOpts{})
+	require.NoError(t, err)
+
+	// Another tool adds metadata of its own, under a different field manager
+	patch := []byte(`{"metadata":{"labels":{"other":"label"},"annotations":{"other/annotation":"value"}}}`)
+	_, err = client.CoreV1().ConfigMaps(ns).Patch(context.TODO(), "config-one", types.MergePatchType, patch, metav1.PatchOptions{FieldManager: "other-tool"})
+	require.NoError(t, err)
+
+	// shared is no longer in the manifest, but was owned by goply alone, so it would normally be removed
+	_, err = r.Apply(yaml("app: goply"), ApplyOpts{PreserveMetadata: true})
+	require.NoError(t, err)
+
+	cm, err := client.CoreV1().ConfigMaps(ns).Get(context.TODO(), "config-one", metav1.GetOptions{})
+	require.NoError(t, err)
+	require.Equal(t, "goply", cm.Labels["app"])
+	require.Equal(t, "present", cm.Labels["shared"])
+	require.Equal(t, "label", cm.Labels["other"])
+	require.Equal(t, "value", cm.Annotations["other/annotation"])
+
+	// Without it, goply drops what only it owned, and leaves the other tool's metadata alone
+	_, err = r.Apply(yaml("app: goply"), ApplyOpts{})
+	require.NoError(t, err)
+	cm, err = client.CoreV1().ConfigMaps(ns).Get(context.TODO(), "config-one", metav1.GetOptions{})
+	require.NoError(t, err)
+	require.Equal(t, "label", cm.Labels["other"])
+	require.Equal(t, "value", cm.Annotations["other/annotation"])
+}
+
+func TestRelinquishAfterApply(t *testing.T) {
+	const ns = "goply-relinquish-test"
+	r, client, cleanup := basicSetup(t, ns)
+	defer cleanup()
+
+	yaml := dedent.Dedent(fmt.Sprintf(`
+		---
+		apiVersion: v1
+		kind: Namespace
+		metadata:
+		  name: %v
+		---
+		apiVersion: v1
+		kind: ConfigMap
+		metadata:
+		  name: config-one
+		  namespace: %v
+		data:
+		  foo: foo1
+	`, ns, ns))[1:]
+	result, err := r.Apply(yaml, ApplyOpts{
+		RelinquishAfterApply: func(obj *unstructured.Unstructured) bool { return obj.GetKind() == "ConfigMap" },
+	})
+	require.NoError(t, err)
+	require.Equal(t, []string{"config-one"}, lo.Map(result.HandedOff, func(i InventoryItem, _ int) string { return i.Name }))
+	item, ok := result.Inventory.Get(result.HandedOff[0].ObjMetadata)
+	require.True(t, ok)
+	require.True(t, item.ExternallyManaged)
+
+	cm, err := client.CoreV1().ConfigMaps(ns).Get(context.TODO(), "config-one", metav1.GetOptions{})
+	require.NoError(t, err)
+	require.Equal(t, "foo1", cm.Data["foo"])
+	require.False(t, lo.SomeBy(cm.ManagedFields, func(e metav1.ManagedFieldsEntry) bool { return e.Manager == fieldManager }))
+
+	// Another manager can now take the fields over without forcing
+	patch := []byte(fmt.Sprintf(`{"apiVersion":"v1","kind":"ConfigMap","metadata":{"name":"config-one","namespace":"%v"},"data":{"foo":"foo2"}}`, ns))
+	force := false
+	_, err = client.CoreV1().ConfigMaps(ns).Patch(context.TODO(), "config-one", types.ApplyPatchType, patch, metav1.PatchOptions{FieldManager: "other-tool", Force: &force})
+	require.NoError(t, err)
+}
+
+func TestWaitForPVCBound(t *testing.T) {
+	const ns = "goply-pvc-bound-test"
+	r, client, cleanup := basicSetup(t, ns)
+	defer cleanup()
+
+	// Binds on first consumer, like kind's default local-path class
+	storageClass := dedent.Dedent(`
+		---
+		apiVersion: storage.k8s.io/v1
+		kind: StorageClass
+		metadata:
+		  name: goply-wait-for-first-consumer
+		provisioner: rancher.io/local-path
+		volumeBindingMode: WaitForFirstConsumer
+	`)[1:]
+	_, err := r.Apply(storageClass, ApplyOpts{})
+	require.NoError(t, err)
+	defer func() {
+		_ = client.StorageV1().StorageClasses().Delete(context.TODO(), "goply-wait-for-first-consumer", metav1.DeleteOptions{})
+	}()
+
+	pvcYaml := dedent.Dedent(fmt.Sprintf(`
+		---
+		apiVersion: v1
+		kind: Namespace
+		metadata:
+		  name: %v
+		---
+		apiVersion: v1
+		kind: PersistentVolumeClaim
+		metadata:
+		  name: pvc-one
+		  namespace: %v
+		spec:
+		  storageClassName: goply-wait-for-first-consumer
+		  accessModes: [ReadWriteOnce]
+		  resources:
+		    requests:
+		      storage: 1Mi
+	`, ns, ns))[1:]
+
+	// Nothing consumes it, so it stays Pending and the wait can't succeed
+	_, err = r.Apply(pvcYaml, ApplyOpts{WaitTimeout: ptr(5 * time.Second)})
+	var timeoutErr *WaitTimeoutError
+	require.ErrorAs(t, err, &timeoutErr)
+	require.Equal(t, "pvc-one", timeoutErr.Objects[0].Name)
+	require.Contains(t, timeoutErr.Objects[0].Message, "PVC is not Bound")
+
+	podYaml := pvcYaml + dedent.Dedent(fmt.Sprintf(`
+		---
+		apiVersion: v1
+		kind: Pod
+		metadata:
+		  name: pod-one
+		  namespace: %v
+		spec:
+		  containers:
+		  - name: app
+		    image: busybox
+		    command: [sleep, "3600"]
+		    volumeMounts:
+		    - name: data
+		      mountPath: /data
+		  volumes:
+		  - name: data
+		    persistentVolumeClaim:
+		      claimName: pvc-one
+	`, ns))[1:]
+	_, err = r.Apply(podYaml, ApplyOpts{})
+	require.NoError(t, err)
+
+	pvc, err := client.CoreV1().PersistentVolumeClaims(ns).Get(context.TODO(), "pvc-one", metav1.GetOptions{})
+	require.NoError(t, err)
+	require.Equal(t, corev1.ClaimBound, pvc.Status.Phase)
+}
+
+func TestAdopt(t *testing.T) {
+	const ns = "goply-adopt-test"
+	r, client, cleanup := basicSetup(t, ns)
+	defer cleanup()
+
+	yaml := dedent.Dedent(fmt.Sprintf(`
+		---
+		apiVersion: v1
+		kind: Namespace
+		metadata:
+		  name: %v
+		---
+		apiVersion: v1
+		kind: ConfigMap
+		metadata:
+		  name: config-one
+		  namespace: %v
+		data:
+		  foo: foo1
+	`, ns, ns))[1:]
+
+	_, err := r.Apply(yaml, ApplyOpts{})
+	require.NoError(t, err)
+
+	// Created by hand, before goply knew about it
+	_, err = client.CoreV1().ConfigMaps(ns).Create(context.TODO(), &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "config-two", Namespace: ns},
+		Data:       map[string]string{"foo": "manual", "bar": "manual"},
+	}, metav1.CreateOptions{FieldManager: "by-hand"})
+	require.NoError(t, err)
+
+	yaml += dedent.Dedent(fmt.Sprintf(`
+		---
+		apiVersion: v1
+		kind: ConfigMap
+		metadata:
+		  name: config-two
+		  namespace: %v
+		data:
+		  foo: foo2
+	`, ns))[1:]
+
+	_, err = r.Apply(yaml, ApplyOpts{ConflictPolicy: ConflictPolicyFail})
+	require.ErrorIs(t, err, ErrConflictNotForcedError)
+
+	result, err := r.Apply(yaml, ApplyOpts{ConflictPolicy: ConflictPolicyFail, Adopt: true})
+	require.NoError(t, err)
+	require.Equal(t, []string{"config-two"}, lo.Map(result.Adopted, func(item InventoryItem, _ int) string { return item.Name }))
+
+	cm, err := client.CoreV1().ConfigMaps(ns).Get(context.TODO(), "config-two", metav1.GetOptions{})
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"foo": "foo2", "bar": "manual"}, cm.Data)
+
+	// Once adopted, it's just another managed object
+	result, err = r.Apply(yaml, ApplyOpts{ConflictPolicy: ConflictPolicyFail, Adopt: true})
+	require.NoError(t, err)
+	require.Empty(t, result.Adopted)
+}
+
+func TestFindUnmanaged(t *testing.T) {
+	const ns = "goply-find-unmanaged-test"
+	r, client, cleanup := basicSetup(t, ns)
+	defer cleanup()
+
+	result, err := r.Apply(dedent.Dedent(fmt.Sprintf(`
+		---
+		apiVersion: v1
+		kind: Namespace
+		metadata:
+		  name: %v
+		---
+		apiVersion: v1
+		kind: ConfigMap
+		metadata:
+		  name: tracked
+		  namespace: %v
+	`, ns, ns))[1:], ApplyOpts{})
+	require.NoError(t, err)
+
+	// Applied by goply, but from some other manifest whose inventory we don't have
+	_, err = r.Apply(dedent.Dedent(fmt.Sprintf(`
+		---
+		apiVersion: v1
+		kind: ConfigMap
+		metadata:
+		  name: applied-elsewhere
+		  namespace: %v
+	`, ns))[1:], ApplyOpts{})
+	require.NoError(t, err)
+
+	_, err = client.CoreV1().ConfigMaps(ns).Create(context.TODO(), &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "by-hand"},
+	}, metav1.CreateOptions{})
+	require.NoError(t, err)
+
+	unmanaged, err := r.FindUnmanaged(context.Background(), ns, result.Inventory, nil)
+	require.NoError(t, err)
+	require.Equal(t, []string{"ConfigMap/by-hand"}, lo.Map(unmanaged, func(i InventoryItem, _ int) string { return i.GroupKind.Kind + "/" + i.Name }))
+}
+
+func TestFindUnmanagedLabels(t *testing.T) {
+	r, err := NewReconciler(&ReconcilerConfig{
+		Kubeconfig: offlineKubeconfig,
+		WrapTransport: func(http.RoundTripper) http.RoundTripper {
+			return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+				if body, ok := coreDiscovery[req.URL.Path]; ok {
+					return jsonResponse(req, http.StatusOK, body), nil
+				}
+				if req.URL.Path == "/api/v1/namespaces/goply-test/configmaps" {
+					return jsonResponse(req, http.StatusOK, `{"kind":"PartialObjectMetadataList","apiVersion":"meta.k8s.io/v1","metadata":{},"items":[`+
+						`{"metadata":{"name":"owned","namespace":"goply-test","labels":{"goply/namespace":"default"}}},`+
+						`{"metadata":{"name":"labelled","namespace":"goply-test","labels":{"team":"one"}}},`+
+						`{"metadata":{"name":"by-hand","namespace":"goply-test","labels":{"team":"two"}}}]}`), nil
+				}
+				return nil, fmt.Errorf("unexpected request %v %v", req.Method, req.URL.Path)
+			})
+		},
+	})
+	require.NoError(t, err)
+
+	unmanaged, err := r.FindUnmanaged(context.Background(), "goply-test", Inventory{}, map[string]string{"team": "one"})
+	require.NoError(t, err)
+	require.Equal(t, []string{"by-hand"}, lo.Map(unmanaged, func(i InventoryItem, _ int) string { return i.Name }))
+}
+
+func TestReconcileEmptyPrunesOnlyWhenAllowed(t *testing.T) {
+	const ns = "goply-reconcile-empty-test"
+	r, client, cleanup := basicSetup(t, ns)
+	defer cleanup()
+
+	result, err := r.Apply(dedent.Dedent(fmt.Sprintf(`
+		---
+		apiVersion: v1
+		kind: Namespace
+		metadata:
+		  name: %v
+		---
+		apiVersion: v1
+		kind: ConfigMap
+		metadata:
+		  name: config-one
+		  namespace: %v
+	`, ns, ns))[1:], ApplyOpts{})
+	require.NoError(t, err)
+	inv := result.Inventory
+
+	_, err = r.Reconcile("\n", ApplyOpts{}, &inv)
+	require.ErrorIs(t, err, ErrEmptyManifestError)
+	_, err = client.CoreV1().ConfigMaps(ns).Get(context.TODO(), "config-one", metav1.GetOptions{})
+	require.NoError(t, err)
+
+	result, err = r.Reconcile("\n", ApplyOpts{AllowEmpty: true}, &inv)
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{ns, "config-one"}, lo.Map(result.Pruned, func(i InventoryItem, _ int) string { return i.Name }))
+	_, err = client.CoreV1().ConfigMaps(ns).Get(context.TODO(), "config-one", metav1.GetOptions{})
+	require.True(t, k8serr.IsNotFound(err))
+}
+
+func TestTrackOwnedFields(t *testing.T) {
+	const ns = "goply-owned-fields-test"
+	r, _, cleanup := basicSetup(t, ns)
+	defer cleanup()
+
+	manifest := func(key string) string {
+		return dedent.Dedent(fmt.Sprintf(`
+			---
+			apiVersion: v1
+			kind: Namespace
+			metadata:
+			  name: %v
+			---
+			apiVersion: v1
+			kind: ConfigMap
+			metadata:
+			  name: config-one
+			  namespace: %v
+			data:
+			  foo: foo1
+			  %v: value
+		`, ns, ns, key))[1:]
+	}
+
+	// Nothing to compare against on the first apply
+	result, err := r.Apply(manifest("bar"), ApplyOpts{TrackOwnedFields: true})
+	require.NoError(t, err)
+	require.Empty(t, result.OwnedFieldsChanges)
+
+	result, err = r.Apply(manifest("bar"), ApplyOpts{TrackOwnedFields: true})
+	require.NoError(t, err)
+	require.Empty(t, result.OwnedFieldsChanges)
+
+	result, err = r.Apply(manifest("baz"), ApplyOpts{TrackOwnedFields: true})
+	require.NoError(t, err)
+	require.Len(t, result.OwnedFieldsChanges, 1)
+	require.Equal(t, "config-one", result.OwnedFieldsChanges[0].Name)
+	require.Equal(t, []string{".data.baz"}, result.OwnedFieldsChanges[0].Gained)
+	require.Equal(t, []string{".data.bar"}, result.OwnedFieldsChanges[0].Lost)
+}
+
+func TestAtomicDryRunFirst(t *testing.T) {
+	const ns = "goply-atomic-dry-run-test"
+	r, client, cleanup := basicSetup(t, ns)
+	defer cleanup()
+
+	yaml := dedent.Dedent(fmt.Sprintf(`
+		---
+		apiVersion: v1
+		kind: Namespace
+		metadata:
+		  name: %v
+		---
+		apiVersion: v1
+		kind: ConfigMap
+		metadata:
+		  name: config-one
+		  namespace: %v
+		data:
+		  foo: foo1
+		---
+		apiVersion: v1
+		kind: Namespace
+		metadata:
+		  name: Not_A_Valid_Name
+	`, ns, ns))[1:]
+
+	_, err := r.Apply(yaml, ApplyOpts{AtomicDryRunFirst: true})
+	require.Error(t, err)
+	var dryRunErr *DryRunError
+	require.ErrorAs(t, err, &dryRunErr)
+	require.Equal(t, "Not_A_Valid_Name", dryRunErr.Object.Name)
+
+	// Not even the valid namespace was created
+	_, err = client.CoreV1().Namespaces().Get(context.TODO(), ns, metav1.GetOptions{})
+	require.True(t, k8serr.IsNotFound(err))
+}
+
+func TestDiffAgainstOwnedRequiresLabels(t *testing.T) {
+	r := offlineReconciler(t)
+	_, err := r.DiffAgainstOwned(context.Background(), "", nil)
+	require.ErrorIs(t, err, ErrNoOwnerError)
+}
+
+func TestDiffAgainstOwned(t *testing.T) {
+	const ns = "goply-diff-against-owned-test"
+	r, _, cleanup := basicSetup(t, ns)
+	defer cleanup()
+
+	yaml := dedent.Dedent(fmt.Sprintf(`
+		---
+		apiVersion: v1
+		kind: Namespace
+		metadata:
+		  name: %v
+		---
+		apiVersion: v1
+		kind: ConfigMap
+		metadata:
+		  name: config-one
+		  namespace: %v
+		data:
+		  foo: foo1
+		---
+		apiVersion: v1
+		kind: ConfigMap
+		metadata:
+		  name: config-two
+		  namespace: %v
+		data:
+		  foo: foo2
+	`, ns, ns, ns))[1:]
+	labels := map[string]string{"goply.io/managed-by-set": ns}
+
+	_, err := r.Apply(yaml, ApplyOpts{ManagementLabels: labels})
+	require.NoError(t, err)
+
+	plan, err := r.DiffAgainstOwned(context.Background(), yaml, labels)
+	require.NoError(t, err)
+	require.Empty(t, plan.Create)
+	require.Empty(t, plan.Update)
+	require.Empty(t, plan.Prune)
+	require.Len(t, plan.Unchanged, 3)
+
+	// config-one changes, config-two goes away, config-three is new
+	desired := dedent.Dedent(fmt.Sprintf(`
+		---
+		apiVersion: v1
+		kind: Namespace
+		metadata:
+		  name: %v
+		---
+		apiVersion: v1
+		kind: ConfigMap
+		metadata:
+		  name: config-one
+		  namespace: %v
+		data:
+		  foo: changed
+		---
+		apiVersion: v1
+		kind: ConfigMap
+		metadata:
+		  name: config-three
+		  namespace: %v
+		data:
+		  foo: foo3
+	`, ns, ns, ns))[1:]
+
+	plan, err = r.DiffAgainstOwned(context.Background(), desired, labels)
+	require.NoError(t, err)
+	require.Equal(t, []string{"config-three"}, lo.Map(plan.Create, func(item InventoryItem, _ int) string { return item.Name }))
+	require.Equal(t, []string{"config-one"}, lo.Map(plan.Update, func(u PlannedUpdate, _ int) string { return u.Name }))
+	require.Equal(t, []string{"config-two"}, lo.Map(plan.Prune, func(item InventoryItem, _ int) string { return item.Name }))
+}
+
+func TestApplyObjectLeavesStoredInventory(t *testing.T) {
+	const ns = "goply-apply-object-inventory-test"
+	r, client, cleanup := basicSetup(t, ns)
+	defer cleanup()
+
+	yaml := dedent.Dedent(fmt.Sprintf(`
+		---
+		apiVersion: v1
+		kind: Namespace
+		metadata:
+		  name: %v
+		---
+		apiVersion: v1
+		kind: ConfigMap
+		metadata:
+		  name: config-one
+		  namespace: %v
+		---
+		apiVersion: v1
+		kind: ConfigMap
+		metadata:
+		  name: config-two
+		  namespace: %v
+	`, ns, ns, ns))[1:]
+	opts := ApplyOpts{InventoryKey: "app", InventoryNamespace: ns}
+
+	result, err := r.Reconcile(yaml, opts, nil)
+	require.NoError(t, err)
+
+	obj := &unstructured.Unstructured{}
+	obj.SetAPIVersion("v1")
+	obj.SetKind("ConfigMap")
+	obj.SetNamespace(ns)
+	obj.SetName("config-one")
+	require.NoError(t, unstructured.SetNestedField(obj.Object, "foo1", "data", "foo"))
+
+	_, _, err = r.ApplyObject(context.Background(), obj, opts)
+	require.NoError(t, err)
+
+	_, err = client.CoreV1().ConfigMaps(ns).Get(context.TODO(), "config-two", metav1.GetOptions{})
+	require.NoError(t, err)
+
+	stored, err := NewConfigMapInventoryStore(r.Client(), ns).Load(context.Background(), "app")
+	require.NoError(t, err)
+	require.True(t, stored.Equal(result.Inventory))
 }
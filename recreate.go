@@ -0,0 +1,103 @@
+package goply
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/fluxcd/pkg/ssa"
+	ssaerrors "github.com/fluxcd/pkg/ssa/errors"
+	ssautils "github.com/fluxcd/pkg/ssa/utils"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	EventReasonRecreate = "Recreate"
+)
+
+// withRecreate runs apply, recreating objects that hit immutable field errors when asked to and then retrying. The
+// resource manager can do this itself via ApplyOptions.Force, but then there's no way to tell the caller which objects
+// were destroyed
+func (r *Reconciler) withRecreate(ctx context.Context, opts ApplyOpts, apply func() (*ssa.ChangeSet, error)) (*ssa.ChangeSet, error) {
+	recreated := newSet[string]()
+
+	for {
+		changeSet, err := apply()
+		if err == nil || !opts.RecreateOnImmutableError {
+			return changeSet, err
+		}
+
+		var dryRunErr *ssaerrors.DryRunErr
+		if !errors.As(err, &dryRunErr) || dryRunErr.InvolvedObject() == nil || !isImmutableError(dryRunErr.Unwrap()) {
+			return changeSet, err
+		}
+
+		obj := dryRunErr.InvolvedObject()
+		id := ssautils.FmtUnstructured(obj)
+		// Should never happen, but don't loop forever if the recreated object still can't be applied
+		if recreated.Contains(id) {
+			return changeSet, err
+		}
+		recreated.Add(id)
+
+		if err := r.recreate(ctx, obj, opts); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// isImmutableError is deliberately stricter than ssaerrors.IsImmutableError, which treats *any* invalid/conflict
+// error as immutable. Deleting objects over a typo would be rather unfortunate
+func isImmutableError(err error) bool {
+	return (apierrors.IsInvalid(err) || apierrors.IsConflict(err) || apierrors.IsForbidden(err)) &&
+		strings.Contains(err.Error(), "immutable")
+}
+
+func (r *Reconciler) recreate(ctx context.Context, obj *unstructured.Unstructured, opts ApplyOpts) error {
+	item := toInventoryItem(obj)
+	r.event(ctx, Event{
+		Type:    EventTypeWarning,
+		Reason:  EventReasonRecreate,
+		Object:  &item,
+		Message: fmt.Sprintf("immutable field change detected, DELETING and recreating %v", ssautils.FmtUnstructured(obj)),
+	})
+
+	existing := &unstructured.Unstructured{}
+	existing.SetGroupVersionKind(obj.GroupVersionKind())
+	existing.SetName(obj.GetName())
+	existing.SetNamespace(obj.GetNamespace())
+
+	err := r.mgr.Client().Delete(ctx, existing, client.PropagationPolicy(metav1.DeletePropagationForeground))
+	if err != nil && !apierrors.IsNotFound(err) {
+		return fmt.Errorf("error deleting %v for recreation: %w", ssautils.FmtUnstructured(obj), err)
+	}
+
+	waitCtx, cancel := context.WithTimeout(ctx, *opts.WaitTimeout)
+	defer cancel()
+	err = wait.PollUntilContextCancel(waitCtx, 2*time.Second, true, func(ctx context.Context) (bool, error) {
+		err := r.mgr.Client().Get(ctx, client.ObjectKeyFromObject(existing), existing.DeepCopy())
+		if apierrors.IsNotFound(err) {
+			return true, nil
+		}
+		return false, err
+	})
+	if err != nil {
+		return fmt.Errorf("error waiting for %v to be deleted for recreation: %w", ssautils.FmtUnstructured(obj), err)
+	}
+
+	if opts.RecreateDelay > 0 {
+		select {
+		case <-time.After(opts.RecreateDelay):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	return nil
+}
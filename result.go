@@ -0,0 +1,103 @@
+package goply
+
+import (
+	"time"
+
+	"github.com/fluxcd/pkg/ssa"
+	"github.com/samber/lo"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/cli-utils/pkg/object"
+)
+
+type Action string
+
+const (
+	ActionCreated    Action = Action(ssa.CreatedAction)
+	ActionConfigured Action = Action(ssa.ConfiguredAction)
+	ActionUnchanged  Action = Action(ssa.UnchangedAction)
+	ActionDeleted    Action = Action(ssa.DeletedAction)
+	ActionSkipped    Action = Action(ssa.SkippedAction)
+	ActionUnknown    Action = Action(ssa.UnknownAction)
+)
+
+type SkipReason string
+
+const (
+	SkipReasonMissingKind     SkipReason = "MissingKind"
+	SkipReasonConflict        SkipReason = "Conflict"
+	SkipReasonAlreadyExists   SkipReason = "AlreadyExists"
+	SkipReasonNormalizeFailed SkipReason = "NormalizeFailed"
+	SkipReasonUnchanged       SkipReason = "Unchanged"
+	SkipReasonPaused          SkipReason = "Paused"
+)
+
+type Result struct {
+	// ReconcileID uniquely identifies the reconcile that produced this result
+	ReconcileID string
+	// Timestamp is when the reconcile started
+	Timestamp time.Time
+	// Attempts is how many times the reconcile was run, only ever more than 1 with ReconcileWithRetry
+	Attempts      int
+	Inventory     Inventory
+	Changes       []Change
+	Skipped       []SkippedItem
+	Discrepancies []Discrepancy
+	// Pruned holds the objects from the previous inventory that were deleted
+	Pruned []InventoryItem
+	// HandedOff holds the objects whose field ownership was relinquished, see ApplyOpts.RelinquishAfterApply
+	HandedOff []InventoryItem
+	// Adopted holds the pre-existing objects goply took over, see ApplyOpts.Adopt
+	Adopted []InventoryItem
+	// ReadyAfter is how long each object waited on took to become ready, measured from the start of its wait, which
+	// is useful for tuning WaitTimeout. Objects that timed out, or weren't waited on, aren't present
+	ReadyAfter map[object.ObjMetadata]time.Duration
+	// OwnedFieldsChanges holds the objects whose set of fields owned by goply changed, see ApplyOpts.TrackOwnedFields
+	OwnedFieldsChanges []OwnedFieldsChange
+}
+
+// Change is what was done to a single object during the reconcile
+type Change struct {
+	InventoryItem
+	Action Action
+}
+
+func (r *Result) addChangeSet(changeSet *ssa.ChangeSet) {
+	if changeSet == nil {
+		return
+	}
+	r.Changes = append(r.Changes, lo.Map(changeSet.Entries, func(e ssa.ChangeSetEntry, _ int) Change {
+		return Change{
+			InventoryItem: InventoryItem{
+				ObjMetadata:  fromFluxObjMetadata(e.ObjMetadata),
+				GroupVersion: e.GroupVersion,
+			},
+			Action: Action(e.Action),
+		}
+	})...)
+}
+
+// CountsByKind returns how many objects of each kind were applied, whatever the outcome
+func (r Result) CountsByKind() map[schema.GroupKind]int {
+	counts := map[schema.GroupKind]int{}
+	for _, c := range r.Changes {
+		counts[c.GroupKind]++
+	}
+	return counts
+}
+
+// CountsByKindAndAction breaks CountsByKind down further, by what was done to the objects
+func (r Result) CountsByKindAndAction() map[schema.GroupKind]map[Action]int {
+	counts := map[schema.GroupKind]map[Action]int{}
+	for _, c := range r.Changes {
+		if counts[c.GroupKind] == nil {
+			counts[c.GroupKind] = map[Action]int{}
+		}
+		counts[c.GroupKind][c.Action]++
+	}
+	return counts
+}
+
+type SkippedItem struct {
+	InventoryItem
+	Reason SkipReason
+}
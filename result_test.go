@@ -0,0 +1,34 @@
+package goply
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/cli-utils/pkg/object"
+)
+
+func TestCountsByKind(t *testing.T) {
+	configMap := schema.GroupKind{Kind: "ConfigMap"}
+	deployment := schema.GroupKind{Group: "apps", Kind: "Deployment"}
+	change := func(gk schema.GroupKind, name string, action Action) Change {
+		return Change{InventoryItem: InventoryItem{ObjMetadata: object.ObjMetadata{GroupKind: gk, Name: name}}, Action: action}
+	}
+
+	result := Result{Changes: []Change{
+		change(configMap, "config-one", ActionCreated),
+		change(configMap, "config-two", ActionUnchanged),
+		change(configMap, "config-three", ActionCreated),
+		change(deployment, "deploy-one", ActionConfigured),
+	}}
+
+	require.Equal(t, map[schema.GroupKind]int{configMap: 3, deployment: 1}, result.CountsByKind())
+	require.Equal(
+		t,
+		map[schema.GroupKind]map[Action]int{
+			configMap:  {ActionCreated: 2, ActionUnchanged: 1},
+			deployment: {ActionConfigured: 1},
+		},
+		result.CountsByKindAndAction(),
+	)
+}
@@ -0,0 +1,79 @@
+package goply
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	utilnet "k8s.io/apimachinery/pkg/util/net"
+)
+
+// RetryPolicy controls how ReconcileWithRetry retries a failed reconcile. Zero values default to 3 attempts, waiting
+// 1s before the first retry and doubling up to 30s, and retrying only errors IsRetryable considers transient
+type RetryPolicy struct {
+	MaxAttempts int
+	Initial     time.Duration
+	Max         time.Duration
+	Retryable   func(error) bool
+}
+
+func (p RetryPolicy) withDefaults() RetryPolicy {
+	if p.MaxAttempts <= 0 {
+		p.MaxAttempts = 3
+	}
+	if p.Initial <= 0 {
+		p.Initial = time.Second
+	}
+	if p.Max <= 0 {
+		p.Max = 30 * time.Second
+	}
+	if p.Retryable == nil {
+		p.Retryable = IsRetryable
+	}
+	return p
+}
+
+// IsRetryable reports whether err looks like a transient problem with the cluster, one that running the same reconcile
+// again could get past
+func IsRetryable(err error) bool {
+	return apierrors.IsServerTimeout(err) ||
+		apierrors.IsTimeout(err) ||
+		apierrors.IsTooManyRequests(err) ||
+		apierrors.IsServiceUnavailable(err) ||
+		apierrors.IsInternalError(err) ||
+		apierrors.IsConflict(err) ||
+		utilnet.IsConnectionRefused(err) ||
+		utilnet.IsConnectionReset(err) ||
+		utilnet.IsProbableEOF(err)
+}
+
+// ReconcileWithRetry runs Reconcile, running the whole thing again with backoff when it fails with a retryable error.
+// Reconciling is idempotent, so everything already applied or pruned by a failed attempt is simply found to be done
+// by the next. Every attempt shares the same reconcile ID, and Result.Attempts says how many were made, including on
+// failure. It gives up early, returning the last error, if ctx is done
+func (r *Reconciler) ReconcileWithRetry(ctx context.Context, yaml string, opts ApplyOpts, previousInventory *Inventory, policy RetryPolicy) (Result, error) {
+	policy = policy.withDefaults()
+	if opts.ReconcileID == "" {
+		opts.ReconcileID = uuid.NewString()
+	}
+	ctx = withReconcileID(ctx, opts.ReconcileID)
+
+	backoff := policy.Initial
+	for attempt := 1; ; attempt++ {
+		result, err := r.ReconcileContext(ctx, yaml, opts, previousInventory)
+		result.Attempts = attempt
+		if err == nil || attempt == policy.MaxAttempts || !policy.Retryable(err) {
+			return result, err
+		}
+
+		r.log(ctx, "reconcile failed, retrying", "error", err, "backoff", backoff, "attempt", attempt, "attempts", policy.MaxAttempts)
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return result, errors.Join(err, ctx.Err())
+		}
+		backoff = min(backoff*2, policy.Max)
+	}
+}
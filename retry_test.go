@@ -0,0 +1,49 @@
+package goply
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/lithammer/dedent"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReconcileWithRetry(t *testing.T) {
+	yaml := dedent.Dedent(`
+		---
+		apiVersion: v1
+		kind: ConfigMap
+		metadata:
+		  name: config-one
+		  namespace: goply-test
+	`)[1:]
+
+	t.Run("retryable", func(t *testing.T) {
+		// Nothing is listening, so every attempt fails with a refused connection
+		result, err := offlineReconciler(t).ReconcileWithRetry(context.Background(), yaml, ApplyOpts{}, nil, RetryPolicy{
+			MaxAttempts: 3,
+			Initial:     10 * time.Millisecond,
+		})
+		require.Error(t, err)
+		require.True(t, IsRetryable(err))
+		require.Equal(t, 3, result.Attempts)
+	})
+
+	t.Run("not retryable", func(t *testing.T) {
+		result, err := offlineReconciler(t).ReconcileWithRetry(context.Background(), yaml, ApplyOpts{}, nil, RetryPolicy{
+			Initial:   10 * time.Millisecond,
+			Retryable: func(error) bool { return false },
+		})
+		require.Error(t, err)
+		require.Equal(t, 1, result.Attempts)
+	})
+
+	t.Run("cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		result, err := offlineReconciler(t).ReconcileWithRetry(ctx, yaml, ApplyOpts{}, nil, RetryPolicy{Initial: time.Hour})
+		require.ErrorIs(t, err, context.Canceled)
+		require.Equal(t, 1, result.Attempts)
+	})
+}
@@ -0,0 +1,253 @@
+package goply
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	ssautils "github.com/fluxcd/pkg/ssa/utils"
+	"github.com/samber/lo"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/structured-merge-diff/v4/fieldpath"
+	"sigs.k8s.io/structured-merge-diff/v4/value"
+)
+
+const (
+	EventReasonRollback = "Rollback"
+)
+
+// RollbackError is returned when a reconcile with ApplyOpts.Rollback fails and a rollback was attempted. It wraps the
+// original failure. Rollback is best effort, anything it couldn't put back is in Failed
+type RollbackError struct {
+	// Restored objects had goply's fields re-applied as they were before the reconcile
+	Restored []InventoryItem
+	// Deleted objects were created by the failed reconcile, and have been deleted again
+	Deleted []InventoryItem
+	Failed  []RollbackFailure
+	err     error
+}
+
+// RollbackFailure is an object that couldn't be rolled back, and why
+type RollbackFailure struct {
+	InventoryItem
+	Err error
+}
+
+func (e *RollbackError) Error() string {
+	msg := fmt.Sprintf("%v (rolled back %v objects", e.err, len(e.Restored)+len(e.Deleted))
+	if len(e.Failed) == 0 {
+		return msg + ")"
+	}
+	return fmt.Sprintf(
+		"%v, failed to roll back: [%v])",
+		msg,
+		strings.Join(lo.Map(e.Failed, func(f RollbackFailure, _ int) string { return fmt.Sprintf("%v: %v", f.ID(), f.Err) }), ", "),
+	)
+}
+
+func (e *RollbackError) Unwrap() error {
+	return e.err
+}
+
+// snapshot is the state of a set of objects before a reconcile touched them. A nil live object didn't exist
+type snapshot struct {
+	objects []*unstructured.Unstructured
+	live    []*unstructured.Unstructured
+}
+
+// takeSnapshot reads the current state of every object. Objects of kinds that aren't installed yet count as not
+// existing, they're presumably defined by a CRD in the same manifest
+func (r *Reconciler) takeSnapshot(ctx context.Context, objects []*unstructured.Unstructured) (*snapshot, error) {
+	snap := &snapshot{}
+	for _, obj := range objects {
+		live := &unstructured.Unstructured{}
+		live.SetGroupVersionKind(obj.GroupVersionKind())
+		err := r.mgr.Client().Get(ctx, client.ObjectKeyFromObject(obj), live)
+		switch {
+		case apierrors.IsNotFound(err) || meta.IsNoMatchError(err):
+			live = nil
+		case err != nil:
+			return nil, fmt.Errorf("error getting %v: %w", ssautils.FmtUnstructured(obj), err)
+		}
+
+		snap.objects = append(snap.objects, obj)
+		snap.live = append(snap.live, live)
+	}
+	return snap, nil
+}
+
+// rollback puts every object in snap that's changed since back the way it was, deleting the ones that didn't exist.
+// Objects still at the resource version they were snapshotted at are left alone
+func (r *Reconciler) rollback(ctx context.Context, snap *snapshot, cause error) error {
+	r.log(ctx, "rolling back after failure", "error", cause)
+	rollbackErr := &RollbackError{err: cause}
+
+	// In reverse, so stage two objects are dealt with before the namespaces and CRDs they depend on
+	for idx := len(snap.objects) - 1; idx >= 0; idx-- {
+		obj, previous := snap.objects[idx], snap.live[idx]
+		item := toInventoryItem(obj)
+
+		current := &unstructured.Unstructured{}
+		current.SetGroupVersionKind(obj.GroupVersionKind())
+		err := r.mgr.Client().Get(ctx, client.ObjectKeyFromObject(obj), current)
+		switch {
+		case apierrors.IsNotFound(err) || meta.IsNoMatchError(err):
+			if previous != nil {
+				rollbackErr.Failed = append(rollbackErr.Failed, RollbackFailure{InventoryItem: item, Err: errors.New("no longer exists")})
+			}
+			continue
+		case err != nil:
+			rollbackErr.Failed = append(rollbackErr.Failed, RollbackFailure{InventoryItem: item, Err: err})
+			continue
+		}
+
+		if previous == nil {
+			r.log(ctx, "rolling back, deleting newly created object", objectKV(obj)...)
+			if err := r.mgr.Client().Delete(ctx, current); err != nil && !apierrors.IsNotFound(err) {
+				rollbackErr.Failed = append(rollbackErr.Failed, RollbackFailure{InventoryItem: item, Err: err})
+				continue
+			}
+			rollbackErr.Deleted = append(rollbackErr.Deleted, item)
+			continue
+		}
+
+		if current.GetResourceVersion() == previous.GetResourceVersion() {
+			continue
+		}
+
+		r.log(ctx, "rolling back, restoring object", objectKV(obj)...)
+		restore, err := restorable(previous)
+		if err != nil {
+			rollbackErr.Failed = append(rollbackErr.Failed, RollbackFailure{InventoryItem: item, Err: err})
+			continue
+		}
+		if err := r.mgr.Client().Patch(ctx, restore, client.Apply, client.FieldOwner(fieldManager)); err != nil {
+			rollbackErr.Failed = append(rollbackErr.Failed, RollbackFailure{InventoryItem: item, Err: err})
+			continue
+		}
+		rollbackErr.Restored = append(rollbackErr.Restored, item)
+	}
+
+	r.event(ctx, Event{
+		Type:    EventTypeWarning,
+		Reason:  EventReasonRollback,
+		Message: rollbackErr.Error(),
+	})
+	return rollbackErr
+}
+
+// restorable builds what goply last applied to an object, from the fields its apply owned in the live object as
+// snapshotted. Re-applying that, without forcing, puts back goply's own fields and nothing else, so fields other managers
+// set (an HPA's replicas, a controller's defaults) are left to them. An object goply had never applied can't be
+// restored that way, only taken over, so it's an error
+func restorable(live *unstructured.Unstructured) (*unstructured.Unstructured, error) {
+	owned, err := goplyFields(live.GetManagedFields())
+	if err != nil {
+		return nil, fmt.Errorf("error decoding managed fields: %w", err)
+	}
+	if owned == nil {
+		return nil, errors.New("not previously applied by goply")
+	}
+
+	fields, _ := ownedValue(live.Object, owned).(map[string]any)
+	obj := &unstructured.Unstructured{Object: fields}
+	for _, field := range []string{"resourceVersion", "uid", "creationTimestamp", "generation", "managedFields", "selfLink"} {
+		unstructured.RemoveNestedField(obj.Object, "metadata", field)
+	}
+	unstructured.RemoveNestedField(obj.Object, "status")
+	obj.SetGroupVersionKind(live.GroupVersionKind())
+	obj.SetNamespace(live.GetNamespace())
+	obj.SetName(live.GetName())
+	return obj, nil
+}
+
+// ownedValue returns the parts of v covered by set. A member of set is copied whole, unless set also has children
+// for it, in which case only those are
+func ownedValue(v any, set *fieldpath.Set) any {
+	switch v := v.(type) {
+	case map[string]any:
+		out := map[string]any{}
+		set.Members.Iterate(func(pe fieldpath.PathElement) {
+			if pe.FieldName != nil {
+				if child, ok := v[*pe.FieldName]; ok {
+					out[*pe.FieldName] = runtime.DeepCopyJSONValue(child)
+				}
+			}
+		})
+		set.Children.Iterate(func(pe fieldpath.PathElement) {
+			if pe.FieldName != nil {
+				if child, ok := v[*pe.FieldName]; ok {
+					out[*pe.FieldName] = ownedValue(child, set.Children.Descend(pe))
+				}
+			}
+		})
+		return out
+	case []any:
+		out := []any{}
+		for idx, item := range v {
+			if pe, ok := listElement(set, idx, item); ok {
+				children, hasChildren := set.Children.Get(pe)
+				switch {
+				case hasChildren:
+					owned := ownedValue(item, children)
+					// The keys identify the item, they have to be sent along with it
+					if ownedMap, ok := owned.(map[string]any); ok && pe.Key != nil {
+						for _, key := range *pe.Key {
+							ownedMap[key.Name] = item.(map[string]any)[key.Name]
+						}
+					}
+					out = append(out, owned)
+				default:
+					out = append(out, runtime.DeepCopyJSONValue(item))
+				}
+			}
+		}
+		return out
+	default:
+		return runtime.DeepCopyJSONValue(v)
+	}
+}
+
+// listElement finds the element of set, member or child, selecting the list item at idx
+func listElement(set *fieldpath.Set, idx int, item any) (fieldpath.PathElement, bool) {
+	var found *fieldpath.PathElement
+	match := func(pe fieldpath.PathElement) {
+		if found == nil && selects(pe, idx, item) {
+			found = &pe
+		}
+	}
+	set.Children.Iterate(match)
+	set.Members.Iterate(match)
+	if found == nil {
+		return fieldpath.PathElement{}, false
+	}
+	return *found, true
+}
+
+func selects(pe fieldpath.PathElement, idx int, item any) bool {
+	switch {
+	case pe.Index != nil:
+		return *pe.Index == idx
+	case pe.Value != nil:
+		return value.Equals(*pe.Value, value.NewValueInterface(item))
+	case pe.Key != nil:
+		fields, ok := item.(map[string]any)
+		if !ok {
+			return false
+		}
+		for _, key := range *pe.Key {
+			field, ok := fields[key.Name]
+			if !ok || !value.Equals(key.Value, value.NewValueInterface(field)) {
+				return false
+			}
+		}
+		return true
+	default:
+		return false
+	}
+}
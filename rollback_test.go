@@ -0,0 +1,101 @@
+package goply
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/lithammer/dedent"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRestorable(t *testing.T) {
+	objs, err := GetObjects(dedent.Dedent(`
+		---
+		apiVersion: apps/v1
+		kind: Deployment
+		metadata:
+		  name: deploy-one
+		  namespace: goply-test
+		  resourceVersion: "42"
+		  uid: 0b7b5a4c-5b8e-4c3b-9d5c-7c1a3c9d2e10
+		  creationTimestamp: "2024-01-01T00:00:00Z"
+		  labels:
+		    app: web
+		    added-by-hand: "true"
+		  managedFields:
+		  - manager: goply
+		    operation: Apply
+		    fieldsType: FieldsV1
+		    fieldsV1:
+		      f:metadata:
+		        f:labels:
+		          f:app: {}
+		      f:spec:
+		        f:template:
+		          f:spec:
+		            f:containers:
+		              k:{"name":"web"}:
+		                .: {}
+		                f:image: {}
+		                f:name: {}
+		  - manager: kube-controller-manager
+		    operation: Update
+		    fieldsType: FieldsV1
+		    fieldsV1:
+		      f:metadata:
+		        f:labels:
+		          f:added-by-hand: {}
+		      f:spec:
+		        f:replicas: {}
+		spec:
+		  replicas: 5
+		  template:
+		    spec:
+		      containers:
+		      - name: web
+		        image: nginx:1.27
+		        imagePullPolicy: IfNotPresent
+		      - name: injected
+		        image: sidecar:v1
+		status:
+		  replicas: 5
+	`)[1:])
+	require.NoError(t, err)
+
+	restore, err := restorable(objs[0])
+	require.NoError(t, err)
+	require.Equal(t, map[string]any{
+		"apiVersion": "apps/v1",
+		"kind":       "Deployment",
+		"metadata": map[string]any{
+			"name":      "deploy-one",
+			"namespace": "goply-test",
+			"labels":    map[string]any{"app": "web"},
+		},
+		"spec": map[string]any{
+			"template": map[string]any{
+				"spec": map[string]any{
+					"containers": []any{map[string]any{"name": "web", "image": "nginx:1.27"}},
+				},
+			},
+		},
+	}, restore.Object)
+	// The snapshot itself is left alone
+	require.Equal(t, "42", objs[0].GetResourceVersion())
+
+	// Something goply never applied has nothing of goply's to restore
+	objs[0].SetManagedFields(objs[0].GetManagedFields()[1:])
+	_, err = restorable(objs[0])
+	require.ErrorContains(t, err, "not previously applied by goply")
+}
+
+func TestRollbackError(t *testing.T) {
+	cause := errors.New("error applying stage two resources")
+	err := error(&RollbackError{
+		Restored: []InventoryItem{{}},
+		Failed:   []RollbackFailure{{Err: errors.New("forbidden")}},
+		err:      cause,
+	})
+	require.ErrorIs(t, err, cause)
+	require.ErrorContains(t, err, "rolled back 1 objects, failed to roll back: [")
+}
@@ -0,0 +1,106 @@
+package goply
+
+import (
+	"fmt"
+
+	"github.com/fluxcd/cli-utils/pkg/kstatus/polling/event"
+	"github.com/fluxcd/cli-utils/pkg/kstatus/status"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// checkRollout downgrades a current status to in progress while a Deployment, StatefulSet, or DaemonSet is still
+// rolling out its latest revision: until its controller has seen the latest generation, and every replica is both
+// updated and ready. Workloads using the OnDelete update strategy only have to be ready, since they never roll out on
+// their own
+func checkRollout(rs *event.ResourceStatus) *event.ResourceStatus {
+	if rs.Status != status.CurrentStatus || rs.Resource == nil || rs.Resource.GroupVersionKind().Group != "apps" {
+		return rs
+	}
+
+	msg := ""
+	switch rs.Resource.GetKind() {
+	case "Deployment":
+		msg = deploymentRollout(rs.Resource)
+	case "StatefulSet":
+		msg = statefulSetRollout(rs.Resource)
+	case "DaemonSet":
+		msg = daemonSetRollout(rs.Resource)
+	default:
+		return rs
+	}
+	if msg == "" {
+		return checkObservedGeneration(rs)
+	}
+
+	rollingOut := *rs
+	rollingOut.Status = status.InProgressStatus
+	rollingOut.Message = msg
+	return &rollingOut
+}
+
+func deploymentRollout(obj *unstructured.Unstructured) string {
+	replicas := desiredReplicas(obj)
+	updated := statusInt(obj, "updatedReplicas")
+	ready := statusInt(obj, "readyReplicas")
+	total := statusInt(obj, "replicas")
+
+	switch {
+	case updated < replicas:
+		return fmt.Sprintf("rollout in progress, %v of %v replicas updated", updated, replicas)
+	case total > updated:
+		return fmt.Sprintf("rollout in progress, %v old replicas pending termination", total-updated)
+	case ready < replicas:
+		return fmt.Sprintf("rollout in progress, %v of %v replicas ready", ready, replicas)
+	default:
+		return ""
+	}
+}
+
+func statefulSetRollout(obj *unstructured.Unstructured) string {
+	replicas := desiredReplicas(obj)
+	ready := statusInt(obj, "readyReplicas")
+
+	if !isOnDelete(obj) {
+		if updated := statusInt(obj, "updatedReplicas"); updated < replicas {
+			return fmt.Sprintf("rollout in progress, %v of %v replicas updated", updated, replicas)
+		}
+	}
+	if ready < replicas {
+		return fmt.Sprintf("rollout in progress, %v of %v replicas ready", ready, replicas)
+	}
+	return ""
+}
+
+func daemonSetRollout(obj *unstructured.Unstructured) string {
+	desired := statusInt(obj, "desiredNumberScheduled")
+	ready := statusInt(obj, "numberReady")
+
+	if !isOnDelete(obj) {
+		if updated := statusInt(obj, "updatedNumberScheduled"); updated < desired {
+			return fmt.Sprintf("rollout in progress, %v of %v pods updated", updated, desired)
+		}
+	}
+	if ready < desired {
+		return fmt.Sprintf("rollout in progress, %v of %v pods ready", ready, desired)
+	}
+	return ""
+}
+
+// desiredReplicas returns spec.replicas, which defaults to 1
+func desiredReplicas(obj *unstructured.Unstructured) int64 {
+	replicas, found, err := unstructured.NestedInt64(obj.Object, "spec", "replicas")
+	if !found || err != nil {
+		return 1
+	}
+	return replicas
+}
+
+func statusInt(obj *unstructured.Unstructured, field string) int64 {
+	value, _, _ := unstructured.NestedInt64(obj.Object, "status", field)
+	return value
+}
+
+func isOnDelete(obj *unstructured.Unstructured) bool {
+	strategy, _, _ := unstructured.NestedString(obj.Object, "spec", "updateStrategy", "type")
+	return strategy == "OnDelete"
+}
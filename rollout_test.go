@@ -0,0 +1,107 @@
+package goply
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/fluxcd/cli-utils/pkg/kstatus/polling/event"
+	"github.com/fluxcd/cli-utils/pkg/kstatus/status"
+	"github.com/lithammer/dedent"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckRollout(t *testing.T) {
+	rollout := func(t *testing.T, yaml string) *event.ResourceStatus {
+		t.Helper()
+		objs, err := decodeObjects(strings.NewReader(dedent.Dedent(yaml)[1:]))
+		require.NoError(t, err)
+		return checkRollout(&event.ResourceStatus{Status: status.CurrentStatus, Resource: objs[0]})
+	}
+
+	t.Run("deployment mid rollout", func(t *testing.T) {
+		rs := rollout(t, `
+			apiVersion: apps/v1
+			kind: Deployment
+			metadata:
+			  name: deploy-one
+			  generation: 2
+			spec:
+			  replicas: 3
+			status:
+			  observedGeneration: 2
+			  replicas: 4
+			  updatedReplicas: 3
+			  readyReplicas: 3
+		`)
+		require.Equal(t, status.InProgressStatus, rs.Status)
+		require.Equal(t, "rollout in progress, 1 old replicas pending termination", rs.Message)
+	})
+
+	t.Run("deployment rolled out", func(t *testing.T) {
+		rs := rollout(t, `
+			apiVersion: apps/v1
+			kind: Deployment
+			metadata:
+			  name: deploy-one
+			  generation: 2
+			spec:
+			  replicas: 3
+			status:
+			  observedGeneration: 2
+			  replicas: 3
+			  updatedReplicas: 3
+			  readyReplicas: 3
+		`)
+		require.Equal(t, status.CurrentStatus, rs.Status)
+	})
+
+	t.Run("deployment generation not observed", func(t *testing.T) {
+		rs := rollout(t, `
+			apiVersion: apps/v1
+			kind: Deployment
+			metadata:
+			  name: deploy-one
+			  generation: 3
+			spec:
+			  replicas: 1
+			status:
+			  observedGeneration: 2
+			  replicas: 1
+			  updatedReplicas: 1
+			  readyReplicas: 1
+		`)
+		require.Equal(t, status.InProgressStatus, rs.Status)
+	})
+
+	t.Run("statefulset on delete", func(t *testing.T) {
+		rs := rollout(t, `
+			apiVersion: apps/v1
+			kind: StatefulSet
+			metadata:
+			  name: sts-one
+			spec:
+			  replicas: 2
+			  updateStrategy:
+			    type: OnDelete
+			status:
+			  readyReplicas: 2
+			  updatedReplicas: 0
+		`)
+		require.Equal(t, status.CurrentStatus, rs.Status)
+	})
+
+	t.Run("daemonset mid rollout", func(t *testing.T) {
+		rs := rollout(t, `
+			apiVersion: apps/v1
+			kind: DaemonSet
+			metadata:
+			  name: ds-one
+			status:
+			  desiredNumberScheduled: 3
+			  updatedNumberScheduled: 1
+			  numberReady: 3
+		`)
+		require.Equal(t, status.InProgressStatus, rs.Status)
+		require.Equal(t, "rollout in progress, 1 of 3 pods updated", rs.Message)
+	})
+}
@@ -0,0 +1,21 @@
+package goply
+
+import (
+	"fmt"
+	"io"
+
+	ssautils "github.com/fluxcd/pkg/ssa/utils"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// writeManifests writes objects to w as a multi-document YAML stream, headed by a comment naming the stage
+func writeManifests(w io.Writer, stage string, objects []*unstructured.Unstructured) error {
+	manifests, err := ssautils.ObjectsToYAML(objects)
+	if err != nil {
+		return fmt.Errorf("error encoding stage %v manifests: %w", stage, err)
+	}
+	if _, err := fmt.Fprintf(w, "# stage %v\n---\n%v", stage, manifests); err != nil {
+		return fmt.Errorf("error writing stage %v manifests: %w", stage, err)
+	}
+	return nil
+}
@@ -0,0 +1,43 @@
+package goply
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/lithammer/dedent"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteManifests(t *testing.T) {
+	objs, err := GetObjects(dedent.Dedent(`
+		---
+		apiVersion: v1
+		kind: ConfigMap
+		metadata:
+		  name: config-one
+		  namespace: goply-test
+		data:
+		  foo: foo1
+	`)[1:])
+	require.NoError(t, err)
+
+	b := strings.Builder{}
+	require.NoError(t, writeManifests(&b, StageTwo, objs))
+	require.Equal(t, dedent.Dedent(`
+		# stage two
+		---
+		apiVersion: v1
+		data:
+		  foo: foo1
+		kind: ConfigMap
+		metadata:
+		  name: config-one
+		  namespace: goply-test
+		---
+	`)[1:], b.String())
+
+	// And it reads back in
+	readBack, err := GetObjects(b.String())
+	require.NoError(t, err)
+	require.Equal(t, objs, readBack)
+}
@@ -0,0 +1,83 @@
+package goply
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+
+	ssautils "github.com/fluxcd/pkg/ssa/utils"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/cli-utils/pkg/object"
+)
+
+// SnapshotDiff is how a manifest differs from a previous inventory, judged purely by the content hashes stored in it,
+// see SnapshotDiff
+type SnapshotDiff struct {
+	Added     []InventoryItem
+	Removed   []InventoryItem
+	Changed   []InventoryItem
+	Unchanged []InventoryItem
+	// Unknown objects are in both, but the previous inventory has no hash for them to compare against
+	Unknown []InventoryItem
+}
+
+// Empty reports whether the manifest is known to match the previous inventory exactly
+func (d SnapshotDiff) Empty() bool {
+	return len(d.Added)+len(d.Removed)+len(d.Changed)+len(d.Unknown) == 0
+}
+
+// SnapshotDiff compares yaml against previous, an inventory from an earlier reconcile, without contacting the cluster.
+// Objects are compared by InventoryItem.ContentHash, a hash of the object as it appears in the manifest, after
+// normalization. It can only tell whether the manifest has changed since, not whether the cluster has drifted from it,
+// or what an apply would actually change, that's what Plan is for
+func (r *Reconciler) SnapshotDiff(yaml string, previous Inventory) (SnapshotDiff, error) {
+	allObjects, err := GetObjects(yaml)
+	if err != nil {
+		return SnapshotDiff{}, fmt.Errorf("error decoding yaml to unstructured: %w", err)
+	}
+	if _, _, _, err := getResourceStages(allObjects, r.stages, r.transformers); err != nil {
+		return SnapshotDiff{}, fmt.Errorf("error getting resource stages: %w", err)
+	}
+
+	hashes, err := contentHashes(allObjects)
+	if err != nil {
+		return SnapshotDiff{}, err
+	}
+
+	diff := SnapshotDiff{}
+	previousItems := previous.Index()
+	for _, obj := range r.withoutIgnoredObjects(allObjects) {
+		item := toInventoryItem(obj)
+		item.ContentHash = hashes[item.ObjMetadata]
+
+		old, ok := previousItems.Get(item.ObjMetadata)
+		switch {
+		case !ok:
+			diff.Added = append(diff.Added, item)
+		case old.ContentHash == "":
+			diff.Unknown = append(diff.Unknown, item)
+		case old.ContentHash != item.ContentHash:
+			diff.Changed = append(diff.Changed, item)
+		default:
+			diff.Unchanged = append(diff.Unchanged, item)
+		}
+	}
+	diff.Removed = r.withoutIgnoredItems(toInventoryItems(previous.ItemsToRemove(Inventory{Items: toInventoryItems(allObjects)})))
+
+	return diff, nil
+}
+
+// contentHashes hashes every object as recorded in the LastAppliedAnnotation, so it's unaffected by the annotations goply
+// itself sets and doesn't take Secret values in the clear
+func contentHashes(objects []*unstructured.Unstructured) (map[object.ObjMetadata]string, error) {
+	hashes := make(map[object.ObjMetadata]string, len(objects))
+	for _, obj := range objects {
+		data, err := lastAppliedForm(obj).MarshalJSON()
+		if err != nil {
+			return nil, fmt.Errorf("error hashing %v: %w", ssautils.FmtUnstructured(obj), err)
+		}
+		sum := sha256.Sum256(data)
+		hashes[object.UnstructuredToObjMetadata(obj)] = hex.EncodeToString(sum[:])
+	}
+	return hashes, nil
+}
@@ -0,0 +1,59 @@
+package goply
+
+import (
+	"testing"
+
+	"github.com/lithammer/dedent"
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestSnapshotDiff(t *testing.T) {
+	configMap := func(name string, value string) string {
+		return dedent.Dedent(`
+			---
+			apiVersion: v1
+			kind: ConfigMap
+			metadata:
+			  name: ` + name + `
+			  namespace: goply-test
+			data:
+			  foo: ` + value + `
+		`)[1:]
+	}
+
+	previousObjs, err := GetObjects(configMap("unchanged", "foo1") + configMap("changed", "foo1") + configMap("removed", "foo1") + configMap("unhashed", "foo1"))
+	require.NoError(t, err)
+	// As a reconcile would have, normalized, and with goply's own annotations
+	_, _, _, err = getResourceStages(previousObjs, stageOverrides{}, nil)
+	require.NoError(t, err)
+	stampReconcileID(previousObjs, "abc")
+	hashes, err := contentHashes(previousObjs)
+	require.NoError(t, err)
+	previous := Inventory{Items: lo.Map(previousObjs, func(obj *unstructured.Unstructured, _ int) InventoryItem {
+		item := toInventoryItem(obj)
+		if item.Name != "unhashed" {
+			item.ContentHash = hashes[item.ObjMetadata]
+		}
+		return item
+	})}
+
+	r := offlineReconciler(t)
+	diff, err := r.SnapshotDiff(configMap("unchanged", "foo1")+configMap("changed", "foo2")+configMap("unhashed", "foo1")+configMap("added", "foo1"), previous)
+	require.NoError(t, err)
+
+	names := func(items []InventoryItem) []string {
+		return lo.Map(items, func(i InventoryItem, _ int) string { return i.Name })
+	}
+	require.Equal(t, []string{"added"}, names(diff.Added))
+	require.Equal(t, []string{"removed"}, names(diff.Removed))
+	require.Equal(t, []string{"changed"}, names(diff.Changed))
+	require.Equal(t, []string{"unchanged"}, names(diff.Unchanged))
+	require.Equal(t, []string{"unhashed"}, names(diff.Unknown))
+	require.False(t, diff.Empty())
+
+	diff, err = r.SnapshotDiff(configMap("unchanged", "foo1"), Inventory{Items: previous.Items[:1]})
+	require.NoError(t, err)
+	require.True(t, diff.Empty())
+}
@@ -0,0 +1,83 @@
+package goply
+
+import (
+	"sort"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// helmInstallOrder is the kind ordering Helm installs charts with
+var helmInstallOrder = []string{
+	"PriorityClass",
+	"Namespace",
+	"NetworkPolicy",
+	"ResourceQuota",
+	"LimitRange",
+	"PodSecurityPolicy",
+	"PodDisruptionBudget",
+	"ServiceAccount",
+	"Secret",
+	"SecretList",
+	"ConfigMap",
+	"StorageClass",
+	"PersistentVolume",
+	"PersistentVolumeClaim",
+	"CustomResourceDefinition",
+	"ClusterRole",
+	"ClusterRoleList",
+	"ClusterRoleBinding",
+	"ClusterRoleBindingList",
+	"Role",
+	"RoleList",
+	"RoleBinding",
+	"RoleBindingList",
+	"Service",
+	"DaemonSet",
+	"Pod",
+	"ReplicationController",
+	"ReplicaSet",
+	"Deployment",
+	"HorizontalPodAutoscaler",
+	"StatefulSet",
+	"Job",
+	"CronJob",
+	"IngressClass",
+	"Ingress",
+	"APIService",
+}
+
+// sortByKind orders objects by helmInstallOrder. Kinds Helm doesn't know about go last, alphabetically by kind, and
+// objects of the same kind keep their input order
+func sortByKind(objects []*unstructured.Unstructured) {
+	order := make(map[string]int, len(helmInstallOrder))
+	for i, kind := range helmInstallOrder {
+		order[kind] = i
+	}
+
+	sort.SliceStable(objects, func(i, j int) bool {
+		kindI, kindJ := objects[i].GetKind(), objects[j].GetKind()
+		orderI, knownI := order[kindI]
+		orderJ, knownJ := order[kindJ]
+
+		switch {
+		case knownI && knownJ:
+			return orderI < orderJ
+		case knownI != knownJ:
+			return knownI
+		default:
+			return kindI < kindJ
+		}
+	})
+}
+
+// groupByKind splits already sorted objects into runs of the same kind
+func groupByKind(objects []*unstructured.Unstructured) [][]*unstructured.Unstructured {
+	groups := [][]*unstructured.Unstructured{}
+	for i, obj := range objects {
+		if i == 0 || obj.GroupVersionKind().GroupKind() != objects[i-1].GroupVersionKind().GroupKind() {
+			groups = append(groups, []*unstructured.Unstructured{})
+		}
+		groups[len(groups)-1] = append(groups[len(groups)-1], obj)
+	}
+	return groups
+}
@@ -0,0 +1,60 @@
+package goply
+
+import (
+	"testing"
+
+	"github.com/lithammer/dedent"
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestSortByKind(t *testing.T) {
+	objs, err := GetObjects(dedent.Dedent(`
+		---
+		apiVersion: apps/v1
+		kind: Deployment
+		metadata:
+		  name: deploy-one
+		---
+		apiVersion: example.com/v1
+		kind: Widget
+		metadata:
+		  name: widget-one
+		---
+		apiVersion: v1
+		kind: ConfigMap
+		metadata:
+		  name: config-two
+		---
+		apiVersion: example.com/v1
+		kind: Gadget
+		metadata:
+		  name: gadget-one
+		---
+		apiVersion: v1
+		kind: ServiceAccount
+		metadata:
+		  name: sa-one
+		---
+		apiVersion: v1
+		kind: ConfigMap
+		metadata:
+		  name: config-one
+	`)[1:])
+	require.NoError(t, err)
+
+	sortByKind(objs)
+	require.Equal(
+		t,
+		[]string{"sa-one", "config-two", "config-one", "deploy-one", "gadget-one", "widget-one"},
+		lo.Map(objs, func(u *unstructured.Unstructured, _ int) string { return u.GetName() }),
+	)
+
+	groups := groupByKind(objs)
+	require.Equal(
+		t,
+		[]int{1, 2, 1, 1, 1},
+		lo.Map(groups, func(g []*unstructured.Unstructured, _ int) int { return len(g) }),
+	)
+}
@@ -0,0 +1,125 @@
+package goply
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+var (
+	// ErrInventoryNotFoundError is returned by InventoryStore.Load when nothing has been saved under the key
+	ErrInventoryNotFoundError = errors.New("inventory not found")
+	ErrNoInventoryKeyError    = errors.New("an inventory key is required")
+)
+
+// InventoryStore persists inventories between reconciles, keyed by a caller chosen name
+type InventoryStore interface {
+	Save(ctx context.Context, key string, inv Inventory) error
+	// Load returns ErrInventoryNotFoundError if nothing has been saved under key
+	Load(ctx context.Context, key string) (Inventory, error)
+}
+
+const configMapInventoryKey = "inventory"
+
+// ConfigMapInventoryStore stores each inventory as JSON in a ConfigMap named after its key
+type ConfigMapInventoryStore struct {
+	Client    client.Client
+	Namespace string
+}
+
+// NewConfigMapInventoryStore returns a store keeping inventories in ConfigMaps in namespace, which must already exist
+func NewConfigMapInventoryStore(c client.Client, namespace string) *ConfigMapInventoryStore {
+	return &ConfigMapInventoryStore{Client: c, Namespace: namespace}
+}
+
+func (s *ConfigMapInventoryStore) Save(ctx context.Context, key string, inv Inventory) error {
+	data, err := json.Marshal(inv.Items)
+	if err != nil {
+		return fmt.Errorf("error encoding inventory %v: %w", key, err)
+	}
+
+	cm := &corev1.ConfigMap{}
+	err = s.Client.Get(ctx, client.ObjectKey{Namespace: s.Namespace, Name: key}, cm)
+	if apierrors.IsNotFound(err) {
+		cm = &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Namespace: s.Namespace, Name: key},
+			Data:       map[string]string{configMapInventoryKey: string(data)},
+		}
+		if err := s.Client.Create(ctx, cm, client.FieldOwner(fieldManager)); err != nil {
+			return fmt.Errorf("error creating inventory %v: %w", key, err)
+		}
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("error getting inventory %v: %w", key, err)
+	}
+
+	if cm.Data == nil {
+		cm.Data = map[string]string{}
+	}
+	cm.Data[configMapInventoryKey] = string(data)
+	if err := s.Client.Update(ctx, cm, client.FieldOwner(fieldManager)); err != nil {
+		return fmt.Errorf("error updating inventory %v: %w", key, err)
+	}
+	return nil
+}
+
+func (s *ConfigMapInventoryStore) Load(ctx context.Context, key string) (Inventory, error) {
+	cm := &corev1.ConfigMap{}
+	err := s.Client.Get(ctx, client.ObjectKey{Namespace: s.Namespace, Name: key}, cm)
+	if apierrors.IsNotFound(err) {
+		return Inventory{}, fmt.Errorf("%w: %v", ErrInventoryNotFoundError, key)
+	}
+	if err != nil {
+		return Inventory{}, fmt.Errorf("error getting inventory %v: %w", key, err)
+	}
+
+	data, ok := cm.Data[configMapInventoryKey]
+	if !ok {
+		return Inventory{}, fmt.Errorf("%w: %v has no %v key", ErrInventoryNotFoundError, key, configMapInventoryKey)
+	}
+
+	inv := Inventory{}
+	if err := json.Unmarshal([]byte(data), &inv.Items); err != nil {
+		return Inventory{}, fmt.Errorf("error decoding inventory %v: %w", key, err)
+	}
+	return inv, nil
+}
+
+// inventoryStore returns the store opts asks for, nil if it doesn't use one. Setting only InventoryKey gets the
+// ConfigMap store
+func (r *Reconciler) inventoryStore(opts ApplyOpts) (InventoryStore, error) {
+	if opts.InventoryStore == nil && opts.InventoryKey == "" {
+		return nil, nil
+	}
+	if opts.InventoryKey == "" {
+		return nil, ErrNoInventoryKeyError
+	}
+	if opts.InventoryStore != nil {
+		return opts.InventoryStore, nil
+	}
+
+	namespace := opts.InventoryNamespace
+	if namespace == "" {
+		namespace = metav1.NamespaceDefault
+	}
+	return NewConfigMapInventoryStore(r.Client(), namespace), nil
+}
+
+// loadInventory loads the previous inventory from store, a missing one meaning there's nothing to prune
+func loadInventory(ctx context.Context, store InventoryStore, key string) (*Inventory, error) {
+	inv, err := store.Load(ctx, key)
+	if errors.Is(err, ErrInventoryNotFoundError) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error loading inventory: %w", err)
+	}
+	return &inv, nil
+}
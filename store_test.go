@@ -0,0 +1,84 @@
+package goply
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/cli-utils/pkg/object"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestConfigMapInventoryStore(t *testing.T) {
+	ctx := context.Background()
+	store := NewConfigMapInventoryStore(fake.NewClientBuilder().Build(), "goply-test")
+
+	_, err := store.Load(ctx, "app")
+	require.ErrorIs(t, err, ErrInventoryNotFoundError)
+
+	first := Inventory{Items: []InventoryItem{
+		{ObjMetadata: object.ObjMetadata{Namespace: "goply-test", Name: "config-one", GroupKind: schema.GroupKind{Kind: "ConfigMap"}}, GroupVersion: "v1"},
+	}}
+	require.NoError(t, store.Save(ctx, "app", first))
+
+	loaded, err := store.Load(ctx, "app")
+	require.NoError(t, err)
+	require.Equal(t, first, loaded)
+
+	// Saving again overwrites the existing ConfigMap
+	second := Inventory{Items: append(first.Items,
+		InventoryItem{ObjMetadata: object.ObjMetadata{Namespace: "goply-test", Name: "config-two", GroupKind: schema.GroupKind{Kind: "ConfigMap"}}, GroupVersion: "v1"},
+	)}
+	require.NoError(t, store.Save(ctx, "app", second))
+
+	loaded, err = store.Load(ctx, "app")
+	require.NoError(t, err)
+	require.Equal(t, second, loaded)
+}
+
+// countingStore counts how often it's used, holding a single inventory
+type countingStore struct {
+	inv          Inventory
+	loads, saves int
+}
+
+func (s *countingStore) Save(_ context.Context, _ string, inv Inventory) error {
+	s.saves++
+	s.inv = inv
+	return nil
+}
+
+func (s *countingStore) Load(_ context.Context, _ string) (Inventory, error) {
+	s.loads++
+	return s.inv, nil
+}
+
+func TestPartialApplyIgnoresStore(t *testing.T) {
+	r := offlineReconciler(t)
+	store := &countingStore{inv: Inventory{Items: []InventoryItem{
+		{ObjMetadata: object.ObjMetadata{Namespace: "default", Name: "config-two", GroupKind: schema.GroupKind{Kind: "ConfigMap"}}, GroupVersion: "v1"},
+	}}}
+	opts := ApplyOpts{InventoryKey: "app", InventoryStore: store, SkipWait: true}
+
+	objects, err := GetObjects(`
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: config-one
+  namespace: default
+`)
+	require.NoError(t, err)
+
+	// The cluster doesn't exist so the applies fail, but only after the store would have been loaded
+	_, _, err = r.ApplyObject(context.Background(), objects[0], opts)
+	require.Error(t, err)
+	_, err = r.ApplyObjects(objects, opts)
+	require.Error(t, err)
+	require.Zero(t, store.loads)
+	require.Zero(t, store.saves)
+
+	_, err = r.ReconcileObjects(objects, opts, nil)
+	require.Error(t, err)
+	require.Equal(t, 1, store.loads)
+}
@@ -0,0 +1,45 @@
+package goply
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+const (
+	EventReasonTakeOver = "TakeOver"
+)
+
+var (
+	ErrTakeOverNotConfirmedError = errors.New("take over not confirmed")
+	ErrTakeOverLabelsError       = errors.New("taking over needs labels to stamp, set Owner or ManagementLabels")
+)
+
+// validateTakeOver refuses a take over that hasn't been explicitly confirmed, or that wouldn't leave the objects labelled
+// as goply's
+func validateTakeOver(opts ApplyOpts) error {
+	if !opts.TakeOver {
+		return nil
+	}
+	if !opts.ConfirmTakeOver {
+		return fmt.Errorf("%w: TakeOver seizes every field in the manifest from whoever owns it, set ConfirmTakeOver as well", ErrTakeOverNotConfirmedError)
+	}
+	if opts.Owner == nil && len(opts.ManagementLabels) == 0 {
+		return ErrTakeOverLabelsError
+	}
+	return nil
+}
+
+// announceTakeOver makes sure a take over doesn't go unnoticed, with a warning event and a log line for every object
+func (r *Reconciler) announceTakeOver(ctx context.Context, objects []*unstructured.Unstructured) {
+	r.event(ctx, Event{
+		Type:    EventTypeWarning,
+		Reason:  EventReasonTakeOver,
+		Message: fmt.Sprintf("TAKING OVER %v objects, forcing ownership of every field in the manifest regardless of its current owner", len(objects)),
+	})
+	for _, obj := range objects {
+		r.log(ctx, "TAKING OVER object", objectKV(obj)...)
+	}
+}
@@ -0,0 +1,44 @@
+package goply
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestTakeOverRequiresConfirmation(t *testing.T) {
+	r := offlineReconciler(t)
+
+	_, err := r.Reconcile("", ApplyOpts{TakeOver: true, Owner: &Owner{Name: "app", Namespace: "default"}}, nil)
+	require.ErrorIs(t, err, ErrTakeOverNotConfirmedError)
+
+	_, err = r.Reconcile("", ApplyOpts{TakeOver: true, ConfirmTakeOver: true}, nil)
+	require.ErrorIs(t, err, ErrTakeOverLabelsError)
+
+	require.NoError(t, validateTakeOver(ApplyOpts{TakeOver: true, ConfirmTakeOver: true, ManagementLabels: map[string]string{"app": "goply"}}))
+	require.NoError(t, validateTakeOver(ApplyOpts{}))
+}
+
+func TestAnnounceTakeOver(t *testing.T) {
+	r := offlineReconciler(t)
+
+	events := []Event{}
+	r.SetEventFunc(func(e Event) { events = append(events, e) })
+	logs := []string{}
+	r.SetLogFunc(func(line string) { logs = append(logs, line) })
+
+	obj := &unstructured.Unstructured{}
+	obj.SetAPIVersion("v1")
+	obj.SetKind("ConfigMap")
+	obj.SetNamespace("default")
+	obj.SetName("config")
+	r.announceTakeOver(context.Background(), []*unstructured.Unstructured{obj})
+
+	require.Len(t, events, 1)
+	require.Equal(t, EventTypeWarning, events[0].Type)
+	require.Equal(t, EventReasonTakeOver, events[0].Reason)
+	require.Contains(t, events[0].Message, "TAKING OVER 1 objects")
+	require.Contains(t, logs, `TAKING OVER object apiVersion=v1 kind=ConfigMap namespace=default name=config`)
+}
@@ -0,0 +1,46 @@
+package goply
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// templateErrorRegex picks the line number out of text/template's "template: name:line:col: ..." errors
+var templateErrorRegex = regexp.MustCompile(`^template: [^:]*:(\d+)`)
+
+// RenderAndGetObjects executes tmpl as a text/template with data, failing on references to missing map keys, then
+// decodes the rendered output, ready to be passed to ApplyObjects/ReconcileObjects. Template errors are ParseErrors,
+// quoting the template line they refer to
+func RenderAndGetObjects(tmpl string, data any) ([]*unstructured.Unstructured, error) {
+	t, err := template.New("manifest").Option("missingkey=error").Parse(tmpl)
+	if err != nil {
+		return []*unstructured.Unstructured{}, &ParseError{err: fmt.Errorf("error parsing template: %w%v", err, templateLineContext(tmpl, err))}
+	}
+
+	rendered := strings.Builder{}
+	if err := t.Execute(&rendered, data); err != nil {
+		return []*unstructured.Unstructured{}, &ParseError{err: fmt.Errorf("error executing template: %w%v", err, templateLineContext(tmpl, err))}
+	}
+
+	return GetObjects(rendered.String())
+}
+
+// templateLineContext returns the template line err refers to, formatted to follow the error message, or "" if it
+// doesn't refer to one
+func templateLineContext(tmpl string, err error) string {
+	matches := templateErrorRegex.FindStringSubmatch(err.Error())
+	if matches == nil {
+		return ""
+	}
+	line, _ := strconv.Atoi(matches[1])
+	lines := strings.Split(tmpl, "\n")
+	if line < 1 || line > len(lines) {
+		return ""
+	}
+	return fmt.Sprintf("\n  %v | %v", line, lines[line-1])
+}
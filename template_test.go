@@ -0,0 +1,43 @@
+package goply
+
+import (
+	"testing"
+
+	"github.com/lithammer/dedent"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRenderAndGetObjects(t *testing.T) {
+	tmpl := dedent.Dedent(`
+		---
+		apiVersion: v1
+		kind: ConfigMap
+		metadata:
+		  name: {{ .Name }}
+		  namespace: goply-test
+		data:
+		{{- range $k, $v := .Data }}
+		  {{ $k }}: {{ $v | printf "%q" }}
+		{{- end }}
+	`)[1:]
+
+	objs, err := RenderAndGetObjects(tmpl, map[string]any{"Name": "config-one", "Data": map[string]string{"foo": "foo1"}})
+	require.NoError(t, err)
+	require.Len(t, objs, 1)
+	require.Equal(t, "config-one", objs[0].GetName())
+	require.Equal(t, map[string]any{"foo": "foo1"}, objs[0].Object["data"])
+
+	t.Run("execute error", func(t *testing.T) {
+		_, err := RenderAndGetObjects(tmpl, map[string]any{"Data": map[string]string{}})
+		var parseErr *ParseError
+		require.ErrorAs(t, err, &parseErr)
+		require.ErrorContains(t, err, "error executing template")
+		require.ErrorContains(t, err, "\n  5 |   name: {{ .Name }}")
+	})
+
+	t.Run("parse error", func(t *testing.T) {
+		_, err := RenderAndGetObjects("apiVersion: v1\nkind: {{ end }}\n", nil)
+		require.ErrorContains(t, err, "error parsing template")
+		require.ErrorContains(t, err, "\n  2 | kind: {{ end }}")
+	})
+}
@@ -0,0 +1,141 @@
+package goply
+
+import (
+	"fmt"
+	"strings"
+
+	ssautils "github.com/fluxcd/pkg/ssa/utils"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// Transformer changes an object from a manifest before goply does anything else with it, see
+// ReconcilerConfig.Transformers. Objects are changed in place
+type Transformer interface {
+	Transform(obj *unstructured.Unstructured) error
+}
+
+// TransformerFunc adapts a plain func to a Transformer
+type TransformerFunc func(obj *unstructured.Unstructured) error
+
+func (f TransformerFunc) Transform(obj *unstructured.Unstructured) error {
+	return f(obj)
+}
+
+// Chain combines transformers into one, running them in order and stopping at the first error
+func Chain(transformers ...Transformer) Transformer {
+	return TransformerFunc(func(obj *unstructured.Unstructured) error {
+		for _, t := range transformers {
+			if err := t.Transform(obj); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// transformObjects runs every transformer over every object, in order
+func transformObjects(objects []*unstructured.Unstructured, transformers []Transformer) error {
+	for _, obj := range objects {
+		for _, t := range transformers {
+			if err := t.Transform(obj); err != nil {
+				return fmt.Errorf("error transforming %v: %w", ssautils.FmtUnstructured(obj), err)
+			}
+		}
+	}
+	return nil
+}
+
+// CommonLabels sets labels on every object, on top of whatever labels it already has. Only the object's own labels are
+// set, not those of pod templates or selectors
+func CommonLabels(labels map[string]string) Transformer {
+	return TransformerFunc(func(obj *unstructured.Unstructured) error {
+		stampLabels([]*unstructured.Unstructured{obj}, labels)
+		return nil
+	})
+}
+
+// CommonAnnotations sets annotations on every object, on top of whatever annotations it already has
+func CommonAnnotations(annotations map[string]string) Transformer {
+	return TransformerFunc(func(obj *unstructured.Unstructured) error {
+		objAnnotations := obj.GetAnnotations()
+		if objAnnotations == nil {
+			objAnnotations = make(map[string]string, len(annotations))
+		}
+		for k, v := range annotations {
+			objAnnotations[k] = v
+		}
+		obj.SetAnnotations(objAnnotations)
+		return nil
+	})
+}
+
+// podSpecPaths are where the built-in workload kinds keep their pod spec
+var podSpecPaths = map[string][]string{
+	"Pod":                   {"spec"},
+	"Deployment":            {"spec", "template", "spec"},
+	"StatefulSet":           {"spec", "template", "spec"},
+	"DaemonSet":             {"spec", "template", "spec"},
+	"ReplicaSet":            {"spec", "template", "spec"},
+	"ReplicationController": {"spec", "template", "spec"},
+	"Job":                   {"spec", "template", "spec"},
+	"CronJob":               {"spec", "jobTemplate", "spec", "template", "spec"},
+}
+
+// RegistryRewrite points every container image pulled from registry at replacement instead, e.g. docker.io to
+// mirror.example.com/docker.io. Images that don't name a registry, like nginx or library/nginx, are matched as the
+// docker.io/library/nginx the container runtime would pull. Only the built-in workload kinds are rewritten, images in
+// custom resources are left alone
+func RegistryRewrite(registry string, replacement string) Transformer {
+	prefix := strings.TrimSuffix(registry, "/") + "/"
+	replacementPrefix := strings.TrimSuffix(replacement, "/") + "/"
+
+	return TransformerFunc(func(obj *unstructured.Unstructured) error {
+		specPath, ok := podSpecPaths[obj.GetKind()]
+		if !ok {
+			return nil
+		}
+
+		for _, field := range []string{"initContainers", "containers", "ephemeralContainers"} {
+			containersPath := append(append([]string{}, specPath...), field)
+			containers, found, err := unstructured.NestedSlice(obj.Object, containersPath...)
+			if err != nil {
+				return err
+			}
+			if !found {
+				continue
+			}
+
+			for _, c := range containers {
+				container, ok := c.(map[string]any)
+				if !ok {
+					continue
+				}
+				image, ok := container["image"].(string)
+				if !ok {
+					continue
+				}
+				if qualified := qualifyImage(image); strings.HasPrefix(qualified, prefix) {
+					container["image"] = replacementPrefix + strings.TrimPrefix(qualified, prefix)
+				}
+			}
+			if err := unstructured.SetNestedSlice(obj.Object, containers, containersPath...); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// qualifyImage fills in the registry of an image reference that doesn't name one, the same way the container runtime
+// does, so nginx and library/nginx are both docker.io/library/nginx
+func qualifyImage(image string) string {
+	first, _, found := strings.Cut(image, "/")
+	switch {
+	case !found:
+		return "docker.io/library/" + image
+	case strings.ContainsAny(first, ".:") || first == "localhost":
+		return image
+	default:
+		return "docker.io/" + image
+	}
+}
@@ -0,0 +1,138 @@
+package goply
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestRegistryRewrite(t *testing.T) {
+	objects, err := GetObjects(`
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  namespace: default
+spec:
+  template:
+    spec:
+      initContainers:
+        - name: init
+          image: docker.io/library/busybox:1.36
+      containers:
+        - name: web
+          image: docker.io/library/nginx:1.27
+        - name: sidecar
+          image: ghcr.io/example/sidecar:v1
+        - name: unqualified
+          image: redis:7
+        - name: library
+          image: library/postgres:16
+        - name: user
+          image: bitnami/kubectl:1.31
+        - name: local
+          image: localhost:5000/tools:v1
+---
+apiVersion: batch/v1
+kind: CronJob
+metadata:
+  name: nightly
+  namespace: default
+spec:
+  schedule: "0 0 * * *"
+  jobTemplate:
+    spec:
+      template:
+        spec:
+          containers:
+            - name: job
+              image: docker.io/library/alpine:3.20
+---
+apiVersion: example.com/v1
+kind: Widget
+metadata:
+  name: widget
+  namespace: default
+spec:
+  containers:
+    - name: widget
+      image: docker.io/library/nginx:1.27
+`)
+	require.NoError(t, err)
+
+	rewrite := RegistryRewrite("docker.io", "mirror.example.com/docker.io/")
+	for _, obj := range objects {
+		require.NoError(t, rewrite.Transform(obj))
+	}
+
+	images := func(obj *unstructured.Unstructured, path ...string) []string {
+		containers, _, err := unstructured.NestedSlice(obj.Object, path...)
+		require.NoError(t, err)
+		found := []string{}
+		for _, c := range containers {
+			found = append(found, c.(map[string]any)["image"].(string))
+		}
+		return found
+	}
+
+	require.Equal(t, []string{"mirror.example.com/docker.io/library/busybox:1.36"}, images(objects[0], "spec", "template", "spec", "initContainers"))
+	require.Equal(t, []string{
+		"mirror.example.com/docker.io/library/nginx:1.27",
+		"ghcr.io/example/sidecar:v1",
+		// Images without a registry come from Docker Hub
+		"mirror.example.com/docker.io/library/redis:7",
+		"mirror.example.com/docker.io/library/postgres:16",
+		"mirror.example.com/docker.io/bitnami/kubectl:1.31",
+		"localhost:5000/tools:v1",
+	}, images(objects[0], "spec", "template", "spec", "containers"))
+	require.Equal(t, []string{"mirror.example.com/docker.io/library/alpine:3.20"}, images(objects[1], "spec", "jobTemplate", "spec", "template", "spec", "containers"))
+	// Custom resources are left alone
+	require.Equal(t, []string{"docker.io/library/nginx:1.27"}, images(objects[2], "spec", "containers"))
+}
+
+func TestChain(t *testing.T) {
+	obj := &unstructured.Unstructured{}
+	obj.SetAPIVersion("v1")
+	obj.SetKind("ConfigMap")
+	obj.SetName("config-one")
+	obj.SetLabels(map[string]string{"app": "config"})
+
+	failing := errors.New("nope")
+	chain := Chain(
+		CommonLabels(map[string]string{"team": "one"}),
+		CommonLabels(map[string]string{"team": "two"}),
+		CommonAnnotations(map[string]string{"owner": "platform"}),
+	)
+	require.NoError(t, chain.Transform(obj))
+	require.Equal(t, map[string]string{"app": "config", "team": "two"}, obj.GetLabels())
+	require.Equal(t, map[string]string{"owner": "platform"}, obj.GetAnnotations())
+
+	ran := false
+	chain = Chain(
+		TransformerFunc(func(*unstructured.Unstructured) error { return failing }),
+		TransformerFunc(func(*unstructured.Unstructured) error { ran = true; return nil }),
+	)
+	require.ErrorIs(t, chain.Transform(obj), failing)
+	require.False(t, ran)
+}
+
+func TestStagesTransformed(t *testing.T) {
+	r, err := NewReconciler(&ReconcilerConfig{
+		Kubeconfig:   offlineKubeconfig,
+		Transformers: []Transformer{CommonLabels(map[string]string{"team": "one"})},
+	})
+	require.NoError(t, err)
+
+	stages, err := r.Stages(`
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: config-one
+  namespace: default
+`)
+	require.NoError(t, err)
+	require.Len(t, stages.StageTwo, 1)
+	require.Equal(t, map[string]string{"team": "one"}, stages.StageTwo[0].GetLabels())
+}
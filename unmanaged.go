@@ -0,0 +1,67 @@
+package goply
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/samber/lo"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/cli-utils/pkg/object"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// FindUnmanaged lists every object in namespace that goply neither applied nor tracks: it isn't in inv, doesn't carry
+// goply's owner labels or all of managementLabels (see ApplyOpts.ManagementLabels, nil if unused), and goply's field
+// manager has never applied it. Left out as well are objects with a controller
+// (a Deployment's ReplicaSets and Pods, say), which belong to whatever their controller belongs to, what Kubernetes
+// creates in every namespace by itself, and anything matching ReconcilerConfig.IgnorePatterns
+func (r *Reconciler) FindUnmanaged(ctx context.Context, namespace string, inv Inventory, managementLabels map[string]string) ([]InventoryItem, error) {
+	resources, err := r.listableResources()
+	if err != nil {
+		return nil, err
+	}
+	resources = lo.Filter(resources, func(l listableResource, _ int) bool { return l.Namespaced })
+
+	ownerLabels := lo.Keys(r.mgr.GetOwnerLabels("", ""))
+	tracked := inv.Index()
+	unmanaged := []InventoryItem{}
+	for _, resource := range resources {
+		list := &metav1.PartialObjectMetadataList{}
+		list.SetGroupVersionKind(resource.listGVK())
+		if err := r.mgr.Client().List(ctx, list, client.InNamespace(namespace)); err != nil {
+			return nil, fmt.Errorf("error listing %v in %v: %w", resource.GroupVersionKind, namespace, err)
+		}
+
+		for _, live := range list.Items {
+			item := InventoryItem{
+				ObjMetadata: object.ObjMetadata{
+					Namespace: live.Namespace,
+					Name:      live.Name,
+					GroupKind: resource.GroupKind(),
+				},
+				GroupVersion: resource.Version,
+			}
+
+			managed := tracked.Contains(item.ObjMetadata) || appliedByGoply(live.ManagedFields) ||
+				lo.SomeBy(ownerLabels, func(key string) bool { return lo.HasKey(live.Labels, key) }) ||
+				len(managementLabels) > 0 && hasLabels(live.Labels, managementLabels)
+			if managed || metav1.GetControllerOfNoCopy(&live) != nil || isNamespaceDefault(item.GroupKind, item.Name) ||
+				r.isIgnored(item.GroupKind, item.Namespace, item.Name, live.Labels) {
+				continue
+			}
+			unmanaged = append(unmanaged, item)
+		}
+	}
+
+	return unmanaged, nil
+}
+
+// hasLabels reports whether labels holds every one of want
+func hasLabels(labels map[string]string, want map[string]string) bool {
+	for k, v := range want {
+		if labels[k] != v {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,65 @@
+package goply
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	ssautils "github.com/fluxcd/pkg/ssa/utils"
+	"github.com/samber/lo"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/cli-utils/pkg/object"
+)
+
+var ErrInvalidManifestError = errors.New("invalid manifest")
+
+// ValidateYAML checks yaml the same way goply would before applying it, without contacting a cluster. Every document
+// must decode, have an apiVersion, kind, and name, and normalize cleanly. Empty documents are skipped, as they are
+// when reading. No two documents may describe the same object. Every problem found is reported, not just the first
+func ValidateYAML(yaml string) error {
+	objects, err := decodeObjects(strings.NewReader(yaml))
+	if err != nil {
+		return fmt.Errorf("%w: error decoding yaml: %v", ErrInvalidManifestError, err)
+	}
+	// Empty documents, such as a trailing --- or one holding nothing but comments, are skipped when reading too
+	objects = lo.Reject(objects, func(obj *unstructured.Unstructured, _ int) bool { return len(obj.Object) == 0 })
+
+	problems := []error{}
+	seen := map[object.ObjMetadata]int{}
+	for idx, obj := range objects {
+		if ssautils.IsKustomization(obj) {
+			continue
+		}
+
+		missing := []string{}
+		if obj.GetAPIVersion() == "" {
+			missing = append(missing, "apiVersion")
+		}
+		if obj.GetKind() == "" {
+			missing = append(missing, "kind")
+		}
+		if obj.GetName() == "" {
+			missing = append(missing, "metadata.name")
+		}
+		if len(missing) > 0 {
+			problems = append(problems, fmt.Errorf("object %v is missing %v", idx, strings.Join(missing, ", ")))
+			continue
+		}
+
+		id := object.UnstructuredToObjMetadata(obj)
+		if first, ok := seen[id]; ok {
+			problems = append(problems, fmt.Errorf("object %v, %v, duplicates object %v", idx, ssautils.FmtUnstructured(obj), first))
+			continue
+		}
+		seen[id] = idx
+
+		if err := normalizeObject(obj.DeepCopy()); err != nil {
+			problems = append(problems, fmt.Errorf("object %v, %v, is malformed: %w", idx, ssautils.FmtUnstructured(obj), err))
+		}
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("%w: %w", ErrInvalidManifestError, errors.Join(problems...))
+	}
+	return nil
+}
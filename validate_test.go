@@ -0,0 +1,78 @@
+package goply
+
+import (
+	"testing"
+
+	"github.com/lithammer/dedent"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateYAML(t *testing.T) {
+	t.Run("valid", func(t *testing.T) {
+		require.NoError(t, ValidateYAML(dedent.Dedent(`
+			---
+			apiVersion: v1
+			kind: Namespace
+			metadata:
+			  name: goply-test
+			---
+			apiVersion: v1
+			kind: ConfigMap
+			metadata:
+			  name: config-one
+			  namespace: goply-test
+		`)[1:]))
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		err := ValidateYAML(dedent.Dedent(`
+			---
+			apiVersion: v1
+			kind: ConfigMap
+			metadata:
+			  name: config-one
+			  namespace: goply-test
+			---
+			apiVersion: v1
+			kind: ConfigMap
+			metadata:
+			  name: config-one
+			  namespace: goply-test
+			---
+			kind: ConfigMap
+			metadata:
+			  generateName: config-
+			---
+			apiVersion: apps/v1
+			kind: Deployment
+			metadata:
+			  name: deploy-one
+			  namespace: goply-test
+			spec:
+			  replicas: lots
+		`)[1:])
+		require.ErrorIs(t, err, ErrInvalidManifestError)
+		require.ErrorContains(t, err, "object 1, ConfigMap/goply-test/config-one, duplicates object 0")
+		require.ErrorContains(t, err, "object 2 is missing apiVersion, metadata.name")
+		require.ErrorContains(t, err, "object 3, Deployment/goply-test/deploy-one, is malformed")
+	})
+
+	t.Run("empty documents", func(t *testing.T) {
+		require.NoError(t, ValidateYAML(dedent.Dedent(`
+			---
+			# nothing but a comment
+			---
+			apiVersion: v1
+			kind: ConfigMap
+			metadata:
+			  name: config-one
+			  namespace: goply-test
+			---
+			---
+		`)[1:]))
+	})
+
+	t.Run("undecodable", func(t *testing.T) {
+		require.ErrorIs(t, ValidateYAML("foo: [bar"), ErrInvalidManifestError)
+	})
+}
@@ -0,0 +1,143 @@
+package goply
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+
+	ssautils "github.com/fluxcd/pkg/ssa/utils"
+	"github.com/samber/lo"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/cli-utils/pkg/object"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Discrepancy is a field whose value as persisted by the API server differs from what goply applied. A nil Actual
+// means the field was dropped entirely
+type Discrepancy struct {
+	InventoryItem
+	Path     string
+	Expected any
+	Actual   any
+}
+
+func (d Discrepancy) String() string {
+	if d.Actual == nil {
+		return fmt.Sprintf("%v %v: expected %v, but field is missing", d.ID(), d.Path, d.Expected)
+	}
+	return fmt.Sprintf("%v %v: expected %v, got %v", d.ID(), d.Path, d.Expected, d.Actual)
+}
+
+// verifyReadback compares each object to what the API server persisted, leaving out the skipped ones, which were never
+// sent for it to persist
+func (r *Reconciler) verifyReadback(ctx context.Context, objects []*unstructured.Unstructured, skipped []SkippedItem) ([]Discrepancy, error) {
+	discrepancies := []Discrepancy{}
+	notApplied := Inventory{Items: lo.Map(skipped, func(s SkippedItem, _ int) InventoryItem { return s.InventoryItem })}.Index()
+
+	for _, obj := range objects {
+		if notApplied.Contains(object.UnstructuredToObjMetadata(obj)) {
+			continue
+		}
+		live := &unstructured.Unstructured{}
+		live.SetGroupVersionKind(obj.GroupVersionKind())
+		if err := r.mgr.Client().Get(ctx, client.ObjectKeyFromObject(obj), live); err != nil {
+			return nil, fmt.Errorf("error reading back %v: %w", ssautils.FmtUnstructured(obj), err)
+		}
+
+		discrepancies = append(discrepancies, compareReadback(obj, live)...)
+	}
+
+	return discrepancies, nil
+}
+
+// compareReadback walks every leaf of desired and checks the live object holds the same value there. Fields only
+// present on the live object are assumed to be server side defaults and ignored
+func compareReadback(desired *unstructured.Unstructured, live *unstructured.Unstructured) []Discrepancy {
+	item := toInventoryItem(desired)
+	discrepancies := []Discrepancy{}
+
+	for key, val := range desired.Object {
+		switch key {
+		case "apiVersion", "kind", "status":
+			continue
+		case "stringData":
+			// Folded into .data by the API server, never persisted as-is
+			if ssautils.IsSecret(desired) {
+				continue
+			}
+		case "metadata":
+			for _, metaKey := range []string{"labels", "annotations"} {
+				desiredMeta, ok := val.(map[string]any)[metaKey]
+				if !ok {
+					continue
+				}
+				liveMeta, _, _ := unstructured.NestedFieldNoCopy(live.Object, "metadata", metaKey)
+				discrepancies = append(discrepancies, compareValue(item, ".metadata."+metaKey, desiredMeta, liveMeta)...)
+			}
+			continue
+		}
+
+		discrepancies = append(discrepancies, compareValue(item, "."+key, val, live.Object[key])...)
+	}
+
+	sort.Slice(discrepancies, func(i, j int) bool { return discrepancies[i].Path < discrepancies[j].Path })
+
+	return discrepancies
+}
+
+func compareValue(item InventoryItem, path string, desired any, live any) []Discrepancy {
+	switch d := desired.(type) {
+	case map[string]any:
+		l, ok := live.(map[string]any)
+		if !ok {
+			return []Discrepancy{{InventoryItem: item, Path: path, Expected: desired, Actual: live}}
+		}
+		discrepancies := []Discrepancy{}
+		for key, val := range d {
+			discrepancies = append(discrepancies, compareValue(item, path+"."+key, val, l[key])...)
+		}
+		return discrepancies
+	case []any:
+		l, ok := live.([]any)
+		if !ok {
+			return []Discrepancy{{InventoryItem: item, Path: path, Expected: desired, Actual: live}}
+		}
+		discrepancies := []Discrepancy{}
+		for idx, val := range d {
+			var liveVal any
+			if idx < len(l) {
+				liveVal = l[idx]
+			}
+			discrepancies = append(discrepancies, compareValue(item, fmt.Sprintf("%v[%v]", path, idx), val, liveVal)...)
+		}
+		return discrepancies
+	}
+
+	if desired == nil || equivalentScalars(desired, live) {
+		return []Discrepancy{}
+	}
+
+	return []Discrepancy{{InventoryItem: item, Path: path, Expected: desired, Actual: live}}
+}
+
+func equivalentScalars(desired any, live any) bool {
+	if live == nil {
+		return false
+	}
+	if reflect.DeepEqual(desired, live) || fmt.Sprint(desired) == fmt.Sprint(live) {
+		return true
+	}
+
+	// Quantities get canonicalized on the way in, "1000m" comes back as "1"
+	desiredQty, err := resource.ParseQuantity(fmt.Sprint(desired))
+	if err != nil {
+		return false
+	}
+	liveQty, err := resource.ParseQuantity(fmt.Sprint(live))
+	if err != nil {
+		return false
+	}
+	return desiredQty.Cmp(liveQty) == 0
+}
@@ -0,0 +1,112 @@
+package goply
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/lithammer/dedent"
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompareReadback(t *testing.T) {
+	objs, err := GetObjects(dedent.Dedent(`
+		---
+		apiVersion: apps/v1
+		kind: Deployment
+		metadata:
+		  name: deploy-one
+		  namespace: goply-test
+		  labels:
+		    app: deploy-one
+		spec:
+		  replicas: 2
+		  template:
+		    spec:
+		      containers:
+		      - name: main
+		        image: nginx:latest
+		        resources:
+		          requests:
+		            cpu: 1000m
+		---
+		apiVersion: apps/v1
+		kind: Deployment
+		metadata:
+		  name: deploy-one
+		  namespace: goply-test
+		  uid: abc-123
+		  labels:
+		    app: deploy-one
+		    injected: "true"
+		spec:
+		  replicas: 2
+		  revisionHistoryLimit: 10
+		  template:
+		    spec:
+		      containers:
+		      - name: main
+		        image: registry.example.com/nginx:latest
+		        resources:
+		          requests:
+		            cpu: "1"
+		status:
+		  replicas: 2
+	`)[1:])
+	require.NoError(t, err)
+	require.Len(t, objs, 2)
+
+	discrepancies := compareReadback(objs[0], objs[1])
+	require.Equal(
+		t,
+		[]string{".spec.template.spec.containers[0].image"},
+		lo.Map(discrepancies, func(d Discrepancy, _ int) string { return d.Path }),
+	)
+	require.Equal(t, "nginx:latest", discrepancies[0].Expected)
+	require.Equal(t, "registry.example.com/nginx:latest", discrepancies[0].Actual)
+
+	t.Run("dropped field", func(t *testing.T) {
+		live := objs[0].DeepCopy()
+		delete(live.Object["metadata"].(map[string]any)["labels"].(map[string]any), "app")
+
+		discrepancies := compareReadback(objs[0], live)
+		require.Len(t, discrepancies, 1)
+		require.Equal(t, ".metadata.labels.app", discrepancies[0].Path)
+		require.Nil(t, discrepancies[0].Actual)
+	})
+}
+
+func TestVerifyReadbackSkipsCreateOnly(t *testing.T) {
+	r, err := NewReconciler(&ReconcilerConfig{
+		Kubeconfig: offlineKubeconfig,
+		WrapTransport: func(http.RoundTripper) http.RoundTripper {
+			// config-one already exists, holding someone else's data
+			return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+				if body, ok := coreDiscovery[req.URL.Path]; ok {
+					return jsonResponse(req, http.StatusOK, body), nil
+				}
+				if req.Method == http.MethodGet && req.URL.Path == "/api/v1/namespaces/goply-test/configmaps/config-one" {
+					return jsonResponse(req, http.StatusOK, `{"kind":"ConfigMap","apiVersion":"v1",`+
+						`"metadata":{"name":"config-one","namespace":"goply-test","resourceVersion":"1"},"data":{"foo":"someone-else"}}`), nil
+				}
+				return nil, fmt.Errorf("unexpected request %v %v", req.Method, req.URL.Path)
+			})
+		},
+	})
+	require.NoError(t, err)
+
+	result, err := r.Apply(dedent.Dedent(`
+		---
+		apiVersion: v1
+		kind: ConfigMap
+		metadata:
+		  name: config-one
+		  namespace: goply-test
+		data:
+		  foo: foo1
+	`)[1:], ApplyOpts{CreateOnly: true, VerifyReadback: true, SkipWait: true})
+	require.NoError(t, err)
+	require.Equal(t, []string{"config-one"}, lo.Map(result.Skipped, func(s SkippedItem, _ int) string { return s.Name }))
+	require.Empty(t, result.Discrepancies)
+}
@@ -0,0 +1,407 @@
+package goply
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/fluxcd/cli-utils/pkg/kstatus/polling"
+	"github.com/fluxcd/cli-utils/pkg/kstatus/polling/aggregator"
+	"github.com/fluxcd/cli-utils/pkg/kstatus/polling/collector"
+	"github.com/fluxcd/cli-utils/pkg/kstatus/polling/event"
+	"github.com/fluxcd/cli-utils/pkg/kstatus/status"
+	fluxobject "github.com/fluxcd/cli-utils/pkg/object"
+	"github.com/fluxcd/pkg/ssa"
+	"github.com/samber/lo"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/cli-utils/pkg/object"
+)
+
+const (
+	EventReasonWaitProgress = "WaitProgress"
+)
+
+// ObjectStatus is the last status the poller observed for an object
+type ObjectStatus struct {
+	object.ObjMetadata
+	Status  status.Status
+	Message string
+}
+
+func (o ObjectStatus) String() string {
+	if o.Message == "" {
+		return fmt.Sprintf("%v status: '%v'", o.ObjMetadata, o.Status)
+	}
+	return fmt.Sprintf("%v status: '%v': %v", o.ObjMetadata, o.Status, o.Message)
+}
+
+// WaitTimeoutError is returned when objects fail to become ready within the wait timeout, and lists the objects that
+// were still not ready
+type WaitTimeoutError struct {
+	Objects []ObjectStatus
+}
+
+func (e *WaitTimeoutError) Error() string {
+	return fmt.Sprintf(
+		"timed out waiting for objects to reconcile: [%v]",
+		strings.Join(lo.Map(e.Objects, func(o ObjectStatus, _ int) string { return o.String() }), ", "),
+	)
+}
+
+// WaitBackoff makes the wait poll with an exponentially growing interval instead of a fixed one: it starts at Initial
+// and is multiplied by Factor after every poll, up to Max. Zero values default to 500ms, 30s, and 2 respectively
+type WaitBackoff struct {
+	Initial time.Duration
+	Max     time.Duration
+	Factor  float64
+}
+
+func (b WaitBackoff) withDefaults() WaitBackoff {
+	if b.Initial <= 0 {
+		b.Initial = 500 * time.Millisecond
+	}
+	if b.Max <= 0 {
+		b.Max = 30 * time.Second
+	}
+	if b.Factor <= 1 {
+		b.Factor = 2
+	}
+	return b
+}
+
+func (b WaitBackoff) next(interval time.Duration) time.Duration {
+	return min(time.Duration(float64(interval)*b.Factor), b.Max)
+}
+
+// readiness records how long after the start of a wait each object was first seen to be current, reporting it to
+// progress as well if that's set
+type readiness struct {
+	start time.Time
+	after map[object.ObjMetadata]time.Duration
+
+	stage    string
+	items    map[object.ObjMetadata]InventoryItem
+	progress func(ProgressUpdate)
+}
+
+func (r *readiness) observe(rs *event.ResourceStatus) {
+	if r == nil || rs.Status != status.CurrentStatus {
+		return
+	}
+	id := fromFluxObjMetadata(rs.Identifier)
+	if _, ok := r.after[id]; ok {
+		return
+	}
+	r.after[id] = time.Since(r.start)
+
+	if r.progress != nil {
+		item, ok := r.items[id]
+		if !ok {
+			item = InventoryItem{ObjMetadata: id}
+		}
+		r.progress(ProgressUpdate{InventoryItem: item, Stage: r.stage, Phase: ProgressReady, ReadyAfter: r.after[id]})
+	}
+}
+
+// criticalObjects returns the objects the stage two wait should block on, see ApplyOpts.WaitForLabels
+func criticalObjects(objects []*unstructured.Unstructured, opts ApplyOpts) []*unstructured.Unstructured {
+	if len(opts.WaitForLabels) == 0 && len(opts.CriticalKinds) == 0 {
+		return objects
+	}
+
+	return lo.Filter(objects, func(obj *unstructured.Unstructured, _ int) bool {
+		if lo.Contains(opts.CriticalKinds, obj.GroupVersionKind().GroupKind()) {
+			return true
+		}
+		if len(opts.WaitForLabels) == 0 {
+			return false
+		}
+		labels := obj.GetLabels()
+		return lo.EveryBy(lo.Entries(opts.WaitForLabels), func(e lo.Entry[string, string]) bool {
+			value, ok := labels[e.Key]
+			return ok && value == e.Value
+		})
+	})
+}
+
+// wait is equivalent to ssa.ResourceManager.Wait, but keeps the per-object status around so it can be reported in a
+// structured fashion. When applyOpts.WaitBackoff is set, it's used instead of opts.Interval. When
+// applyOpts.WaitBatchSize is set, objects are waited on in batches of that size, one after the other, with
+// opts.Timeout covering all of them. How long each object took to become ready is recorded in result.ReadyAfter, if
+// result is given
+func (r *Reconciler) wait(ctx context.Context, objects []*unstructured.Unstructured, opts ssa.WaitOptions, applyOpts ApplyOpts, result *Result) error {
+	var readyTimes *readiness
+	if result != nil {
+		if result.ReadyAfter == nil {
+			result.ReadyAfter = map[object.ObjMetadata]time.Duration{}
+		}
+		readyTimes = &readiness{start: time.Now(), after: result.ReadyAfter}
+		if applyOpts.ObjectProgress != nil {
+			readyTimes.stage = stageFrom(ctx)
+			readyTimes.progress = applyOpts.ObjectProgress
+			readyTimes.items = lo.SliceToMap(objects, func(obj *unstructured.Unstructured) (object.ObjMetadata, InventoryItem) {
+				item := toInventoryItem(obj)
+				return item.ObjMetadata, item
+			})
+		}
+	}
+
+	if applyOpts.WaitBatchSize <= 0 || len(objects) <= applyOpts.WaitBatchSize {
+		return r.waitSet(ctx, objects, opts, applyOpts, readyTimes)
+	}
+
+	deadline := time.Now().Add(opts.Timeout)
+	batches := lo.Chunk(objects, applyOpts.WaitBatchSize)
+	ready := 0
+	for idx, batch := range batches {
+		batchOpts := opts
+		batchOpts.Timeout = time.Until(deadline)
+
+		err := r.waitSet(ctx, batch, batchOpts, applyOpts, readyTimes)
+		var timeoutErr *WaitTimeoutError
+		if errors.As(err, &timeoutErr) {
+			// The later batches never got a look in
+			for _, obj := range lo.Flatten(batches[idx+1:]) {
+				timeoutErr.Objects = append(timeoutErr.Objects, ObjectStatus{
+					ObjMetadata: toInventoryItem(obj).ObjMetadata,
+					Status:      status.UnknownStatus,
+					Message:     "timed out before it was waited on",
+				})
+			}
+		}
+		if err != nil {
+			return err
+		}
+
+		ready += len(batch)
+		r.event(ctx, Event{
+			Type:    EventTypeNormal,
+			Reason:  EventReasonWaitProgress,
+			Message: fmt.Sprintf("%v of %v objects reconciled", ready, len(objects)),
+		})
+	}
+
+	return nil
+}
+
+// waitSet waits for every object at once
+func (r *Reconciler) waitSet(ctx context.Context, objects []*unstructured.Unstructured, opts ssa.WaitOptions, applyOpts ApplyOpts, ready *readiness) error {
+	set := fluxobject.UnstructuredSetToObjMetadataSet(objects)
+	if len(set) == 0 {
+		return nil
+	}
+
+	lastStatus := make(map[fluxobject.ObjMetadata]*event.ResourceStatus)
+	if opts.Timeout <= 0 {
+		return waitTimeoutError(set, lastStatus)
+	}
+
+	parent := ctx
+	ctx, cancel := context.WithTimeout(ctx, opts.Timeout)
+	defer cancel()
+
+	checks := []func(*event.ResourceStatus) *event.ResourceStatus{}
+	if !applyOpts.SkipRolloutCheck {
+		checks = append(checks, checkRollout)
+	}
+	if applyOpts.RequireObservedGeneration {
+		checks = append(checks, checkObservedGeneration)
+	}
+	check := func(rs *event.ResourceStatus) *event.ResourceStatus {
+		for _, c := range checks {
+			rs = c(rs)
+		}
+		return rs
+	}
+
+	var err error
+	if applyOpts.WaitBackoff == nil {
+		err = r.waitInterval(ctx, cancel, set, opts.Interval, check, lastStatus, ready)
+	} else {
+		err = r.waitBackoff(ctx, set, applyOpts.WaitBackoff.withDefaults(), check, lastStatus, ready)
+	}
+	// The reconcile itself being cancelled isn't the wait succeeding, nor timing out
+	if err := checkpoint(parent, nil); err != nil {
+		return err
+	}
+	if err != nil {
+		return err
+	}
+
+	if !errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return nil
+	}
+
+	return waitTimeoutError(set, lastStatus)
+}
+
+// waitInterval polls set every interval, calling cancel once everything is current
+func (r *Reconciler) waitInterval(
+	ctx context.Context,
+	cancel context.CancelFunc,
+	set fluxobject.ObjMetadataSet,
+	interval time.Duration,
+	check func(*event.ResourceStatus) *event.ResourceStatus,
+	lastStatus map[fluxobject.ObjMetadata]*event.ResourceStatus,
+	ready *readiness,
+) error {
+	statusCollector := collector.NewResourceStatusCollector(set)
+
+	eventsChan := r.poller.Poll(ctx, set, polling.PollOptions{PollInterval: interval})
+
+	done := statusCollector.ListenWithObserver(eventsChan, collector.ObserverFunc(
+		func(statusCollector *collector.ResourceStatusCollector, _ event.Event) {
+			var rss []*event.ResourceStatus
+			for _, rs := range statusCollector.ResourceStatuses {
+				if rs == nil {
+					continue
+				}
+				rs = check(rs)
+				recordStatus(lastStatus, rs)
+				ready.observe(rs)
+				rss = append(rss, rs)
+			}
+
+			if aggregator.AggregateStatus(rss, status.CurrentStatus) == status.CurrentStatus {
+				cancel()
+			}
+		}),
+	)
+
+	<-done
+
+	return statusCollector.Error
+}
+
+// waitBackoff polls set in rounds, sleeping for a growing interval between them, until everything is current or ctx
+// is done
+func (r *Reconciler) waitBackoff(
+	ctx context.Context,
+	set fluxobject.ObjMetadataSet,
+	backoff WaitBackoff,
+	check func(*event.ResourceStatus) *event.ResourceStatus,
+	lastStatus map[fluxobject.ObjMetadata]*event.ResourceStatus,
+	ready *readiness,
+) error {
+	interval := backoff.Initial
+	for {
+		statuses, err := r.pollOnce(ctx, set)
+		if err != nil {
+			return err
+		}
+		for id, rs := range statuses {
+			statuses[id] = check(rs)
+			recordStatus(lastStatus, statuses[id])
+			ready.observe(statuses[id])
+		}
+
+		if len(statuses) == len(set) && aggregator.AggregateStatus(lo.Values(statuses), status.CurrentStatus) == status.CurrentStatus {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(interval):
+		}
+		interval = backoff.next(interval)
+	}
+}
+
+// pollOnce reads the status of every object in set a single time
+func (r *Reconciler) pollOnce(ctx context.Context, set fluxobject.ObjMetadataSet) (map[fluxobject.ObjMetadata]*event.ResourceStatus, error) {
+	roundCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// The poller reads every object immediately, and the round is cancelled as soon as that's done, so the interval
+	// never comes into play
+	eventsChan := r.poller.Poll(roundCtx, set, polling.PollOptions{PollInterval: time.Hour})
+
+	statuses := make(map[fluxobject.ObjMetadata]*event.ResourceStatus, len(set))
+	var err error
+	for e := range eventsChan {
+		switch e.Type {
+		case event.ErrorEvent:
+			err = e.Error
+			cancel()
+		case event.ResourceUpdateEvent:
+			statuses[e.Resource.Identifier] = e.Resource
+			if len(statuses) == len(set) {
+				cancel()
+			}
+		}
+	}
+
+	return statuses, err
+}
+
+// checkObservedGeneration downgrades a current status to in progress when the object's controller hasn't yet observed
+// its latest generation. Objects that don't report an observedGeneration are left as they are
+func checkObservedGeneration(rs *event.ResourceStatus) *event.ResourceStatus {
+	if rs.Status != status.CurrentStatus || rs.Resource == nil {
+		return rs
+	}
+
+	observed, found, err := unstructured.NestedInt64(rs.Resource.Object, "status", "observedGeneration")
+	if !found || err != nil {
+		return rs
+	}
+	generation := rs.Resource.GetGeneration()
+	if observed >= generation {
+		return rs
+	}
+
+	lagging := *rs
+	lagging.Status = status.InProgressStatus
+	lagging.Message = fmt.Sprintf("observedGeneration %v is behind generation %v", observed, generation)
+	return &lagging
+}
+
+func recordStatus(lastStatus map[fluxobject.ObjMetadata]*event.ResourceStatus, rs *event.ResourceStatus) {
+	// kstatus emits a DeadlineExceeded error for every resource once the timeout hits, which would clobber the last
+	// real status we saw
+	if !errors.Is(rs.Error, context.DeadlineExceeded) {
+		lastStatus[rs.Identifier] = rs
+	}
+}
+
+func waitTimeoutError(set fluxobject.ObjMetadataSet, lastStatus map[fluxobject.ObjMetadata]*event.ResourceStatus) error {
+	notReady := []ObjectStatus{}
+	for _, id := range set {
+		rs, ok := lastStatus[id]
+		switch {
+		case !ok:
+			notReady = append(notReady, ObjectStatus{
+				ObjMetadata: fromFluxObjMetadata(id),
+				Status:      status.UnknownStatus,
+				Message:     "can't determine status",
+			})
+		case rs.Status != status.CurrentStatus:
+			msg := rs.Message
+			if rs.Error != nil {
+				msg = rs.Error.Error()
+			}
+			notReady = append(notReady, ObjectStatus{
+				ObjMetadata: fromFluxObjMetadata(id),
+				Status:      rs.Status,
+				Message:     msg,
+			})
+		}
+	}
+
+	if len(notReady) == 0 {
+		return nil
+	}
+
+	return &WaitTimeoutError{Objects: notReady}
+}
+
+func fromFluxObjMetadata(id fluxobject.ObjMetadata) object.ObjMetadata {
+	return object.ObjMetadata{
+		Namespace: id.Namespace,
+		Name:      id.Name,
+		GroupKind: id.GroupKind,
+	}
+}
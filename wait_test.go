@@ -0,0 +1,50 @@
+package goply
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/fluxcd/cli-utils/pkg/kstatus/polling/event"
+	"github.com/fluxcd/cli-utils/pkg/kstatus/status"
+	fluxobject "github.com/fluxcd/cli-utils/pkg/object"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestWaitTimeoutError(t *testing.T) {
+	deploy := fluxobject.ObjMetadata{Namespace: "goply-test", Name: "deploy-one", GroupKind: schema.GroupKind{Group: "apps", Kind: "Deployment"}}
+	config := fluxobject.ObjMetadata{Namespace: "goply-test", Name: "config-one", GroupKind: schema.GroupKind{Kind: "ConfigMap"}}
+	job := fluxobject.ObjMetadata{Namespace: "goply-test", Name: "job-one", GroupKind: schema.GroupKind{Group: "batch", Kind: "Job"}}
+	set := fluxobject.ObjMetadataSet{deploy, config, job}
+
+	lastStatus := map[fluxobject.ObjMetadata]*event.ResourceStatus{}
+	recordStatus(lastStatus, &event.ResourceStatus{Identifier: deploy, Status: status.InProgressStatus, Message: "0 of 1 updated replicas are available"})
+	recordStatus(lastStatus, &event.ResourceStatus{Identifier: config, Status: status.CurrentStatus})
+	recordStatus(lastStatus, &event.ResourceStatus{Identifier: job, Status: status.FailedStatus, Error: errors.New("backoff limit exceeded")})
+	// The poller giving up doesn't replace what was last seen
+	recordStatus(lastStatus, &event.ResourceStatus{Identifier: deploy, Status: status.UnknownStatus, Error: fmt.Errorf("polling: %w", context.DeadlineExceeded)})
+
+	err := waitTimeoutError(set, lastStatus)
+	var timeoutErr *WaitTimeoutError
+	require.ErrorAs(t, err, &timeoutErr)
+	require.Equal(t, []ObjectStatus{
+		{ObjMetadata: fromFluxObjMetadata(deploy), Status: status.InProgressStatus, Message: "0 of 1 updated replicas are available"},
+		{ObjMetadata: fromFluxObjMetadata(job), Status: status.FailedStatus, Message: "backoff limit exceeded"},
+	}, timeoutErr.Objects)
+	require.Equal(t, fmt.Sprintf(
+		"timed out waiting for objects to reconcile: [%v status: 'InProgress': 0 of 1 updated replicas are available, %v status: 'Failed': backoff limit exceeded]",
+		fromFluxObjMetadata(deploy), fromFluxObjMetadata(job),
+	), err.Error())
+
+	// Objects the poller never reported on are unknown
+	err = waitTimeoutError(fluxobject.ObjMetadataSet{config, {Namespace: "goply-test", Name: "config-two", GroupKind: schema.GroupKind{Kind: "ConfigMap"}}}, lastStatus)
+	require.ErrorAs(t, err, &timeoutErr)
+	require.Len(t, timeoutErr.Objects, 1)
+	require.Equal(t, "config-two", timeoutErr.Objects[0].Name)
+	require.Equal(t, status.UnknownStatus, timeoutErr.Objects[0].Status)
+	require.Equal(t, "can't determine status", timeoutErr.Objects[0].Message)
+
+	require.NoError(t, waitTimeoutError(fluxobject.ObjMetadataSet{config}, lastStatus))
+}
@@ -0,0 +1,78 @@
+package goply
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+
+	ssaerrors "github.com/fluxcd/pkg/ssa/errors"
+)
+
+const (
+	EventReasonWebhookRejected = "WebhookRejected"
+)
+
+var webhookDenialRegex = regexp.MustCompile(`admission webhook "([^"]+)" denied the request:?\s*(.*)`)
+
+// WebhookRejectionError is returned when an admission webhook denies an object. Object is nil if the rejected object
+// couldn't be determined
+type WebhookRejectionError struct {
+	Object  *InventoryItem
+	Webhook string
+	Reason  string
+	err     error
+}
+
+func (e *WebhookRejectionError) Error() string {
+	if e.Object == nil {
+		return fmt.Sprintf("rejected by webhook %v: %v", e.Webhook, e.Reason)
+	}
+	return fmt.Sprintf("%v rejected by webhook %v: %v", e.Object.ObjMetadata, e.Webhook, e.Reason)
+}
+
+func (e *WebhookRejectionError) Unwrap() error {
+	return e.err
+}
+
+// asWebhookRejection returns the webhook rejection err represents, or nil if it isn't one
+func asWebhookRejection(err error) *WebhookRejectionError {
+	if err == nil {
+		return nil
+	}
+	matches := webhookDenialRegex.FindStringSubmatch(err.Error())
+	if matches == nil {
+		return nil
+	}
+
+	rejection := &WebhookRejectionError{
+		Webhook: matches[1],
+		Reason:  matches[2],
+		err:     err,
+	}
+
+	var dryRunErr *ssaerrors.DryRunErr
+	if errors.As(err, &dryRunErr) && dryRunErr.InvolvedObject() != nil {
+		item := toInventoryItem(dryRunErr.InvolvedObject())
+		rejection.Object = &item
+	}
+
+	return rejection
+}
+
+// reportWebhookRejection emits a warning event and returns a WebhookRejectionError if err is an admission webhook
+// denial, otherwise err is returned as is
+func (r *Reconciler) reportWebhookRejection(ctx context.Context, err error) error {
+	rejection := asWebhookRejection(err)
+	if rejection == nil {
+		return err
+	}
+
+	r.event(ctx, Event{
+		Type:    EventTypeWarning,
+		Reason:  EventReasonWebhookRejected,
+		Object:  rejection.Object,
+		Message: rejection.Error(),
+	})
+	return rejection
+}
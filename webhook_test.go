@@ -0,0 +1,206 @@
+package goply
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	ssaerrors "github.com/fluxcd/pkg/ssa/errors"
+	"github.com/lithammer/dedent"
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/require"
+	admissionv1 "k8s.io/api/admission/v1"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestAsWebhookRejection(t *testing.T) {
+	statusErr := &apierrors.StatusError{ErrStatus: metav1.Status{
+		Status:  metav1.StatusFailure,
+		Code:    400,
+		Reason:  metav1.StatusReasonBadRequest,
+		Message: `admission webhook "policy.example.com" denied the request: replicas must be at least 2`,
+	}}
+
+	obj := &unstructured.Unstructured{}
+	obj.SetAPIVersion("apps/v1")
+	obj.SetKind("Deployment")
+	obj.SetNamespace("goply-test")
+	obj.SetName("deploy-one")
+
+	t.Run("dry run error", func(t *testing.T) {
+		rejection := asWebhookRejection(fmt.Errorf("apply failed: %w", ssaerrors.NewDryRunErr(statusErr, obj)))
+		require.NotNil(t, rejection)
+		require.Equal(t, "policy.example.com", rejection.Webhook)
+		require.Equal(t, "replicas must be at least 2", rejection.Reason)
+		require.NotNil(t, rejection.Object)
+		require.Equal(t, "deploy-one", rejection.Object.Name)
+		require.Equal(
+			t,
+			"goply-test_deploy-one_apps_Deployment rejected by webhook policy.example.com: replicas must be at least 2",
+			rejection.Error(),
+		)
+		require.True(t, apierrors.IsBadRequest(rejection))
+	})
+
+	t.Run("unknown object", func(t *testing.T) {
+		rejection := asWebhookRejection(statusErr)
+		require.NotNil(t, rejection)
+		require.Nil(t, rejection.Object)
+		require.Equal(t, "rejected by webhook policy.example.com: replicas must be at least 2", rejection.Error())
+	})
+
+	t.Run("not a rejection", func(t *testing.T) {
+		require.Nil(t, asWebhookRejection(errors.New("boom")))
+	})
+}
+
+func TestPlanWebhookRejection(t *testing.T) {
+	const ns = "goply-plan-webhook-test"
+	r, client, cleanup := basicSetup(t, ns)
+	defer cleanup()
+
+	// The API server has to be able to call back into this process
+	host, ok := os.LookupEnv("TEST_WEBHOOK_HOST")
+	if !ok {
+		t.Skip("Skipping due to TEST_WEBHOOK_HOST not being set")
+	}
+
+	_, err := r.Apply(dedent.Dedent(fmt.Sprintf(`
+		---
+		apiVersion: v1
+		kind: Namespace
+		metadata:
+		  name: %v
+	`, ns))[1:], ApplyOpts{})
+	require.NoError(t, err)
+
+	url, caBundle := rejectingWebhookServer(t, host)
+	webhook, err := client.AdmissionregistrationV1().ValidatingWebhookConfigurations().Create(context.TODO(), &admissionregistrationv1.ValidatingWebhookConfiguration{
+		ObjectMeta: metav1.ObjectMeta{Name: ns},
+		Webhooks: []admissionregistrationv1.ValidatingWebhook{{
+			Name:                    "reject.goply.example.com",
+			ClientConfig:            admissionregistrationv1.WebhookClientConfig{URL: &url, CABundle: caBundle},
+			AdmissionReviewVersions: []string{"v1"},
+			// Webhooks are only called for dry-run requests when they declare they have no side effects
+			SideEffects:       ptr(admissionregistrationv1.SideEffectClassNone),
+			FailurePolicy:     ptr(admissionregistrationv1.Fail),
+			NamespaceSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"kubernetes.io/metadata.name": ns}},
+			ObjectSelector:    &metav1.LabelSelector{MatchLabels: map[string]string{"goply-test/reject": "true"}},
+			Rules: []admissionregistrationv1.RuleWithOperations{{
+				Operations: []admissionregistrationv1.OperationType{admissionregistrationv1.Create, admissionregistrationv1.Update},
+				Rule: admissionregistrationv1.Rule{
+					APIGroups:   []string{""},
+					APIVersions: []string{"v1"},
+					Resources:   []string{"configmaps"},
+				},
+			}},
+		}},
+	}, metav1.CreateOptions{})
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, client.AdmissionregistrationV1().ValidatingWebhookConfigurations().Delete(context.TODO(), webhook.Name, metav1.DeleteOptions{}))
+	}()
+
+	yaml := dedent.Dedent(fmt.Sprintf(`
+		---
+		apiVersion: v1
+		kind: ConfigMap
+		metadata:
+		  name: config-good
+		  namespace: %v
+		---
+		apiVersion: v1
+		kind: ConfigMap
+		metadata:
+		  name: config-bad
+		  namespace: %v
+		  labels:
+		    goply-test/reject: "true"
+	`, ns, ns))[1:]
+
+	// The API server picks up new webhooks asynchronously
+	var plan Plan
+	require.Eventually(t, func() bool {
+		plan, err = r.Plan(context.Background(), yaml, nil)
+		require.NoError(t, err)
+		return len(plan.Rejected) > 0
+	}, 30*time.Second, 500*time.Millisecond)
+
+	require.Equal(t, []string{"config-good"}, lo.Map(plan.Create, func(i InventoryItem, _ int) string { return i.Name }))
+	require.Equal(t, 1, len(plan.Rejected))
+	require.Equal(t, "config-bad", plan.Rejected[0].Object.Name)
+	require.Equal(t, "reject.goply.example.com", plan.Rejected[0].Webhook)
+	require.Equal(t, "labelled for rejection", plan.Rejected[0].Reason)
+
+	// Nothing was actually created
+	_, err = client.CoreV1().ConfigMaps(ns).Get(context.TODO(), "config-good", metav1.GetOptions{})
+	require.True(t, apierrors.IsNotFound(err))
+}
+
+// rejectingWebhookServer serves a validating webhook on host that denies everything sent to it, returning its URL and
+// the CA bundle to trust it with
+func rejectingWebhookServer(t *testing.T, host string) (string, []byte) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		IsCA:         true,
+
+		BasicConstraintsValid: true,
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		template.IPAddresses = []net.IP{ip}
+	} else {
+		template.DNSNames = []string{host}
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		review := admissionv1.AdmissionReview{}
+		if err := json.NewDecoder(req.Body).Decode(&review); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		review.Response = &admissionv1.AdmissionResponse{
+			UID:     review.Request.UID,
+			Allowed: false,
+			Result:  &metav1.Status{Message: "labelled for rejection"},
+		}
+		review.Request = nil
+		_ = json.NewEncoder(w).Encode(review)
+	}))
+	server.Listener, err = net.Listen("tcp", ":0")
+	require.NoError(t, err)
+	server.TLS = &tls.Config{Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}}}
+	server.StartTLS()
+	t.Cleanup(server.Close)
+
+	port := server.Listener.Addr().(*net.TCPAddr).Port
+	url := "https://" + net.JoinHostPort(host, strconv.Itoa(port)) + "/validate"
+	return url, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+}